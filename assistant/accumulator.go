@@ -0,0 +1,138 @@
+package assistant
+
+import (
+	"sort"
+	"strings"
+)
+
+// MessageAccumulator assembles a streamed assistant turn from events. Tool
+// call fragments are matched by index, or by ID when a provider omits the
+// index, so the complete calls can be handed back to the model on the next
+// turn.
+type MessageAccumulator struct {
+	text      strings.Builder
+	toolCalls map[int]*ToolCall
+	ids       map[string]int
+	reasoning map[int]*ReasoningBlock
+}
+
+// NewMessageAccumulator creates an empty MessageAccumulator.
+func NewMessageAccumulator() *MessageAccumulator {
+	return &MessageAccumulator{
+		toolCalls: map[int]*ToolCall{},
+		ids:       map[string]int{},
+		reasoning: map[int]*ReasoningBlock{},
+	}
+}
+
+// Add consumes a single event. When the event completes a tool call, the
+// finished call is returned; otherwise Add returns nil.
+func (a *MessageAccumulator) Add(ev Event) *ToolCall {
+	switch ev.Type {
+	case EventTextDelta:
+		a.text.WriteString(ev.Text)
+	case EventReasoningDelta:
+		block, ok := a.reasoning[ev.Reasoning.Index]
+		if !ok {
+			block = &ReasoningBlock{}
+			a.reasoning[ev.Reasoning.Index] = block
+		}
+		block.Text += ev.Reasoning.Text
+		block.Signature += ev.Reasoning.Signature
+		block.Redacted += ev.Reasoning.Redacted
+	case EventToolCallStart:
+		tc := a.toolCall(ev.ToolCall)
+		if ev.ToolCall.Name != "" {
+			tc.Function.Name = ev.ToolCall.Name
+		}
+		tc.Function.Arguments += ev.ToolCall.Arguments
+	case EventToolCallDelta:
+		tc := a.toolCall(ev.ToolCall)
+		tc.Function.Arguments += ev.ToolCall.Arguments
+	case EventToolCallEnd:
+		done := *a.toolCall(ev.ToolCall)
+		if done.Function.Arguments == "" {
+			done.Function.Arguments = "{}"
+		}
+		return &done
+	}
+	return nil
+}
+
+// toolCall returns the call a delta belongs to, creating it if needed.
+func (a *MessageAccumulator) toolCall(delta *ToolCallDelta) *ToolCall {
+	index := delta.Index
+	if delta.ID != "" {
+		if i, ok := a.ids[delta.ID]; ok {
+			index = i
+		}
+	}
+
+	tc, ok := a.toolCalls[index]
+	if !ok {
+		tc = &ToolCall{Type: "function"}
+		a.toolCalls[index] = tc
+	}
+	if tc.ID == "" && delta.ID != "" {
+		tc.ID = delta.ID
+		a.ids[delta.ID] = index
+	}
+	return tc
+}
+
+// Text returns the text streamed so far.
+func (a *MessageAccumulator) Text() string {
+	return a.text.String()
+}
+
+// ToolCalls returns the tool calls seen so far, ordered by index.
+func (a *MessageAccumulator) ToolCalls() []ToolCall {
+	if len(a.toolCalls) == 0 {
+		return nil
+	}
+
+	indexes := make([]int, 0, len(a.toolCalls))
+	for i := range a.toolCalls {
+		indexes = append(indexes, i)
+	}
+	sort.Ints(indexes)
+
+	calls := make([]ToolCall, len(indexes))
+	for i, index := range indexes {
+		calls[i] = *a.toolCalls[index]
+		if calls[i].Function.Arguments == "" {
+			calls[i].Function.Arguments = "{}"
+		}
+	}
+	return calls
+}
+
+// Reasoning returns the reasoning blocks seen so far, ordered by index.
+func (a *MessageAccumulator) Reasoning() []ReasoningBlock {
+	if len(a.reasoning) == 0 {
+		return nil
+	}
+
+	indexes := make([]int, 0, len(a.reasoning))
+	for i := range a.reasoning {
+		indexes = append(indexes, i)
+	}
+	sort.Ints(indexes)
+
+	blocks := make([]ReasoningBlock, len(indexes))
+	for i, index := range indexes {
+		blocks[i] = *a.reasoning[index]
+	}
+	return blocks
+}
+
+// Message returns the assistant message built from the stream, ready to be
+// appended to the conversation history.
+func (a *MessageAccumulator) Message() Message {
+	return Message{
+		Role:      RoleAssistant,
+		Content:   a.Text(),
+		ToolCalls: a.ToolCalls(),
+		Reasoning: a.Reasoning(),
+	}
+}
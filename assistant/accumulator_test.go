@@ -0,0 +1,92 @@
+package assistant_test
+
+import (
+	"testing"
+
+	"github.com/sburchfield/go-assistant-api/assistant"
+)
+
+func TestMessageAccumulator(t *testing.T) {
+	events := []assistant.Event{
+		{Type: assistant.EventTextDelta, Text: "Let me "},
+		{Type: assistant.EventTextDelta, Text: "check."},
+		{Type: assistant.EventToolCallStart, ToolCall: &assistant.ToolCallDelta{Index: 0, ID: "call_1", Name: "get_weather"}},
+		{Type: assistant.EventToolCallStart, ToolCall: &assistant.ToolCallDelta{Index: 1, ID: "call_2", Name: "get_time"}},
+		{Type: assistant.EventToolCallDelta, ToolCall: &assistant.ToolCallDelta{Index: 0, Arguments: `{"location":`}},
+		{Type: assistant.EventToolCallDelta, ToolCall: &assistant.ToolCallDelta{Index: 0, Arguments: `"Paris"}`}},
+		{Type: assistant.EventToolCallEnd, ToolCall: &assistant.ToolCallDelta{Index: 0, ID: "call_1"}},
+		{Type: assistant.EventToolCallEnd, ToolCall: &assistant.ToolCallDelta{Index: 1, ID: "call_2"}},
+		{Type: assistant.EventFinish, FinishReason: assistant.FinishReasonToolCalls},
+	}
+
+	acc := assistant.NewMessageAccumulator()
+	var finished []assistant.ToolCall
+	for _, ev := range events {
+		if tc := acc.Add(ev); tc != nil {
+			finished = append(finished, *tc)
+		}
+	}
+
+	if len(finished) != 2 {
+		t.Fatalf("expected 2 finished tool calls, got %d", len(finished))
+	}
+	if finished[0].ID != "call_1" || finished[0].Function.Name != "get_weather" || finished[0].Function.Arguments != `{"location":"Paris"}` {
+		t.Errorf("unexpected first tool call: %+v", finished[0])
+	}
+	if finished[1].Function.Arguments != "{}" {
+		t.Errorf("expected empty arguments to become '{}', got '%s'", finished[1].Function.Arguments)
+	}
+
+	msg := acc.Message()
+	if msg.Role != assistant.RoleAssistant {
+		t.Errorf("expected role '%s', got '%s'", assistant.RoleAssistant, msg.Role)
+	}
+	if msg.Content != "Let me check." {
+		t.Errorf("expected content 'Let me check.', got '%s'", msg.Content)
+	}
+	if len(msg.ToolCalls) != 2 || msg.ToolCalls[0].ID != "call_1" || msg.ToolCalls[1].ID != "call_2" {
+		t.Errorf("unexpected tool calls on message: %+v", msg.ToolCalls)
+	}
+	if msg.ToolCalls[0].Type != "function" {
+		t.Errorf("expected tool call type 'function', got '%s'", msg.ToolCalls[0].Type)
+	}
+}
+
+func TestMessageAccumulator_MatchesByID(t *testing.T) {
+	acc := assistant.NewMessageAccumulator()
+	acc.Add(assistant.Event{Type: assistant.EventToolCallStart, ToolCall: &assistant.ToolCallDelta{Index: 3, ID: "toolu_1", Name: "search"}})
+	acc.Add(assistant.Event{Type: assistant.EventToolCallDelta, ToolCall: &assistant.ToolCallDelta{ID: "toolu_1", Arguments: `{"q":"go"}`}})
+	tc := acc.Add(assistant.Event{Type: assistant.EventToolCallEnd, ToolCall: &assistant.ToolCallDelta{ID: "toolu_1"}})
+
+	if tc == nil {
+		t.Fatal("expected a finished tool call")
+	}
+	if tc.Function.Name != "search" || tc.Function.Arguments != `{"q":"go"}` {
+		t.Errorf("unexpected tool call: %+v", tc)
+	}
+	if len(acc.ToolCalls()) != 1 {
+		t.Errorf("expected 1 tool call, got %d", len(acc.ToolCalls()))
+	}
+}
+
+func TestMessageAccumulator_Reasoning(t *testing.T) {
+	acc := assistant.NewMessageAccumulator()
+	for _, ev := range []assistant.Event{
+		{Type: assistant.EventReasoningDelta, Reasoning: &assistant.ReasoningDelta{Index: 1, Redacted: "opaque"}},
+		{Type: assistant.EventReasoningDelta, Reasoning: &assistant.ReasoningDelta{Index: 0, Text: "The user "}},
+		{Type: assistant.EventReasoningDelta, Reasoning: &assistant.ReasoningDelta{Index: 0, Text: "wants weather."}},
+		{Type: assistant.EventReasoningDelta, Reasoning: &assistant.ReasoningDelta{Index: 0, Signature: "sig"}},
+		{Type: assistant.EventTextDelta, Text: "Checking."},
+	} {
+		acc.Add(ev)
+	}
+
+	msg := acc.Message()
+	want := []assistant.ReasoningBlock{{Text: "The user wants weather.", Signature: "sig"}, {Redacted: "opaque"}}
+	if len(msg.Reasoning) != 2 || msg.Reasoning[0] != want[0] || msg.Reasoning[1] != want[1] {
+		t.Errorf("expected reasoning %+v, got %+v", want, msg.Reasoning)
+	}
+	if msg.Content != "Checking." {
+		t.Errorf("expected content 'Checking.', got '%s'", msg.Content)
+	}
+}
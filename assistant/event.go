@@ -0,0 +1,85 @@
+package assistant
+
+import "context"
+
+// EventType identifies the kind of an Event emitted on a provider event stream.
+type EventType string
+
+const (
+	EventTextDelta      EventType = "text-delta"
+	EventReasoningDelta EventType = "reasoning-delta"
+	EventToolCallStart  EventType = "tool-call-start"
+	EventToolCallDelta  EventType = "tool-call-delta"
+	EventToolCallEnd    EventType = "tool-call-end"
+	EventUsage          EventType = "usage"
+	EventFinish         EventType = "finish"
+	EventError          EventType = "error"
+	EventMetadata       EventType = "metadata"
+)
+
+// Event is a single item on a provider event stream. Only the fields that
+// belong to Type are populated:
+//
+//   - EventTextDelta: Text
+//   - EventReasoningDelta: Reasoning
+//   - EventToolCallStart, EventToolCallDelta, EventToolCallEnd: ToolCall
+//   - EventUsage: Usage
+//   - EventFinish: FinishReason
+//   - EventError: Err
+//   - EventMetadata: Metadata
+//
+// Usage and finish events may arrive in either order. An error event is
+// always the last event before the channel is closed. Metadata events are
+// added by wrappers rather than backends and may be ignored.
+type Event struct {
+	Type         EventType       `json:"type"`
+	Text         string          `json:"text,omitempty"`
+	ToolCall     *ToolCallDelta  `json:"tool_call,omitempty"`
+	Reasoning    *ReasoningDelta `json:"reasoning,omitempty"`
+	Usage        *UsageMetadata  `json:"usage,omitempty"`
+	FinishReason FinishReason    `json:"finish_reason,omitempty"`
+	Metadata     *StreamMetadata `json:"metadata,omitempty"`
+	Err          error           `json:"-"`
+}
+
+// StreamMetadata describes how a response was served, for wrappers such as a
+// fallback chain that pick between backends or a cache that replays earlier
+// responses.
+type StreamMetadata struct {
+	// Provider names the backend that produced the response.
+	Provider string `json:"provider,omitempty"`
+	// Cached reports that the response was replayed from a cache.
+	Cached bool `json:"cached,omitempty"`
+}
+
+// ToolCallDelta describes one piece of a tool call streamed by the model.
+// Start events carry the ID and Name, delta events carry the next fragment of
+// the JSON Arguments, and end events mark the call as complete.
+type ToolCallDelta struct {
+	Index     int    `json:"index"` // Position of the tool call within the assistant turn
+	ID        string `json:"id,omitempty"`
+	Name      string `json:"name,omitempty"`
+	Arguments string `json:"arguments,omitempty"` // JSON fragment
+}
+
+// ReasoningDelta describes one piece of the model's reasoning. Deltas with
+// the same Index belong to the same ReasoningBlock; their Text, Signature and
+// Redacted fragments are concatenated in order.
+type ReasoningDelta struct {
+	Index     int    `json:"index"` // Position of the reasoning block within the assistant turn
+	Text      string `json:"text,omitempty"`
+	Signature string `json:"signature,omitempty"`
+	Redacted  string `json:"redacted,omitempty"`
+}
+
+// SendEvent delivers ev on out unless ctx is done first, and reports whether
+// it was delivered. Producers should stop and release their upstream stream
+// as soon as it returns false, since nobody is reading anymore.
+func SendEvent(ctx context.Context, out chan<- Event, ev Event) bool {
+	select {
+	case out <- ev:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
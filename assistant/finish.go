@@ -0,0 +1,13 @@
+package assistant
+
+// FinishReason explains why the model stopped generating, normalized across
+// providers to the values used by the assistant-ui data stream protocol.
+type FinishReason string
+
+const (
+	FinishReasonStop          FinishReason = "stop"
+	FinishReasonLength        FinishReason = "length"
+	FinishReasonToolCalls     FinishReason = "tool-calls"
+	FinishReasonContentFilter FinishReason = "content-filter"
+	FinishReasonError         FinishReason = "error"
+)
@@ -6,6 +6,20 @@ type Message struct {
 	Content    string     `json:"content,omitempty"`
 	ToolCalls  []ToolCall `json:"tool_calls,omitempty"`
 	ToolCallID string     `json:"tool_call_id,omitempty"`
+	// Reasoning holds the reasoning blocks of an assistant turn. Providers
+	// that verify reasoning, such as Anthropic with extended thinking,
+	// require them to be sent back unchanged alongside tool results.
+	Reasoning []ReasoningBlock `json:"reasoning,omitempty"`
+}
+
+// ReasoningBlock is one block of the model's reasoning.
+type ReasoningBlock struct {
+	// Text is the readable reasoning, or a summary of it.
+	Text string `json:"text,omitempty"`
+	// Signature verifies Text when it is sent back to the same provider.
+	Signature string `json:"signature,omitempty"`
+	// Redacted holds the encrypted content of a block the provider withheld.
+	Redacted string `json:"redacted,omitempty"`
 }
 
 // ToolCall represents a request from the assistant to call a tool
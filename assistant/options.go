@@ -0,0 +1,67 @@
+package assistant
+
+import "fmt"
+
+// GenerationOptions overrides a provider's defaults for a single request.
+// Nil and zero-valued fields keep the values the client was constructed with.
+type GenerationOptions struct {
+	Model         string   `json:"model,omitempty"`
+	Temperature   *float32 `json:"temperature,omitempty"`
+	MaxTokens     *int     `json:"max_tokens,omitempty"`
+	TopP          *float32 `json:"top_p,omitempty"`
+	StopSequences []string `json:"stop_sequences,omitempty"`
+	Seed          *int     `json:"seed,omitempty"`
+}
+
+// GenerationOption sets a field of GenerationOptions.
+type GenerationOption func(*GenerationOptions)
+
+// NewGenerationOptions applies opts, in order, to an empty GenerationOptions.
+func NewGenerationOptions(opts ...GenerationOption) GenerationOptions {
+	var o GenerationOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// WithModel overrides the model used for the request.
+func WithModel(model string) GenerationOption {
+	return func(o *GenerationOptions) { o.Model = model }
+}
+
+// WithTemperature overrides the sampling temperature.
+func WithTemperature(temperature float32) GenerationOption {
+	return func(o *GenerationOptions) { o.Temperature = &temperature }
+}
+
+// WithMaxTokens limits the number of tokens the model may generate.
+func WithMaxTokens(maxTokens int) GenerationOption {
+	return func(o *GenerationOptions) { o.MaxTokens = &maxTokens }
+}
+
+// WithTopP sets nucleus sampling.
+func WithTopP(topP float32) GenerationOption {
+	return func(o *GenerationOptions) { o.TopP = &topP }
+}
+
+// WithStopSequences stops generation when the model produces any of the given sequences.
+func WithStopSequences(stop ...string) GenerationOption {
+	return func(o *GenerationOptions) { o.StopSequences = stop }
+}
+
+// WithSeed requests deterministic sampling where the provider supports it.
+func WithSeed(seed int) GenerationOption {
+	return func(o *GenerationOptions) { o.Seed = &seed }
+}
+
+// UnsupportedOptionError is returned when a request sets a generation option
+// the provider cannot honor, rather than silently dropping it.
+type UnsupportedOptionError struct {
+	Provider string
+	Option   string
+}
+
+func (e *UnsupportedOptionError) Error() string {
+	return fmt.Sprintf("%s does not support the %q generation option", e.Provider, e.Option)
+}
@@ -0,0 +1,136 @@
+package anthropic
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// Wire types for the Anthropic Messages API. Only the fields this package
+// reads or writes are declared.
+
+type messagesRequest struct {
+	Model         string         `json:"model"`
+	MaxTokens     int            `json:"max_tokens"`
+	Messages      []message      `json:"messages"`
+	System        []contentBlock `json:"system,omitempty"`
+	Temperature   *float32       `json:"temperature,omitempty"`
+	TopP          *float32       `json:"top_p,omitempty"`
+	StopSequences []string       `json:"stop_sequences,omitempty"`
+	Tools         []tool         `json:"tools,omitempty"`
+	ToolChoice    *toolChoice    `json:"tool_choice,omitempty"`
+	Thinking      *thinking      `json:"thinking,omitempty"`
+	Stream        bool           `json:"stream,omitempty"`
+}
+
+type thinking struct {
+	Type         string `json:"type"`
+	BudgetTokens int    `json:"budget_tokens"`
+}
+
+type message struct {
+	Role    string         `json:"role"`
+	Content []contentBlock `json:"content"`
+}
+
+// contentBlock is a text, thinking, redacted_thinking, tool_use or
+// tool_result block.
+type contentBlock struct {
+	Type string `json:"type"`
+
+	// text
+	Text string `json:"text,omitempty"`
+
+	// thinking and redacted_thinking
+	Thinking  string `json:"thinking,omitempty"`
+	Signature string `json:"signature,omitempty"`
+	Data      string `json:"data,omitempty"`
+
+	// tool_use
+	ID    string          `json:"id,omitempty"`
+	Name  string          `json:"name,omitempty"`
+	Input json.RawMessage `json:"input,omitempty"`
+
+	// tool_result
+	ToolUseID string `json:"tool_use_id,omitempty"`
+	Content   string `json:"content,omitempty"`
+
+	CacheControl *cacheControl `json:"cache_control,omitempty"`
+}
+
+type cacheControl struct {
+	Type string `json:"type"`
+}
+
+type tool struct {
+	Name         string                 `json:"name"`
+	Description  string                 `json:"description,omitempty"`
+	InputSchema  map[string]interface{} `json:"input_schema"`
+	CacheControl *cacheControl          `json:"cache_control,omitempty"`
+}
+
+type toolChoice struct {
+	Type string `json:"type"`
+}
+
+type usage struct {
+	InputTokens              int32 `json:"input_tokens"`
+	OutputTokens             int32 `json:"output_tokens"`
+	CacheCreationInputTokens int32 `json:"cache_creation_input_tokens"`
+	CacheReadInputTokens     int32 `json:"cache_read_input_tokens"`
+}
+
+// promptTokens counts every input token, whether or not it was served from the cache.
+func (u usage) promptTokens() int32 {
+	return u.InputTokens + u.CacheCreationInputTokens + u.CacheReadInputTokens
+}
+
+type messagesResponse struct {
+	Content    []contentBlock `json:"content"`
+	StopReason string         `json:"stop_reason"`
+	Usage      usage          `json:"usage"`
+}
+
+// streamEvent is a single server-sent event from a streaming Messages request.
+type streamEvent struct {
+	Type         string            `json:"type"`
+	Index        int               `json:"index"`
+	Message      *messagesResponse `json:"message,omitempty"`
+	ContentBlock *contentBlock     `json:"content_block,omitempty"`
+	Delta        *streamDelta      `json:"delta,omitempty"`
+	Usage        *usage            `json:"usage,omitempty"`
+	Error        *apiError         `json:"error,omitempty"`
+}
+
+type streamDelta struct {
+	Type        string `json:"type"`
+	Text        string `json:"text,omitempty"`
+	PartialJSON string `json:"partial_json,omitempty"`
+	Thinking    string `json:"thinking,omitempty"`
+	Signature   string `json:"signature,omitempty"`
+	StopReason  string `json:"stop_reason,omitempty"`
+}
+
+type apiError struct {
+	Type    string `json:"type"`
+	Message string `json:"message"`
+}
+
+// APIError is returned when the Anthropic API rejects a request or reports an
+// error mid-stream.
+type APIError struct {
+	// StatusCode is the HTTP status, or 0 for errors reported inside a stream.
+	StatusCode int
+	// Type is the Anthropic error type, e.g. "overloaded_error".
+	Type    string
+	Message string
+	// RetryAfter is the delay requested by the Retry-After header, if any.
+	RetryAfter time.Duration
+}
+
+func (e *APIError) Error() string {
+	if e.StatusCode != 0 {
+		return fmt.Sprintf("anthropic: %s (%d): %s", e.Type, e.StatusCode, e.Message)
+	}
+	return fmt.Sprintf("anthropic: %s: %s", e.Type, e.Message)
+}
@@ -0,0 +1,600 @@
+package anthropic
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/sburchfield/go-assistant-api/assistant"
+)
+
+const (
+	// DefaultBaseURL is the Anthropic API endpoint used unless WithBaseURL is given.
+	DefaultBaseURL = "https://api.anthropic.com"
+	// DefaultMaxTokens is sent when neither the client nor the request sets a
+	// limit, since the Messages API requires one.
+	DefaultMaxTokens = 4096
+
+	apiVersion = "2023-06-01"
+)
+
+// Client streams chat completions from the Anthropic Messages API.
+type Client struct {
+	httpClient  *http.Client
+	baseURL     string
+	apiKey      string
+	apiKeyFunc  func(context.Context) (string, error)
+	model       string
+	temperature float32
+	maxTokens   int
+	betas       []string
+	promptCache bool
+	thinking    int
+}
+
+// Option configures a Client.
+type Option func(*Client)
+
+// WithBaseURL sends requests to baseURL instead of DefaultBaseURL.
+func WithBaseURL(baseURL string) Option {
+	return func(c *Client) { c.baseURL = strings.TrimRight(baseURL, "/") }
+}
+
+// WithHTTPClient sends requests through httpClient instead of http.DefaultClient.
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(c *Client) { c.httpClient = httpClient }
+}
+
+// WithAPIKeyProvider fetches the API key before every request instead of
+// using the key passed to NewClient, so rotated keys are picked up without
+// rebuilding the client.
+func WithAPIKeyProvider(apiKey func(context.Context) (string, error)) Option {
+	return func(c *Client) { c.apiKeyFunc = apiKey }
+}
+
+// WithMaxTokens sets the default output token limit for requests that do not set one.
+func WithMaxTokens(maxTokens int) Option {
+	return func(c *Client) { c.maxTokens = maxTokens }
+}
+
+// WithBeta opts in to beta features by sending them in the anthropic-beta header.
+func WithBeta(features ...string) Option {
+	return func(c *Client) { c.betas = append(c.betas, features...) }
+}
+
+// WithPromptCaching marks the system prompt and tool definitions as cacheable,
+// so repeated requests sharing them are billed at the cached input rate.
+func WithPromptCaching() Option {
+	return func(c *Client) { c.promptCache = true }
+}
+
+// WithThinking enables extended thinking, letting the model spend up to
+// budgetTokens output tokens reasoning before it answers. Thinking is
+// streamed as assistant.EventReasoningDelta events and collected into
+// Message.Reasoning; keep it in the conversation history, since it must be
+// sent back with tool results. While thinking, the client's temperature is
+// not sent, as the API only accepts the default, and the client's max tokens
+// are raised above the budget.
+func WithThinking(budgetTokens int) Option {
+	return func(c *Client) { c.thinking = budgetTokens }
+}
+
+// NewClient creates a new Anthropic client.
+func NewClient(apiKey, model string, temperature float32, opts ...Option) *Client {
+	c := &Client{
+		httpClient:  http.DefaultClient,
+		baseURL:     DefaultBaseURL,
+		apiKey:      apiKey,
+		model:       model,
+		temperature: temperature,
+		maxTokens:   DefaultMaxTokens,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// ChatStream streams chat completions without tools.
+func (c *Client) ChatStream(ctx context.Context, messages []assistant.Message) (<-chan string, error) {
+	return c.ChatStreamWithTools(ctx, messages, nil, assistant.ToolChoiceAuto)
+}
+
+// ChatStreamWithTools streams chat completions with optional tool support.
+func (c *Client) ChatStreamWithTools(
+	ctx context.Context,
+	messages []assistant.Message,
+	tools []assistant.Tool,
+	toolChoice assistant.ToolChoice,
+	opts ...assistant.GenerationOption,
+) (<-chan string, error) {
+	events, err := c.ChatStreamEvents(ctx, messages, tools, toolChoice, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return assistant.TextWithToolCalls(ctx, events), nil
+}
+
+// ChatStreamWithUsage streams chat completions and provides usage metadata.
+func (c *Client) ChatStreamWithUsage(ctx context.Context, messages []assistant.Message) (*assistant.StreamResult, error) {
+	return c.ChatStreamWithToolsAndUsage(ctx, messages, nil, assistant.ToolChoiceAuto)
+}
+
+// ChatStreamWithToolsAndUsage streams chat completions with tools and provides usage metadata.
+func (c *Client) ChatStreamWithToolsAndUsage(
+	ctx context.Context,
+	messages []assistant.Message,
+	tools []assistant.Tool,
+	toolChoice assistant.ToolChoice,
+	opts ...assistant.GenerationOption,
+) (*assistant.StreamResult, error) {
+	events, err := c.ChatStreamEvents(ctx, messages, tools, toolChoice, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return assistant.NewStreamResult(ctx, events), nil
+}
+
+// ChatStreamEvents streams chat completions as typed events.
+func (c *Client) ChatStreamEvents(
+	ctx context.Context,
+	messages []assistant.Message,
+	tools []assistant.Tool,
+	toolChoice assistant.ToolChoice,
+	opts ...assistant.GenerationOption,
+) (<-chan assistant.Event, error) {
+	req, err := c.buildRequest(messages, tools, toolChoice, assistant.NewGenerationOptions(opts...))
+	if err != nil {
+		return nil, err
+	}
+	req.Stream = true
+
+	body, err := c.post(ctx, req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start message stream: %w", err)
+	}
+
+	out := make(chan assistant.Event)
+	go func() {
+		defer close(out)
+		processStream(ctx, body, out)
+	}()
+
+	return out, nil
+}
+
+// Chat returns the complete response using the non-streaming Messages API.
+func (c *Client) Chat(ctx context.Context, messages []assistant.Message, opts assistant.ChatOptions) (*assistant.Response, error) {
+	req, err := c.buildRequest(messages, opts.Tools, opts.ToolChoice, opts.GenerationOptions)
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := c.post(ctx, req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create message: %w", err)
+	}
+	defer body.Close()
+
+	var output messagesResponse
+	if err := json.NewDecoder(body).Decode(&output); err != nil {
+		return nil, fmt.Errorf("failed to decode message: %w", err)
+	}
+
+	msg := assistant.Message{Role: assistant.RoleAssistant}
+	for _, block := range output.Content {
+		switch block.Type {
+		case "text":
+			msg.Content += block.Text
+		case "thinking":
+			msg.Reasoning = append(msg.Reasoning, assistant.ReasoningBlock{Text: block.Thinking, Signature: block.Signature})
+		case "redacted_thinking":
+			msg.Reasoning = append(msg.Reasoning, assistant.ReasoningBlock{Redacted: block.Data})
+		case "tool_use":
+			args := "{}"
+			if len(block.Input) > 0 {
+				args = string(block.Input)
+			}
+			msg.ToolCalls = append(msg.ToolCalls, assistant.ToolCall{
+				ID:   block.ID,
+				Type: "function",
+				Function: assistant.FunctionCall{
+					Name:      block.Name,
+					Arguments: args,
+				},
+			})
+		}
+	}
+
+	return &assistant.Response{
+		Message:      msg,
+		ToolCalls:    msg.ToolCalls,
+		FinishReason: finishReason(output.StopReason),
+		Usage:        usageMetadata(output.Usage),
+	}, nil
+}
+
+// post sends a Messages request and returns the response body. Non-2xx
+// responses are returned as an *APIError.
+func (c *Client) post(ctx context.Context, req *messagesRequest) (io.ReadCloser, error) {
+	payload, err := json.Marshal(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode request: %w", err)
+	}
+
+	apiKey := c.apiKey
+	if c.apiKeyFunc != nil {
+		if apiKey, err = c.apiKeyFunc(ctx); err != nil {
+			return nil, fmt.Errorf("failed to get API key: %w", err)
+		}
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+"/v1/messages", bytes.NewReader(payload))
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("X-Api-Key", apiKey)
+	httpReq.Header.Set("Anthropic-Version", apiVersion)
+	if len(c.betas) > 0 {
+		httpReq.Header.Set("Anthropic-Beta", strings.Join(c.betas, ","))
+	}
+
+	resp, err := c.httpClient.Do(httpReq)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode/100 != 2 {
+		defer resp.Body.Close()
+		apiErr := &APIError{
+			StatusCode: resp.StatusCode,
+			Type:       "api_error",
+			Message:    resp.Status,
+			RetryAfter: assistant.ParseRetryAfter(resp.Header),
+		}
+		var errBody struct {
+			Error apiError `json:"error"`
+		}
+		if json.NewDecoder(resp.Body).Decode(&errBody) == nil && errBody.Error.Type != "" {
+			apiErr.Type, apiErr.Message = errBody.Error.Type, errBody.Error.Message
+		}
+		return nil, apiErr
+	}
+	return resp.Body, nil
+}
+
+// buildRequest converts messages, tools and generation options into a Messages request.
+func (c *Client) buildRequest(
+	messages []assistant.Message,
+	tools []assistant.Tool,
+	toolChoice assistant.ToolChoice,
+	gen assistant.GenerationOptions,
+) (*messagesRequest, error) {
+	if len(messages) == 0 {
+		return nil, errors.New("ChatStream: no messages provided")
+	}
+	if gen.Seed != nil {
+		return nil, &assistant.UnsupportedOptionError{Provider: "anthropic", Option: "seed"}
+	}
+
+	converted, system, err := convertMessages(messages)
+	if err != nil {
+		return nil, err
+	}
+
+	req := &messagesRequest{
+		Model:     c.model,
+		MaxTokens: c.maxTokens,
+		Messages:  converted,
+		System:    system,
+	}
+
+	// Apply per-request generation options
+	if gen.Model != "" {
+		req.Model = gen.Model
+	}
+	// Current models reject temperature together with top_p, and any
+	// temperature but the default while thinking, so the client's
+	// temperature only applies when neither is in play.
+	switch {
+	case gen.Temperature != nil:
+		req.Temperature = gen.Temperature
+	case gen.TopP == nil && c.thinking == 0:
+		temperature := c.temperature
+		req.Temperature = &temperature
+	}
+	if gen.MaxTokens != nil {
+		req.MaxTokens = *gen.MaxTokens
+	}
+	if gen.TopP != nil {
+		req.TopP = gen.TopP
+	}
+	if len(gen.StopSequences) > 0 {
+		req.StopSequences = gen.StopSequences
+	}
+
+	if c.thinking > 0 {
+		req.Thinking = &thinking{Type: "enabled", BudgetTokens: c.thinking}
+		if req.MaxTokens <= c.thinking {
+			if gen.MaxTokens != nil {
+				return nil, fmt.Errorf("anthropic: max tokens (%d) must exceed the thinking budget (%d)", req.MaxTokens, c.thinking)
+			}
+			req.MaxTokens = c.thinking + c.maxTokens
+		}
+	}
+
+	// Add tools if provided
+	if len(tools) > 0 {
+		req.Tools, req.ToolChoice = convertTools(tools, toolChoice)
+	}
+
+	if c.promptCache {
+		// A cache breakpoint covers everything before it: tools, then system.
+		if len(req.System) > 0 {
+			req.System[len(req.System)-1].CacheControl = &cacheControl{Type: "ephemeral"}
+		} else if len(req.Tools) > 0 {
+			req.Tools[len(req.Tools)-1].CacheControl = &cacheControl{Type: "ephemeral"}
+		}
+	}
+
+	return req, nil
+}
+
+// convertMessages converts assistant.Message to Anthropic messages.
+// Returns the conversation messages and any system prompts separately.
+func convertMessages(messages []assistant.Message) ([]message, []contentBlock, error) {
+	var converted []message
+	var system []contentBlock
+
+	for i, msg := range messages {
+		switch msg.Role {
+		case assistant.RoleSystem:
+			if msg.Content != "" {
+				system = append(system, contentBlock{Type: "text", Text: msg.Content})
+			}
+		case assistant.RoleUser:
+			// The API rejects empty text blocks with a 400.
+			if msg.Content == "" {
+				return nil, nil, fmt.Errorf("anthropic: message %d: user message has no content", i)
+			}
+			converted = append(converted, message{
+				Role:    "user",
+				Content: []contentBlock{{Type: "text", Text: msg.Content}},
+			})
+		case assistant.RoleAssistant:
+			// Thinking comes first and is sent back as it was received. Unsigned
+			// reasoning, such as summaries from another provider, cannot be
+			// verified and is left out.
+			var content []contentBlock
+			for _, r := range msg.Reasoning {
+				switch {
+				case r.Redacted != "":
+					content = append(content, contentBlock{Type: "redacted_thinking", Data: r.Redacted})
+				case r.Signature != "":
+					content = append(content, contentBlock{Type: "thinking", Thinking: r.Text, Signature: r.Signature})
+				}
+			}
+			// The API rejects empty text blocks, so tool-call-only turns carry
+			// just their tool_use blocks.
+			if msg.Content != "" {
+				content = append(content, contentBlock{Type: "text", Text: msg.Content})
+			}
+			for _, tc := range msg.ToolCalls {
+				input := json.RawMessage(tc.Function.Arguments)
+				if !json.Valid(input) {
+					input = json.RawMessage("{}")
+				}
+				content = append(content, contentBlock{
+					Type:  "tool_use",
+					ID:    tc.ID,
+					Name:  tc.Function.Name,
+					Input: input,
+				})
+			}
+			if len(content) > 0 {
+				converted = append(converted, message{Role: "assistant", Content: content})
+			}
+		case assistant.RoleTool:
+			// Tool results go as user messages; the API folds consecutive
+			// results into a single turn.
+			converted = append(converted, message{
+				Role: "user",
+				Content: []contentBlock{{
+					Type:      "tool_result",
+					ToolUseID: msg.ToolCallID,
+					Content:   msg.Content,
+				}},
+			})
+		}
+	}
+
+	return converted, system, nil
+}
+
+// convertTools converts assistant tools and tool choice to the Messages API format.
+func convertTools(tools []assistant.Tool, choice assistant.ToolChoice) ([]tool, *toolChoice) {
+	converted := make([]tool, len(tools))
+	for i, t := range tools {
+		schema := t.Function.Parameters
+		if schema == nil {
+			schema = map[string]interface{}{"type": "object"}
+		}
+		converted[i] = tool{
+			Name:        t.Function.Name,
+			Description: t.Function.Description,
+			InputSchema: schema,
+		}
+	}
+
+	switch choice {
+	case assistant.ToolChoiceAuto:
+		return converted, &toolChoice{Type: "auto"}
+	case assistant.ToolChoiceRequired:
+		return converted, &toolChoice{Type: "any"}
+	case assistant.ToolChoiceNone:
+		return converted, &toolChoice{Type: "none"}
+	default:
+		return converted, nil
+	}
+}
+
+// processStream translates Messages API server-sent events into assistant
+// events until the stream ends or ctx is done.
+func processStream(ctx context.Context, body io.ReadCloser, out chan<- assistant.Event) {
+	// Closing the body releases the underlying HTTP connection.
+	defer body.Close()
+
+	send := func(ev assistant.Event) bool {
+		return assistant.SendEvent(ctx, out, ev)
+	}
+
+	// Anthropic numbers content blocks across text, thinking and tool use,
+	// so map the tool use and thinking blocks onto their position among the
+	// tool calls and reasoning blocks.
+	toolCalls := map[int]*assistant.ToolCallDelta{}
+	reasoning := map[int]int{}
+	var tokens usage
+
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		data, ok := strings.CutPrefix(scanner.Text(), "data:")
+		if !ok {
+			continue
+		}
+
+		var event streamEvent
+		if err := json.Unmarshal([]byte(strings.TrimSpace(data)), &event); err != nil {
+			send(assistant.Event{Type: assistant.EventError, Err: fmt.Errorf("failed to decode stream event: %w", err)})
+			return
+		}
+
+		switch event.Type {
+		case "message_start":
+			if event.Message != nil {
+				tokens = event.Message.Usage
+			}
+		case "content_block_start":
+			if event.ContentBlock == nil {
+				continue
+			}
+			switch event.ContentBlock.Type {
+			case "tool_use":
+				tc := &assistant.ToolCallDelta{
+					Index: len(toolCalls),
+					ID:    event.ContentBlock.ID,
+					Name:  event.ContentBlock.Name,
+				}
+				toolCalls[event.Index] = tc
+				if !send(assistant.Event{Type: assistant.EventToolCallStart, ToolCall: tc}) {
+					return
+				}
+			case "thinking":
+				reasoning[event.Index] = len(reasoning)
+			case "redacted_thinking":
+				reasoning[event.Index] = len(reasoning)
+				if !send(assistant.Event{
+					Type:      assistant.EventReasoningDelta,
+					Reasoning: &assistant.ReasoningDelta{Index: reasoning[event.Index], Redacted: event.ContentBlock.Data},
+				}) {
+					return
+				}
+			}
+		case "content_block_delta":
+			if event.Delta == nil {
+				continue
+			}
+			switch event.Delta.Type {
+			case "text_delta":
+				if !send(assistant.Event{Type: assistant.EventTextDelta, Text: event.Delta.Text}) {
+					return
+				}
+			case "thinking_delta", "signature_delta":
+				if !send(assistant.Event{
+					Type: assistant.EventReasoningDelta,
+					Reasoning: &assistant.ReasoningDelta{
+						Index:     reasoning[event.Index],
+						Text:      event.Delta.Thinking,
+						Signature: event.Delta.Signature,
+					},
+				}) {
+					return
+				}
+			case "input_json_delta":
+				if tc, ok := toolCalls[event.Index]; ok && event.Delta.PartialJSON != "" {
+					if !send(assistant.Event{
+						Type:     assistant.EventToolCallDelta,
+						ToolCall: &assistant.ToolCallDelta{Index: tc.Index, ID: tc.ID, Arguments: event.Delta.PartialJSON},
+					}) {
+						return
+					}
+				}
+			}
+		case "content_block_stop":
+			if tc, ok := toolCalls[event.Index]; ok {
+				if !send(assistant.Event{
+					Type:     assistant.EventToolCallEnd,
+					ToolCall: &assistant.ToolCallDelta{Index: tc.Index, ID: tc.ID},
+				}) {
+					return
+				}
+			}
+		case "message_delta":
+			// Output token counts in message_delta are cumulative.
+			if event.Usage != nil {
+				tokens.OutputTokens = event.Usage.OutputTokens
+			}
+			if event.Delta != nil && event.Delta.StopReason != "" {
+				if !send(assistant.Event{Type: assistant.EventFinish, FinishReason: finishReason(event.Delta.StopReason)}) {
+					return
+				}
+			}
+		case "message_stop":
+			send(assistant.Event{Type: assistant.EventUsage, Usage: usageMetadata(tokens)})
+			return
+		case "error":
+			err := &APIError{Type: "api_error"}
+			if event.Error != nil {
+				err.Type, err.Message = event.Error.Type, event.Error.Message
+			}
+			send(assistant.Event{Type: assistant.EventError, Err: fmt.Errorf("stream error: %w", err)})
+			return
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		send(assistant.Event{Type: assistant.EventError, Err: fmt.Errorf("stream error: %w", err)})
+		return
+	}
+	send(assistant.Event{Type: assistant.EventError, Err: fmt.Errorf("stream error: %w", io.ErrUnexpectedEOF)})
+}
+
+// usageMetadata converts Anthropic token counts to assistant.UsageMetadata.
+func usageMetadata(u usage) *assistant.UsageMetadata {
+	prompt := u.promptTokens()
+	return &assistant.UsageMetadata{
+		PromptTokenCount:     prompt,
+		CandidatesTokenCount: u.OutputTokens,
+		TotalTokenCount:      prompt + u.OutputTokens,
+	}
+}
+
+// finishReason normalizes an Anthropic stop reason.
+func finishReason(reason string) assistant.FinishReason {
+	switch reason {
+	case "max_tokens", "model_context_window_exceeded":
+		return assistant.FinishReasonLength
+	case "tool_use":
+		return assistant.FinishReasonToolCalls
+	case "refusal":
+		return assistant.FinishReasonContentFilter
+	default:
+		return assistant.FinishReasonStop
+	}
+}
@@ -0,0 +1,562 @@
+package anthropic_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sburchfield/go-assistant-api/assistant"
+	"github.com/sburchfield/go-assistant-api/assistant/provider/anthropic"
+	"github.com/sburchfield/go-assistant-api/internal/testutil"
+)
+
+// newTestClient returns a client whose requests are served by handler instead of the Anthropic API.
+func newTestClient(t *testing.T, handler http.HandlerFunc, opts ...anthropic.Option) *anthropic.Client {
+	t.Helper()
+
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	opts = append([]anthropic.Option{
+		anthropic.WithBaseURL(server.URL),
+		anthropic.WithHTTPClient(server.Client()),
+	}, opts...)
+	return anthropic.NewClient("test-key", "claude-test", 0.7, opts...)
+}
+
+// streamEvents serves each event as a server-sent event, named after its type.
+func streamEvents(events ...string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, data := range events {
+			var event struct {
+				Type string `json:"type"`
+			}
+			_ = json.Unmarshal([]byte(data), &event)
+			fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event.Type, data)
+		}
+	}
+}
+
+var helloWorld = []string{
+	`{"type":"message_start","message":{"id":"msg_1","type":"message","role":"assistant","content":[],"usage":{"input_tokens":3,"output_tokens":1}}}`,
+	`{"type":"content_block_start","index":0,"content_block":{"type":"text","text":""}}`,
+	`{"type":"ping"}`,
+	`{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"Hello"}}`,
+	`{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":" world"}}`,
+	`{"type":"content_block_stop","index":0}`,
+	`{"type":"message_delta","delta":{"stop_reason":"end_turn"},"usage":{"output_tokens":2}}`,
+	`{"type":"message_stop"}`,
+}
+
+var weatherCall = []string{
+	`{"type":"message_start","message":{"id":"msg_2","type":"message","role":"assistant","content":[],"usage":{"input_tokens":10,"cache_read_input_tokens":5,"output_tokens":1}}}`,
+	`{"type":"content_block_start","index":0,"content_block":{"type":"text","text":""}}`,
+	`{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"Let me check."}}`,
+	`{"type":"content_block_stop","index":0}`,
+	`{"type":"content_block_start","index":1,"content_block":{"type":"tool_use","id":"toolu_1","name":"get_weather","input":{}}}`,
+	`{"type":"content_block_delta","index":1,"delta":{"type":"input_json_delta","partial_json":""}}`,
+	`{"type":"content_block_delta","index":1,"delta":{"type":"input_json_delta","partial_json":"{\"location\":"}}`,
+	`{"type":"content_block_delta","index":1,"delta":{"type":"input_json_delta","partial_json":"\"Paris\"}"}}`,
+	`{"type":"content_block_stop","index":1}`,
+	`{"type":"message_delta","delta":{"stop_reason":"tool_use"},"usage":{"output_tokens":20}}`,
+	`{"type":"message_stop"}`,
+}
+
+var weatherTool = assistant.Tool{
+	Type: "function",
+	Function: assistant.ToolFunction{
+		Name:        "get_weather",
+		Description: "Get the weather for a location",
+		Parameters: map[string]interface{}{
+			"type":       "object",
+			"properties": map[string]interface{}{"location": map[string]interface{}{"type": "string"}},
+		},
+	},
+}
+
+func TestChatStream_Anthropic(t *testing.T) {
+	client := newTestClient(t, streamEvents(helloWorld...))
+
+	stream, err := client.ChatStream(context.Background(), []assistant.Message{
+		{Role: assistant.RoleUser, Content: "Say something"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var result string
+	for msg := range stream {
+		result += msg
+	}
+	if result != "Hello world" {
+		t.Errorf("expected 'Hello world', got %q", result)
+	}
+}
+
+func TestChatStreamWithUsage_Anthropic(t *testing.T) {
+	client := newTestClient(t, streamEvents(helloWorld...))
+
+	result, err := client.ChatStreamWithUsage(context.Background(), []assistant.Message{
+		{Role: assistant.RoleUser, Content: "Say something"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for range result.TextChannel {
+	}
+
+	usage := result.GetUsage()
+	if usage == nil || usage.PromptTokenCount != 3 || usage.CandidatesTokenCount != 2 || usage.TotalTokenCount != 5 {
+		t.Errorf("unexpected usage metadata: %+v", usage)
+	}
+	if result.GetFinishReason() != assistant.FinishReasonStop {
+		t.Errorf("expected finish reason 'stop', got '%s'", result.GetFinishReason())
+	}
+}
+
+func TestChatStreamEvents_AnthropicToolCalls(t *testing.T) {
+	client := newTestClient(t, streamEvents(weatherCall...))
+
+	events, err := client.ChatStreamEvents(context.Background(), []assistant.Message{
+		{Role: assistant.RoleUser, Content: "What's the weather in Paris?"},
+	}, []assistant.Tool{weatherTool}, assistant.ToolChoiceAuto)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	resp, err := assistant.Collect(events)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if resp.Message.Content != "Let me check." {
+		t.Errorf("expected 'Let me check.', got %q", resp.Message.Content)
+	}
+	if len(resp.ToolCalls) != 1 {
+		t.Fatalf("expected 1 tool call, got %+v", resp.ToolCalls)
+	}
+	tc := resp.ToolCalls[0]
+	if tc.ID != "toolu_1" || tc.Function.Name != "get_weather" || tc.Function.Arguments != `{"location":"Paris"}` {
+		t.Errorf("unexpected tool call: %+v", tc)
+	}
+	if resp.FinishReason != assistant.FinishReasonToolCalls {
+		t.Errorf("expected finish reason 'tool-calls', got '%s'", resp.FinishReason)
+	}
+	if resp.Usage == nil || resp.Usage.PromptTokenCount != 15 || resp.Usage.CandidatesTokenCount != 20 || resp.Usage.TotalTokenCount != 35 {
+		t.Errorf("unexpected usage metadata: %+v", resp.Usage)
+	}
+}
+
+func TestChatStreamEvents_AnthropicStreamError(t *testing.T) {
+	client := newTestClient(t, streamEvents(
+		helloWorld[0],
+		`{"type":"error","error":{"type":"overloaded_error","message":"Overloaded"}}`,
+	))
+
+	events, err := client.ChatStreamEvents(context.Background(), []assistant.Message{
+		{Role: assistant.RoleUser, Content: "Say something"},
+	}, nil, assistant.ToolChoiceAuto)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	_, err = assistant.Collect(events)
+	var apiErr *anthropic.APIError
+	if !errors.As(err, &apiErr) || apiErr.Type != "overloaded_error" {
+		t.Fatalf("expected an overloaded_error APIError, got %v", err)
+	}
+}
+
+func TestChatStreamEvents_AnthropicTruncatedStream(t *testing.T) {
+	client := newTestClient(t, streamEvents(helloWorld[:4]...))
+
+	events, err := client.ChatStreamEvents(context.Background(), []assistant.Message{
+		{Role: assistant.RoleUser, Content: "Say something"},
+	}, nil, assistant.ToolChoiceAuto)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if _, err := assistant.Collect(events); !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Fatalf("expected a stream cut short to report io.ErrUnexpectedEOF, got %v", err)
+	}
+}
+
+func TestChatStreamEvents_AnthropicHTTPError(t *testing.T) {
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Retry-After", "7")
+		w.WriteHeader(http.StatusTooManyRequests)
+		_, _ = io.WriteString(w, `{"type":"error","error":{"type":"rate_limit_error","message":"Slow down"}}`)
+	})
+
+	_, err := client.ChatStreamEvents(context.Background(), []assistant.Message{
+		{Role: assistant.RoleUser, Content: "Say something"},
+	}, nil, assistant.ToolChoiceAuto)
+
+	var apiErr *anthropic.APIError
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("expected an APIError, got %v", err)
+	}
+	if apiErr.StatusCode != http.StatusTooManyRequests || apiErr.Type != "rate_limit_error" || apiErr.Message != "Slow down" {
+		t.Errorf("unexpected APIError: %+v", apiErr)
+	}
+	if apiErr.RetryAfter != 7*time.Second {
+		t.Errorf("expected RetryAfter 7s, got %v", apiErr.RetryAfter)
+	}
+}
+
+func TestChat_Anthropic(t *testing.T) {
+	var stream any
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]any
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		stream = body["stream"]
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = io.WriteString(w, `{
+			"id": "msg_3", "type": "message", "role": "assistant",
+			"content": [
+				{"type": "text", "text": "Checking."},
+				{"type": "tool_use", "id": "toolu_2", "name": "get_weather", "input": {"location": "Paris"}}
+			],
+			"stop_reason": "tool_use",
+			"usage": {"input_tokens": 12, "output_tokens": 8}
+		}`)
+	})
+
+	resp, err := client.Chat(context.Background(), []assistant.Message{
+		{Role: assistant.RoleUser, Content: "What's the weather in Paris?"},
+	}, assistant.ChatOptions{Tools: []assistant.Tool{weatherTool}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if stream != nil {
+		t.Errorf("expected a non-streaming request, got stream=%v", stream)
+	}
+	if resp.Message.Content != "Checking." {
+		t.Errorf("expected 'Checking.', got %q", resp.Message.Content)
+	}
+	if len(resp.ToolCalls) != 1 || resp.ToolCalls[0].ID != "toolu_2" || resp.ToolCalls[0].Function.Arguments != `{"location": "Paris"}` {
+		t.Errorf("unexpected tool calls: %+v", resp.ToolCalls)
+	}
+	if resp.FinishReason != assistant.FinishReasonToolCalls {
+		t.Errorf("expected finish reason 'tool-calls', got '%s'", resp.FinishReason)
+	}
+	if resp.Usage == nil || resp.Usage.TotalTokenCount != 20 {
+		t.Errorf("unexpected usage metadata: %+v", resp.Usage)
+	}
+}
+
+func TestChatStreamEvents_AnthropicRequest(t *testing.T) {
+	var header http.Header
+	var body struct {
+		Model       string   `json:"model"`
+		MaxTokens   int      `json:"max_tokens"`
+		Temperature float32  `json:"temperature"`
+		Stop        []string `json:"stop_sequences"`
+		Stream      bool     `json:"stream"`
+		System      []struct {
+			Text         string            `json:"text"`
+			CacheControl map[string]string `json:"cache_control"`
+		} `json:"system"`
+		Messages []struct {
+			Role    string           `json:"role"`
+			Content []map[string]any `json:"content"`
+		} `json:"messages"`
+		Tools []struct {
+			Name        string         `json:"name"`
+			InputSchema map[string]any `json:"input_schema"`
+		} `json:"tools"`
+		ToolChoice map[string]string `json:"tool_choice"`
+	}
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		header = r.Header
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		streamEvents(helloWorld...)(w, r)
+	}, anthropic.WithBeta("interleaved-thinking-2025-05-14"), anthropic.WithPromptCaching())
+
+	events, err := client.ChatStreamEvents(context.Background(), []assistant.Message{
+		{Role: assistant.RoleSystem, Content: "Be brief."},
+		{Role: assistant.RoleUser, Content: "What's the weather in Paris?"},
+		{Role: assistant.RoleAssistant, ToolCalls: []assistant.ToolCall{{
+			ID:       "toolu_1",
+			Type:     "function",
+			Function: assistant.FunctionCall{Name: "get_weather", Arguments: `{"location":"Paris"}`},
+		}}},
+		{Role: assistant.RoleTool, ToolCallID: "toolu_1", Content: "Sunny, 22C"},
+	}, []assistant.Tool{weatherTool}, assistant.ToolChoiceRequired,
+		assistant.WithModel("claude-other"),
+		assistant.WithMaxTokens(256),
+		assistant.WithStopSequences("END"),
+	)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for range events {
+	}
+
+	if header.Get("X-Api-Key") != "test-key" || header.Get("Anthropic-Version") == "" {
+		t.Errorf("missing authentication headers: %v", header)
+	}
+	if header.Get("Anthropic-Beta") != "interleaved-thinking-2025-05-14" {
+		t.Errorf("expected anthropic-beta header, got %q", header.Get("Anthropic-Beta"))
+	}
+
+	if body.Model != "claude-other" || body.MaxTokens != 256 || body.Temperature != 0.7 || !body.Stream {
+		t.Errorf("unexpected request parameters: %+v", body)
+	}
+	if len(body.Stop) != 1 || body.Stop[0] != "END" {
+		t.Errorf("expected stop sequences [END], got %v", body.Stop)
+	}
+	if len(body.System) != 1 || body.System[0].Text != "Be brief." || body.System[0].CacheControl["type"] != "ephemeral" {
+		t.Errorf("expected a cacheable system prompt, got %+v", body.System)
+	}
+	if len(body.Tools) != 1 || body.Tools[0].Name != "get_weather" || body.Tools[0].InputSchema["type"] != "object" {
+		t.Errorf("unexpected tools: %+v", body.Tools)
+	}
+	if body.ToolChoice["type"] != "any" {
+		t.Errorf("expected tool choice 'any', got %v", body.ToolChoice)
+	}
+
+	if len(body.Messages) != 3 {
+		t.Fatalf("expected 3 messages, got %d", len(body.Messages))
+	}
+	call := body.Messages[1]
+	if call.Role != "assistant" || len(call.Content) != 1 || call.Content[0]["type"] != "tool_use" || call.Content[0]["id"] != "toolu_1" {
+		t.Errorf("expected a tool_use-only assistant turn, got %+v", call)
+	}
+	result := body.Messages[2]
+	if result.Role != "user" || result.Content[0]["type"] != "tool_result" || result.Content[0]["tool_use_id"] != "toolu_1" {
+		t.Errorf("expected a tool_result user turn, got %+v", result)
+	}
+}
+
+var thinkingCall = []string{
+	`{"type":"message_start","message":{"id":"msg_3","type":"message","role":"assistant","content":[],"usage":{"input_tokens":10,"output_tokens":1}}}`,
+	`{"type":"content_block_start","index":0,"content_block":{"type":"thinking","thinking":""}}`,
+	`{"type":"content_block_delta","index":0,"delta":{"type":"thinking_delta","thinking":"The user wants "}}`,
+	`{"type":"content_block_delta","index":0,"delta":{"type":"thinking_delta","thinking":"the weather."}}`,
+	`{"type":"content_block_delta","index":0,"delta":{"type":"signature_delta","signature":"sig-1"}}`,
+	`{"type":"content_block_stop","index":0}`,
+	`{"type":"content_block_start","index":1,"content_block":{"type":"redacted_thinking","data":"opaque"}}`,
+	`{"type":"content_block_stop","index":1}`,
+	`{"type":"content_block_start","index":2,"content_block":{"type":"tool_use","id":"toolu_1","name":"get_weather","input":{}}}`,
+	`{"type":"content_block_delta","index":2,"delta":{"type":"input_json_delta","partial_json":"{\"location\":\"Paris\"}"}}`,
+	`{"type":"content_block_stop","index":2}`,
+	`{"type":"message_delta","delta":{"stop_reason":"tool_use"},"usage":{"output_tokens":30}}`,
+	`{"type":"message_stop"}`,
+}
+
+func TestChatStreamEvents_AnthropicThinking(t *testing.T) {
+	var bodies []map[string]any
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]any
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		bodies = append(bodies, body)
+		streamEvents(thinkingCall...)(w, r)
+	}, anthropic.WithThinking(8000))
+
+	messages := []assistant.Message{{Role: assistant.RoleUser, Content: "What's the weather in Paris?"}}
+	events, err := client.ChatStreamEvents(context.Background(), messages, []assistant.Tool{weatherTool}, assistant.ToolChoiceAuto)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	resp, err := assistant.Collect(events)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := []assistant.ReasoningBlock{{Text: "The user wants the weather.", Signature: "sig-1"}, {Redacted: "opaque"}}
+	if len(resp.Message.Reasoning) != 2 || resp.Message.Reasoning[0] != want[0] || resp.Message.Reasoning[1] != want[1] {
+		t.Errorf("expected reasoning %+v, got %+v", want, resp.Message.Reasoning)
+	}
+	if len(resp.ToolCalls) != 1 || resp.ToolCalls[0].ID != "toolu_1" {
+		t.Errorf("unexpected tool calls: %+v", resp.ToolCalls)
+	}
+
+	first := bodies[0]
+	if th, _ := first["thinking"].(map[string]any); th["type"] != "enabled" || th["budget_tokens"] != float64(8000) {
+		t.Errorf("expected thinking with an 8000 token budget, got %v", first["thinking"])
+	}
+	if _, ok := first["temperature"]; ok {
+		t.Errorf("temperature must not be sent while thinking, got %v", first["temperature"])
+	}
+	if first["max_tokens"].(float64) <= 8000 {
+		t.Errorf("expected max_tokens above the budget, got %v", first["max_tokens"])
+	}
+
+	// The thinking blocks go back first in the assistant turn with the tool result.
+	messages = append(messages, resp.Message, assistant.Message{Role: assistant.RoleTool, ToolCallID: "toolu_1", Content: "Sunny"})
+	events, err = client.ChatStreamEvents(context.Background(), messages, []assistant.Tool{weatherTool}, assistant.ToolChoiceAuto)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for range events {
+	}
+	turn := bodies[1]["messages"].([]any)[1].(map[string]any)["content"].([]any)
+	if len(turn) != 3 {
+		t.Fatalf("expected thinking, redacted thinking and tool use blocks, got %v", turn)
+	}
+	thinking, redacted := turn[0].(map[string]any), turn[1].(map[string]any)
+	if thinking["type"] != "thinking" || thinking["thinking"] != "The user wants the weather." || thinking["signature"] != "sig-1" {
+		t.Errorf("unexpected thinking block: %v", thinking)
+	}
+	if redacted["type"] != "redacted_thinking" || redacted["data"] != "opaque" {
+		t.Errorf("unexpected redacted thinking block: %v", redacted)
+	}
+
+	if _, err := client.ChatStreamEvents(context.Background(), messages, nil, "", assistant.WithMaxTokens(1000)); err == nil {
+		t.Error("expected an error for max tokens below the thinking budget")
+	}
+}
+
+func TestChatStreamEvents_AnthropicTemperatureAndTopP(t *testing.T) {
+	var body map[string]any
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		body = nil
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		streamEvents(helloWorld...)(w, r)
+	})
+	hello := []assistant.Message{{Role: assistant.RoleUser, Content: "Hello"}}
+
+	for _, tc := range []struct {
+		name        string
+		opts        []assistant.GenerationOption
+		temperature any
+		topP        any
+	}{
+		{"default", nil, 0.7, nil},
+		{"top_p replaces the default temperature", []assistant.GenerationOption{assistant.WithTopP(0.9)}, nil, 0.9},
+		{"explicit temperature", []assistant.GenerationOption{assistant.WithTemperature(0)}, 0.0, nil},
+	} {
+		events, err := client.ChatStreamEvents(context.Background(), hello, nil, "", tc.opts...)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", tc.name, err)
+		}
+		for range events {
+		}
+		if !approx(body["temperature"], tc.temperature) || !approx(body["top_p"], tc.topP) {
+			t.Errorf("%s: temperature = %v, top_p = %v; want %v, %v", tc.name, body["temperature"], body["top_p"], tc.temperature, tc.topP)
+		}
+	}
+}
+
+// approx compares a decoded JSON number with want, allowing for float32 rounding.
+func approx(got, want any) bool {
+	if want == nil || got == nil {
+		return got == want
+	}
+	g, ok := got.(float64)
+	d := g - want.(float64)
+	return ok && d < 1e-6 && d > -1e-6
+}
+
+func TestChatStreamEvents_AnthropicEmptyUserMessage(t *testing.T) {
+	client := newTestClient(t, streamEvents(helloWorld...))
+	_, err := client.ChatStreamEvents(context.Background(), []assistant.Message{{Role: assistant.RoleUser}}, nil, "")
+	if err == nil || !strings.Contains(err.Error(), "no content") {
+		t.Errorf("expected an error for an empty user message, got %v", err)
+	}
+}
+
+func TestChatStreamEvents_AnthropicAPIKeyProvider(t *testing.T) {
+	var keys []string
+	calls := 0
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		keys = append(keys, r.Header.Get("X-Api-Key"))
+		streamEvents(helloWorld...)(w, r)
+	}, anthropic.WithAPIKeyProvider(func(context.Context) (string, error) {
+		calls++
+		return fmt.Sprintf("rotated-%d", calls), nil
+	}))
+
+	for range 2 {
+		events, err := client.ChatStreamEvents(context.Background(), []assistant.Message{
+			{Role: assistant.RoleUser, Content: "Hello"},
+		}, nil, "")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if _, err := assistant.Collect(events); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	// The key is fetched for every request in place of the static one.
+	if len(keys) != 2 || keys[0] != "rotated-1" || keys[1] != "rotated-2" {
+		t.Errorf("unexpected API keys sent: %v", keys)
+	}
+
+	failing := newTestClient(t, streamEvents(helloWorld...), anthropic.WithAPIKeyProvider(func(context.Context) (string, error) {
+		return "", errors.New("vault sealed")
+	}))
+	if _, err := failing.ChatStreamEvents(context.Background(), []assistant.Message{
+		{Role: assistant.RoleUser, Content: "Hello"},
+	}, nil, ""); err == nil || !strings.Contains(err.Error(), "vault sealed") {
+		t.Errorf("expected the key error to be reported, got %v", err)
+	}
+}
+
+func TestChatStreamEvents_AnthropicUnsupportedSeed(t *testing.T) {
+	client := newTestClient(t, streamEvents(helloWorld...))
+
+	_, err := client.ChatStreamEvents(context.Background(), []assistant.Message{
+		{Role: assistant.RoleUser, Content: "Say something"},
+	}, nil, assistant.ToolChoiceAuto, assistant.WithSeed(1))
+
+	var unsupported *assistant.UnsupportedOptionError
+	if !errors.As(err, &unsupported) || unsupported.Option != "seed" {
+		t.Fatalf("expected UnsupportedOptionError for seed, got %v", err)
+	}
+}
+
+func TestChatStreamWithToolsAndUsage_NoMessages(t *testing.T) {
+	client := newTestClient(t, streamEvents(helloWorld...))
+
+	_, err := client.ChatStreamWithToolsAndUsage(context.Background(), []assistant.Message{}, nil, assistant.ToolChoiceAuto)
+	if err == nil {
+		t.Fatal("expected error for empty messages")
+	}
+}
+
+func TestChatStreamEvents_AbandonedReader(t *testing.T) {
+	released := make(chan struct{})
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		// Start the stream, then hold it open until the client gives up.
+		_, _ = io.Copy(io.Discard, r.Body)
+		streamEvents(helloWorld[:4]...)(w, r)
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+		close(released)
+	})
+	baseline := runtime.NumGoroutine()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	events, err := client.ChatStreamEvents(ctx, []assistant.Message{
+		{Role: assistant.RoleUser, Content: "Say something"},
+	}, nil, assistant.ToolChoiceAuto)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if ev := <-events; ev.Type != assistant.EventTextDelta {
+		t.Fatalf("expected a text delta, got %+v", ev)
+	}
+	cancel()
+
+	select {
+	case <-released:
+	case <-time.After(2 * time.Second):
+		t.Fatal("expected the HTTP request to be released after cancellation")
+	}
+	testutil.WaitForGoroutines(t, baseline)
+}
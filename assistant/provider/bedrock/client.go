@@ -5,8 +5,6 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
-	"log"
-	"sync"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/config"
@@ -16,9 +14,32 @@ import (
 	"github.com/sburchfield/go-assistant-api/assistant"
 )
 
+// BedrockClient defines the subset of the Bedrock Runtime SDK used by our code.
+type BedrockClient interface {
+	ConverseStream(ctx context.Context, input *bedrockruntime.ConverseStreamInput) (bedrockruntime.ConverseStreamOutputReader, error)
+	Converse(ctx context.Context, input *bedrockruntime.ConverseInput) (*bedrockruntime.ConverseOutput, error)
+}
+
+// sdkWrapper wraps the actual Bedrock Runtime client to match our BedrockClient interface.
+type sdkWrapper struct {
+	inner *bedrockruntime.Client
+}
+
+func (s *sdkWrapper) ConverseStream(ctx context.Context, input *bedrockruntime.ConverseStreamInput) (bedrockruntime.ConverseStreamOutputReader, error) {
+	output, err := s.inner.ConverseStream(ctx, input)
+	if err != nil {
+		return nil, err
+	}
+	return output.GetStream(), nil
+}
+
+func (s *sdkWrapper) Converse(ctx context.Context, input *bedrockruntime.ConverseInput) (*bedrockruntime.ConverseOutput, error) {
+	return s.inner.Converse(ctx, input)
+}
+
 // Client wraps the AWS Bedrock Runtime client for chat completions.
 type Client struct {
-	client      *bedrockruntime.Client
+	sdk         BedrockClient
 	modelID     string
 	temperature float32
 }
@@ -30,19 +51,18 @@ func NewClient(ctx context.Context, region, modelID string, temperature float32)
 		return nil, fmt.Errorf("failed to load AWS config: %w", err)
 	}
 
-	client := bedrockruntime.NewFromConfig(cfg)
-
-	return &Client{
-		client:      client,
-		modelID:     modelID,
-		temperature: temperature,
-	}, nil
+	return NewClientWithConfig(cfg, modelID, temperature), nil
 }
 
 // NewClientWithConfig creates a new Bedrock client with a pre-configured AWS config.
 func NewClientWithConfig(cfg aws.Config, modelID string, temperature float32) *Client {
+	return NewClientWithSDK(&sdkWrapper{inner: bedrockruntime.NewFromConfig(cfg)}, modelID, temperature)
+}
+
+// NewClientWithSDK creates a new Bedrock client backed by the given SDK implementation.
+func NewClientWithSDK(sdk BedrockClient, modelID string, temperature float32) *Client {
 	return &Client{
-		client:      bedrockruntime.NewFromConfig(cfg),
+		sdk:         sdk,
 		modelID:     modelID,
 		temperature: temperature,
 	}
@@ -59,64 +79,137 @@ func (c *Client) ChatStreamWithTools(
 	messages []assistant.Message,
 	tools []assistant.Tool,
 	toolChoice assistant.ToolChoice,
+	opts ...assistant.GenerationOption,
 ) (<-chan string, error) {
-	if len(messages) == 0 {
-		return nil, errors.New("ChatStream: no messages provided")
+	events, err := c.ChatStreamEvents(ctx, messages, tools, toolChoice, opts...)
+	if err != nil {
+		return nil, err
 	}
+	return assistant.TextWithToolCalls(ctx, events), nil
+}
 
-	converseMessages, systemPrompts := c.convertMessages(messages)
-
-	input := &bedrockruntime.ConverseStreamInput{
-		ModelId:  aws.String(c.modelID),
-		Messages: converseMessages,
-		InferenceConfig: &types.InferenceConfiguration{
-			Temperature: aws.Float32(c.temperature),
-		},
-	}
+// ChatStreamWithUsage streams chat completions and provides usage metadata.
+func (c *Client) ChatStreamWithUsage(ctx context.Context, messages []assistant.Message) (*assistant.StreamResult, error) {
+	return c.ChatStreamWithToolsAndUsage(ctx, messages, nil, assistant.ToolChoiceAuto)
+}
 
-	// Add system prompts if present
-	if len(systemPrompts) > 0 {
-		input.System = systemPrompts
+// ChatStreamWithToolsAndUsage streams chat completions with tools and provides usage metadata.
+func (c *Client) ChatStreamWithToolsAndUsage(
+	ctx context.Context,
+	messages []assistant.Message,
+	tools []assistant.Tool,
+	toolChoice assistant.ToolChoice,
+	opts ...assistant.GenerationOption,
+) (*assistant.StreamResult, error) {
+	events, err := c.ChatStreamEvents(ctx, messages, tools, toolChoice, opts...)
+	if err != nil {
+		return nil, err
 	}
+	return assistant.NewStreamResult(ctx, events), nil
+}
 
-	// Add tools if provided
-	if len(tools) > 0 {
-		input.ToolConfig = c.convertToolConfig(tools, toolChoice)
+// ChatStreamEvents streams chat completions as typed events.
+func (c *Client) ChatStreamEvents(
+	ctx context.Context,
+	messages []assistant.Message,
+	tools []assistant.Tool,
+	toolChoice assistant.ToolChoice,
+	opts ...assistant.GenerationOption,
+) (<-chan assistant.Event, error) {
+	input, err := c.buildInput(messages, tools, toolChoice, assistant.NewGenerationOptions(opts...))
+	if err != nil {
+		return nil, err
 	}
 
-	output, err := c.client.ConverseStream(ctx, input)
+	stream, err := c.sdk.ConverseStream(ctx, &bedrockruntime.ConverseStreamInput{
+		ModelId:         input.ModelId,
+		Messages:        input.Messages,
+		System:          input.System,
+		InferenceConfig: input.InferenceConfig,
+		ToolConfig:      input.ToolConfig,
+	})
 	if err != nil {
 		return nil, fmt.Errorf("failed to start converse stream: %w", err)
 	}
 
-	out := make(chan string)
+	out := make(chan assistant.Event)
 	go func() {
 		defer close(out)
-		c.processStream(output, out)
+		c.processStream(ctx, stream, out)
 	}()
 
 	return out, nil
 }
 
-// ChatStreamWithUsage streams chat completions and provides usage metadata.
-func (c *Client) ChatStreamWithUsage(ctx context.Context, messages []assistant.Message) (*assistant.StreamResult, error) {
-	return c.ChatStreamWithToolsAndUsage(ctx, messages, nil, assistant.ToolChoiceAuto)
+// Chat returns the complete response using the non-streaming Converse API.
+func (c *Client) Chat(ctx context.Context, messages []assistant.Message, opts assistant.ChatOptions) (*assistant.Response, error) {
+	input, err := c.buildInput(messages, opts.Tools, opts.ToolChoice, opts.GenerationOptions)
+	if err != nil {
+		return nil, err
+	}
+
+	output, err := c.sdk.Converse(ctx, input)
+	if err != nil {
+		return nil, fmt.Errorf("failed to converse: %w", err)
+	}
+
+	msg := assistant.Message{Role: assistant.RoleAssistant}
+	if out, ok := output.Output.(*types.ConverseOutputMemberMessage); ok {
+		for _, block := range out.Value.Content {
+			switch b := block.(type) {
+			case *types.ContentBlockMemberText:
+				msg.Content += b.Value
+			case *types.ContentBlockMemberToolUse:
+				args := []byte("{}")
+				if b.Value.Input != nil {
+					if args, err = b.Value.Input.MarshalSmithyDocument(); err != nil {
+						return nil, fmt.Errorf("failed to decode tool input: %w", err)
+					}
+				}
+				msg.ToolCalls = append(msg.ToolCalls, assistant.ToolCall{
+					ID:   aws.ToString(b.Value.ToolUseId),
+					Type: "function",
+					Function: assistant.FunctionCall{
+						Name:      aws.ToString(b.Value.Name),
+						Arguments: string(args),
+					},
+				})
+			}
+		}
+	}
+
+	resp := &assistant.Response{
+		Message:      msg,
+		ToolCalls:    msg.ToolCalls,
+		FinishReason: finishReason(output.StopReason),
+	}
+	if output.Usage != nil {
+		resp.Usage = &assistant.UsageMetadata{
+			PromptTokenCount:     aws.ToInt32(output.Usage.InputTokens),
+			CandidatesTokenCount: aws.ToInt32(output.Usage.OutputTokens),
+			TotalTokenCount:      aws.ToInt32(output.Usage.TotalTokens),
+		}
+	}
+	return resp, nil
 }
 
-// ChatStreamWithToolsAndUsage streams chat completions with tools and provides usage metadata.
-func (c *Client) ChatStreamWithToolsAndUsage(
-	ctx context.Context,
+// buildInput converts messages, tools and generation options into a Converse request.
+func (c *Client) buildInput(
 	messages []assistant.Message,
 	tools []assistant.Tool,
 	toolChoice assistant.ToolChoice,
-) (*assistant.StreamResult, error) {
+	gen assistant.GenerationOptions,
+) (*bedrockruntime.ConverseInput, error) {
 	if len(messages) == 0 {
 		return nil, errors.New("ChatStream: no messages provided")
 	}
+	if gen.Seed != nil {
+		return nil, &assistant.UnsupportedOptionError{Provider: "bedrock", Option: "seed"}
+	}
 
 	converseMessages, systemPrompts := c.convertMessages(messages)
 
-	input := &bedrockruntime.ConverseStreamInput{
+	input := &bedrockruntime.ConverseInput{
 		ModelId:  aws.String(c.modelID),
 		Messages: converseMessages,
 		InferenceConfig: &types.InferenceConfiguration{
@@ -124,6 +217,23 @@ func (c *Client) ChatStreamWithToolsAndUsage(
 		},
 	}
 
+	// Apply per-request generation options
+	if gen.Model != "" {
+		input.ModelId = aws.String(gen.Model)
+	}
+	if gen.Temperature != nil {
+		input.InferenceConfig.Temperature = gen.Temperature
+	}
+	if gen.MaxTokens != nil {
+		input.InferenceConfig.MaxTokens = aws.Int32(int32(*gen.MaxTokens))
+	}
+	if gen.TopP != nil {
+		input.InferenceConfig.TopP = gen.TopP
+	}
+	if len(gen.StopSequences) > 0 {
+		input.InferenceConfig.StopSequences = gen.StopSequences
+	}
+
 	// Add system prompts if present
 	if len(systemPrompts) > 0 {
 		input.System = systemPrompts
@@ -134,28 +244,7 @@ func (c *Client) ChatStreamWithToolsAndUsage(
 		input.ToolConfig = c.convertToolConfig(tools, toolChoice)
 	}
 
-	output, err := c.client.ConverseStream(ctx, input)
-	if err != nil {
-		return nil, fmt.Errorf("failed to start converse stream: %w", err)
-	}
-
-	out := make(chan string)
-	var usageMetadata *assistant.UsageMetadata
-	var usageMu sync.Mutex
-
-	go func() {
-		defer close(out)
-		c.processStreamWithUsage(output, out, &usageMetadata, &usageMu)
-	}()
-
-	return &assistant.StreamResult{
-		TextChannel: out,
-		GetUsage: func() *assistant.UsageMetadata {
-			usageMu.Lock()
-			defer usageMu.Unlock()
-			return usageMetadata
-		},
-	}, nil
+	return input, nil
 }
 
 // convertMessages converts assistant.Message to Bedrock Converse format.
@@ -253,95 +342,104 @@ func (c *Client) convertToolConfig(tools []assistant.Tool, toolChoice assistant.
 	return config
 }
 
-// processStream handles the streaming response and sends text to the output channel.
-func (c *Client) processStream(output *bedrockruntime.ConverseStreamOutput, out chan<- string) {
-	stream := output.GetStream()
+// processStream translates Bedrock stream events into assistant events until
+// the stream ends or ctx is done.
+func (c *Client) processStream(ctx context.Context, stream bedrockruntime.ConverseStreamOutputReader, out chan<- assistant.Event) {
+	// Closing the reader releases the underlying HTTP connection.
 	defer stream.Close()
 
-	for event := range stream.Events() {
-		switch v := event.(type) {
-		case *types.ConverseStreamOutputMemberContentBlockDelta:
-			if delta, ok := v.Value.Delta.(*types.ContentBlockDeltaMemberText); ok {
-				out <- delta.Value
-			}
-			// Handle tool use deltas
-			if toolDelta, ok := v.Value.Delta.(*types.ContentBlockDeltaMemberToolUse); ok {
-				// Send partial tool use input as it streams
-				if toolDelta.Value.Input != nil {
-					out <- *toolDelta.Value.Input
-				}
-			}
-		case *types.ConverseStreamOutputMemberContentBlockStart:
-			// Handle tool use start
-			if toolStart, ok := v.Value.Start.(*types.ContentBlockStartMemberToolUse); ok {
-				toolCallJSON, _ := json.Marshal(map[string]interface{}{
-					"type": "tool_call_start",
-					"id":   aws.ToString(toolStart.Value.ToolUseId),
-					"function": map[string]string{
-						"name": aws.ToString(toolStart.Value.Name),
-					},
-				})
-				out <- string(toolCallJSON)
+	// Bedrock numbers content blocks across text and tool use, so map the
+	// tool use blocks onto their position among the tool calls.
+	toolCalls := map[int32]*assistant.ToolCallDelta{}
+
+	events := stream.Events()
+	for {
+		var event types.ConverseStreamOutput
+		var ok bool
+		select {
+		case event, ok = <-events:
+		case <-ctx.Done():
+			return
+		}
+		if !ok {
+			break
+		}
+
+		if ev, emit := translateEvent(event, toolCalls); emit {
+			if !assistant.SendEvent(ctx, out, ev) {
+				return
 			}
-		case *types.ConverseStreamOutputMemberMessageStop:
-			// Message complete
 		}
 	}
 
 	if err := stream.Err(); err != nil {
-		log.Printf("stream error: %v", err)
+		assistant.SendEvent(ctx, out, assistant.Event{Type: assistant.EventError, Err: fmt.Errorf("stream error: %w", err)})
 	}
 }
 
-// processStreamWithUsage handles streaming and captures usage metadata.
-func (c *Client) processStreamWithUsage(
-	output *bedrockruntime.ConverseStreamOutput,
-	out chan<- string,
-	usageMetadata **assistant.UsageMetadata,
-	usageMu *sync.Mutex,
-) {
-	stream := output.GetStream()
-	defer stream.Close()
-
-	for event := range stream.Events() {
-		switch v := event.(type) {
-		case *types.ConverseStreamOutputMemberContentBlockDelta:
-			if delta, ok := v.Value.Delta.(*types.ContentBlockDeltaMemberText); ok {
-				out <- delta.Value
-			}
-			if toolDelta, ok := v.Value.Delta.(*types.ContentBlockDeltaMemberToolUse); ok {
-				if toolDelta.Value.Input != nil {
-					out <- *toolDelta.Value.Input
-				}
+// translateEvent converts a single Bedrock stream event. It reports false for
+// events that have no assistant counterpart.
+func translateEvent(event types.ConverseStreamOutput, toolCalls map[int32]*assistant.ToolCallDelta) (assistant.Event, bool) {
+	switch v := event.(type) {
+	case *types.ConverseStreamOutputMemberContentBlockStart:
+		if toolStart, ok := v.Value.Start.(*types.ContentBlockStartMemberToolUse); ok {
+			tc := &assistant.ToolCallDelta{
+				Index: len(toolCalls),
+				ID:    aws.ToString(toolStart.Value.ToolUseId),
+				Name:  aws.ToString(toolStart.Value.Name),
 			}
-		case *types.ConverseStreamOutputMemberContentBlockStart:
-			if toolStart, ok := v.Value.Start.(*types.ContentBlockStartMemberToolUse); ok {
-				toolCallJSON, _ := json.Marshal(map[string]interface{}{
-					"type": "tool_call_start",
-					"id":   aws.ToString(toolStart.Value.ToolUseId),
-					"function": map[string]string{
-						"name": aws.ToString(toolStart.Value.Name),
-					},
-				})
-				out <- string(toolCallJSON)
+			toolCalls[aws.ToInt32(v.Value.ContentBlockIndex)] = tc
+			return assistant.Event{Type: assistant.EventToolCallStart, ToolCall: tc}, true
+		}
+	case *types.ConverseStreamOutputMemberContentBlockDelta:
+		switch delta := v.Value.Delta.(type) {
+		case *types.ContentBlockDeltaMemberText:
+			return assistant.Event{Type: assistant.EventTextDelta, Text: delta.Value}, true
+		case *types.ContentBlockDeltaMemberToolUse:
+			tc, ok := toolCalls[aws.ToInt32(v.Value.ContentBlockIndex)]
+			if ok && delta.Value.Input != nil {
+				return assistant.Event{
+					Type:     assistant.EventToolCallDelta,
+					ToolCall: &assistant.ToolCallDelta{Index: tc.Index, ID: tc.ID, Arguments: *delta.Value.Input},
+				}, true
 			}
-		case *types.ConverseStreamOutputMemberMetadata:
-			// Capture usage metadata
-			if v.Value.Usage != nil {
-				usageMu.Lock()
-				*usageMetadata = &assistant.UsageMetadata{
+		}
+	case *types.ConverseStreamOutputMemberContentBlockStop:
+		if tc, ok := toolCalls[aws.ToInt32(v.Value.ContentBlockIndex)]; ok {
+			return assistant.Event{
+				Type:     assistant.EventToolCallEnd,
+				ToolCall: &assistant.ToolCallDelta{Index: tc.Index, ID: tc.ID},
+			}, true
+		}
+	case *types.ConverseStreamOutputMemberMessageStop:
+		return assistant.Event{Type: assistant.EventFinish, FinishReason: finishReason(v.Value.StopReason)}, true
+	case *types.ConverseStreamOutputMemberMetadata:
+		if v.Value.Usage != nil {
+			return assistant.Event{
+				Type: assistant.EventUsage,
+				Usage: &assistant.UsageMetadata{
 					PromptTokenCount:     aws.ToInt32(v.Value.Usage.InputTokens),
 					CandidatesTokenCount: aws.ToInt32(v.Value.Usage.OutputTokens),
 					TotalTokenCount:      aws.ToInt32(v.Value.Usage.TotalTokens),
-				}
-				usageMu.Unlock()
-			}
-		case *types.ConverseStreamOutputMemberMessageStop:
-			// Message complete
+				},
+			}, true
 		}
 	}
+	return assistant.Event{}, false
+}
 
-	if err := stream.Err(); err != nil {
-		log.Printf("stream error: %v", err)
+// finishReason normalizes a Bedrock stop reason.
+func finishReason(reason types.StopReason) assistant.FinishReason {
+	switch reason {
+	case types.StopReasonMaxTokens, types.StopReasonModelContextWindowExceeded:
+		return assistant.FinishReasonLength
+	case types.StopReasonToolUse:
+		return assistant.FinishReasonToolCalls
+	case types.StopReasonGuardrailIntervened, types.StopReasonContentFiltered:
+		return assistant.FinishReasonContentFilter
+	case types.StopReasonMalformedModelOutput, types.StopReasonMalformedToolUse:
+		return assistant.FinishReasonError
+	default:
+		return assistant.FinishReasonStop
 	}
 }
@@ -2,13 +2,98 @@ package bedrock_test
 
 import (
 	"context"
+	"errors"
+	"runtime"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime/document"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime/types"
 	"github.com/sburchfield/go-assistant-api/assistant"
 	"github.com/sburchfield/go-assistant-api/assistant/provider/bedrock"
+	"github.com/sburchfield/go-assistant-api/internal/testutil"
 )
 
+type mockReader struct {
+	events chan types.ConverseStreamOutput
+	err    error
+	closed bool
+}
+
+func newMockReader(events ...types.ConverseStreamOutput) *mockReader {
+	ch := make(chan types.ConverseStreamOutput, len(events))
+	for _, ev := range events {
+		ch <- ev
+	}
+	close(ch)
+	return &mockReader{events: ch}
+}
+
+func (m *mockReader) Events() <-chan types.ConverseStreamOutput { return m.events }
+func (m *mockReader) Err() error                                { return m.err }
+func (m *mockReader) Close() error {
+	m.closed = true
+	return nil
+}
+
+// openReader has buffered events but never ends on its own.
+type openReader struct {
+	events    chan types.ConverseStreamOutput
+	closeOnce sync.Once
+	closed    chan struct{}
+}
+
+func newOpenReader(n int) *openReader {
+	r := &openReader{
+		events: make(chan types.ConverseStreamOutput, n),
+		closed: make(chan struct{}),
+	}
+	for i := 0; i < n; i++ {
+		r.events <- &types.ConverseStreamOutputMemberContentBlockDelta{Value: types.ContentBlockDeltaEvent{
+			ContentBlockIndex: aws.Int32(0),
+			Delta:             &types.ContentBlockDeltaMemberText{Value: "more"},
+		}}
+	}
+	return r
+}
+
+func (r *openReader) Events() <-chan types.ConverseStreamOutput { return r.events }
+func (r *openReader) Err() error                                { return nil }
+func (r *openReader) Close() error {
+	r.closeOnce.Do(func() { close(r.closed) })
+	return nil
+}
+
+type openBedrockClient struct {
+	reader *openReader
+}
+
+func (m *openBedrockClient) ConverseStream(ctx context.Context, input *bedrockruntime.ConverseStreamInput) (bedrockruntime.ConverseStreamOutputReader, error) {
+	return m.reader, nil
+}
+
+func (m *openBedrockClient) Converse(ctx context.Context, input *bedrockruntime.ConverseInput) (*bedrockruntime.ConverseOutput, error) {
+	return nil, errors.New("not implemented")
+}
+
+type mockBedrockClient struct {
+	reader *mockReader
+	output *bedrockruntime.ConverseOutput
+	input  *bedrockruntime.ConverseStreamInput
+}
+
+func (m *mockBedrockClient) ConverseStream(ctx context.Context, input *bedrockruntime.ConverseStreamInput) (bedrockruntime.ConverseStreamOutputReader, error) {
+	m.input = input
+	return m.reader, nil
+}
+
+func (m *mockBedrockClient) Converse(ctx context.Context, input *bedrockruntime.ConverseInput) (*bedrockruntime.ConverseOutput, error) {
+	return m.output, nil
+}
+
 func TestNewClientWithConfig(t *testing.T) {
 	cfg := aws.Config{
 		Region: "us-east-1",
@@ -136,3 +221,220 @@ func TestToolChoiceOptions(t *testing.T) {
 		})
 	}
 }
+
+func TestChatStreamEvents(t *testing.T) {
+	reader := newMockReader(
+		&types.ConverseStreamOutputMemberContentBlockDelta{Value: types.ContentBlockDeltaEvent{
+			ContentBlockIndex: aws.Int32(0),
+			Delta:             &types.ContentBlockDeltaMemberText{Value: "Let me check."},
+		}},
+		&types.ConverseStreamOutputMemberContentBlockStop{Value: types.ContentBlockStopEvent{ContentBlockIndex: aws.Int32(0)}},
+		&types.ConverseStreamOutputMemberContentBlockStart{Value: types.ContentBlockStartEvent{
+			ContentBlockIndex: aws.Int32(1),
+			Start: &types.ContentBlockStartMemberToolUse{Value: types.ToolUseBlockStart{
+				ToolUseId: aws.String("tooluse_1"),
+				Name:      aws.String("get_weather"),
+			}},
+		}},
+		&types.ConverseStreamOutputMemberContentBlockDelta{Value: types.ContentBlockDeltaEvent{
+			ContentBlockIndex: aws.Int32(1),
+			Delta:             &types.ContentBlockDeltaMemberToolUse{Value: types.ToolUseBlockDelta{Input: aws.String(`{"location":"Paris"}`)}},
+		}},
+		&types.ConverseStreamOutputMemberContentBlockStop{Value: types.ContentBlockStopEvent{ContentBlockIndex: aws.Int32(1)}},
+		&types.ConverseStreamOutputMemberMessageStop{Value: types.MessageStopEvent{StopReason: types.StopReasonToolUse}},
+		&types.ConverseStreamOutputMemberMetadata{Value: types.ConverseStreamMetadataEvent{
+			Usage: &types.TokenUsage{InputTokens: aws.Int32(10), OutputTokens: aws.Int32(5), TotalTokens: aws.Int32(15)},
+		}},
+	)
+	client := bedrock.NewClientWithSDK(&mockBedrockClient{reader: reader}, "anthropic.claude-3-sonnet-20240229-v1:0", 0.7)
+
+	events, err := client.ChatStreamEvents(context.Background(), []assistant.Message{
+		{Role: assistant.RoleUser, Content: "Weather in Paris?"},
+	}, nil, assistant.ToolChoiceAuto)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var got []assistant.EventType
+	for ev := range events {
+		got = append(got, ev.Type)
+		switch ev.Type {
+		case assistant.EventToolCallStart:
+			if ev.ToolCall.Index != 0 || ev.ToolCall.ID != "tooluse_1" || ev.ToolCall.Name != "get_weather" {
+				t.Errorf("unexpected tool call start: %+v", ev.ToolCall)
+			}
+		case assistant.EventToolCallDelta:
+			if ev.ToolCall.Arguments != `{"location":"Paris"}` {
+				t.Errorf("unexpected tool call arguments: %s", ev.ToolCall.Arguments)
+			}
+		case assistant.EventFinish:
+			if ev.FinishReason != assistant.FinishReasonToolCalls {
+				t.Errorf("expected finish reason 'tool-calls', got '%s'", ev.FinishReason)
+			}
+		case assistant.EventUsage:
+			if ev.Usage.TotalTokenCount != 15 {
+				t.Errorf("expected 15 total tokens, got %d", ev.Usage.TotalTokenCount)
+			}
+		}
+	}
+
+	expected := []assistant.EventType{
+		assistant.EventTextDelta,
+		assistant.EventToolCallStart,
+		assistant.EventToolCallDelta,
+		assistant.EventToolCallEnd,
+		assistant.EventFinish,
+		assistant.EventUsage,
+	}
+	if len(got) != len(expected) {
+		t.Fatalf("expected events %v, got %v", expected, got)
+	}
+	for i := range expected {
+		if got[i] != expected[i] {
+			t.Errorf("event %d: expected %s, got %s", i, expected[i], got[i])
+		}
+	}
+
+	if !reader.closed {
+		t.Error("expected event stream to be closed")
+	}
+}
+
+func TestChatStreamWithToolsAndUsage_StreamError(t *testing.T) {
+	reader := newMockReader(
+		&types.ConverseStreamOutputMemberContentBlockDelta{Value: types.ContentBlockDeltaEvent{
+			ContentBlockIndex: aws.Int32(0),
+			Delta:             &types.ContentBlockDeltaMemberText{Value: "Partial"},
+		}},
+	)
+	reader.err = errors.New("ThrottlingException: too many requests")
+	client := bedrock.NewClientWithSDK(&mockBedrockClient{reader: reader}, "anthropic.claude-3-sonnet-20240229-v1:0", 0.7)
+
+	result, err := client.ChatStreamWithToolsAndUsage(context.Background(), []assistant.Message{
+		{Role: assistant.RoleUser, Content: "Hello"},
+	}, nil, assistant.ToolChoiceAuto)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var text string
+	for msg := range result.TextChannel {
+		text += msg
+	}
+
+	if text != "Partial" {
+		t.Errorf("expected 'Partial', got '%s'", text)
+	}
+	if err := result.Err(); err == nil {
+		t.Fatal("expected stream error to be reported")
+	}
+}
+
+func TestChat(t *testing.T) {
+	output := &bedrockruntime.ConverseOutput{
+		Output: &types.ConverseOutputMemberMessage{Value: types.Message{
+			Role: types.ConversationRoleAssistant,
+			Content: []types.ContentBlock{
+				&types.ContentBlockMemberText{Value: "Let me check."},
+				&types.ContentBlockMemberToolUse{Value: types.ToolUseBlock{
+					ToolUseId: aws.String("tooluse_1"),
+					Name:      aws.String("get_weather"),
+					Input:     document.NewLazyDocument(map[string]interface{}{"location": "Paris"}),
+				}},
+			},
+		}},
+		StopReason: types.StopReasonToolUse,
+		Usage:      &types.TokenUsage{InputTokens: aws.Int32(10), OutputTokens: aws.Int32(5), TotalTokens: aws.Int32(15)},
+	}
+	client := bedrock.NewClientWithSDK(&mockBedrockClient{output: output}, "anthropic.claude-3-sonnet-20240229-v1:0", 0.7)
+
+	resp, err := client.Chat(context.Background(), []assistant.Message{
+		{Role: assistant.RoleUser, Content: "Weather in Paris?"},
+	}, assistant.ChatOptions{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if resp.Message.Content != "Let me check." {
+		t.Errorf("expected content 'Let me check.', got '%s'", resp.Message.Content)
+	}
+	if len(resp.ToolCalls) != 1 || resp.ToolCalls[0].ID != "tooluse_1" || resp.ToolCalls[0].Function.Arguments != `{"location":"Paris"}` {
+		t.Errorf("unexpected tool calls: %+v", resp.ToolCalls)
+	}
+	if resp.FinishReason != assistant.FinishReasonToolCalls {
+		t.Errorf("expected finish reason 'tool-calls', got '%s'", resp.FinishReason)
+	}
+	if resp.Usage == nil || resp.Usage.TotalTokenCount != 15 {
+		t.Errorf("unexpected usage metadata: %+v", resp.Usage)
+	}
+}
+
+func TestChatStreamEvents_GenerationOptions(t *testing.T) {
+	mock := &mockBedrockClient{reader: newMockReader()}
+	client := bedrock.NewClientWithSDK(mock, "anthropic.claude-3-sonnet-20240229-v1:0", 0.7)
+
+	events, err := client.ChatStreamEvents(context.Background(), []assistant.Message{
+		{Role: assistant.RoleUser, Content: "Hello"},
+	}, nil, assistant.ToolChoiceAuto,
+		assistant.WithModel("anthropic.claude-3-haiku-20240307-v1:0"),
+		assistant.WithMaxTokens(256),
+		assistant.WithTopP(0.5),
+		assistant.WithStopSequences("\n\nHuman:"),
+	)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for range events {
+	}
+
+	if aws.ToString(mock.input.ModelId) != "anthropic.claude-3-haiku-20240307-v1:0" {
+		t.Errorf("expected model override, got '%s'", aws.ToString(mock.input.ModelId))
+	}
+	cfg := mock.input.InferenceConfig
+	if aws.ToInt32(cfg.MaxTokens) != 256 || aws.ToFloat32(cfg.TopP) != 0.5 || aws.ToFloat32(cfg.Temperature) != 0.7 {
+		t.Errorf("unexpected inference config: %+v", cfg)
+	}
+	if len(cfg.StopSequences) != 1 {
+		t.Errorf("expected 1 stop sequence, got %v", cfg.StopSequences)
+	}
+}
+
+func TestChatStreamEvents_UnsupportedOption(t *testing.T) {
+	client := bedrock.NewClientWithSDK(&mockBedrockClient{reader: newMockReader()}, "anthropic.claude-3-sonnet-20240229-v1:0", 0.7)
+
+	_, err := client.ChatStreamEvents(context.Background(), []assistant.Message{
+		{Role: assistant.RoleUser, Content: "Hello"},
+	}, nil, assistant.ToolChoiceAuto, assistant.WithSeed(42))
+
+	var unsupported *assistant.UnsupportedOptionError
+	if !errors.As(err, &unsupported) || unsupported.Option != "seed" {
+		t.Fatalf("expected unsupported seed option error, got %v", err)
+	}
+}
+
+func TestChatStreamEvents_AbandonedReader(t *testing.T) {
+	for _, n := range []int{1, 10} { // waiting for the next event, or blocked sending one
+		baseline := runtime.NumGoroutine()
+
+		reader := newOpenReader(n)
+		client := bedrock.NewClientWithSDK(&openBedrockClient{reader: reader}, "anthropic.claude-3-sonnet-20240229-v1:0", 0.7)
+		ctx, cancel := context.WithCancel(context.Background())
+
+		result, err := client.ChatStreamWithToolsAndUsage(ctx, []assistant.Message{
+			{Role: assistant.RoleUser, Content: "Hello"},
+		}, nil, assistant.ToolChoiceAuto)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		<-result.TextChannel
+		cancel()
+
+		select {
+		case <-reader.closed:
+		case <-time.After(2 * time.Second):
+			t.Fatal("expected event stream to be closed after cancellation")
+		}
+		testutil.WaitForGoroutines(t, baseline)
+	}
+}
@@ -0,0 +1,255 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/sburchfield/go-assistant-api/assistant"
+)
+
+// BreakerState is the state of a circuit breaker.
+type BreakerState int
+
+const (
+	// BreakerClosed lets requests through while counting failures.
+	BreakerClosed BreakerState = iota
+	// BreakerOpen rejects requests with a *CircuitOpenError until the
+	// cool-down has passed.
+	BreakerOpen
+	// BreakerHalfOpen lets a limited number of trial requests through; their
+	// outcome closes or re-opens the circuit.
+	BreakerHalfOpen
+)
+
+func (s BreakerState) String() string {
+	switch s {
+	case BreakerClosed:
+		return "closed"
+	case BreakerOpen:
+		return "open"
+	case BreakerHalfOpen:
+		return "half-open"
+	default:
+		return fmt.Sprintf("BreakerState(%d)", int(s))
+	}
+}
+
+// CircuitOpenError is returned, without calling the backend, while the
+// circuit for a provider and model is open.
+type CircuitOpenError struct {
+	Provider string
+	Model    string
+	// RetryAt is when the circuit next lets a trial request through.
+	RetryAt time.Time
+}
+
+func (e *CircuitOpenError) Error() string {
+	return fmt.Sprintf("circuit open for %s (model %q) until %s", e.Provider, e.Model, e.RetryAt.Format(time.RFC3339))
+}
+
+// BreakerPolicy configures CircuitBreaker. Zero fields select the defaults.
+type BreakerPolicy struct {
+	// FailureThreshold is the number of consecutive failures that opens the
+	// circuit. Defaults to 5.
+	FailureThreshold int
+	// Cooldown is how long the circuit stays open before a trial request is
+	// let through. Defaults to 30s.
+	Cooldown time.Duration
+	// HalfOpenRequests is the number of concurrent trial requests allowed
+	// while half-open. Defaults to 1.
+	HalfOpenRequests int
+	// IsFailure reports whether an error counts against the backend. Defaults
+	// to IsRetryable, so rate limits, 5xx responses and timeouts count while
+	// invalid requests and local limits do not. Requests abandoned by the
+	// caller never count.
+	IsFailure func(err error) bool
+	// OnStateChange, if set, is called after the circuit for provider and
+	// model moves between states.
+	OnStateChange func(provider, model string, from, to BreakerState)
+}
+
+func (p BreakerPolicy) withDefaults() BreakerPolicy {
+	if p.FailureThreshold <= 0 {
+		p.FailureThreshold = 5
+	}
+	if p.Cooldown <= 0 {
+		p.Cooldown = 30 * time.Second
+	}
+	if p.HalfOpenRequests <= 0 {
+		p.HalfOpenRequests = 1
+	}
+	if p.IsFailure == nil {
+		p.IsFailure = func(err error) bool {
+			retryable, _ := IsRetryable(err)
+			return retryable
+		}
+	}
+	return p
+}
+
+// Breaker is a ChatProvider that stops sending requests to a backend model
+// after repeated failures, failing fast with a *CircuitOpenError instead, and
+// probes it again after a cool-down. Each model requested through the
+// provider (see assistant.WithModel) has its own circuit.
+type Breaker struct {
+	streamMethods
+	inner  ChatProvider
+	name   string
+	policy BreakerPolicy
+
+	mu       sync.Mutex
+	circuits map[string]*circuit
+}
+
+type circuit struct {
+	state    BreakerState
+	failures int
+	retryAt  time.Time
+	trials   int
+}
+
+// CircuitBreaker wraps p with a circuit breaker per model. The provider is
+// identified by its Named name, or its type, in errors and hooks.
+func CircuitBreaker(p ChatProvider, policy BreakerPolicy) *Breaker {
+	b := &Breaker{
+		inner:    p,
+		name:     providerName(p),
+		policy:   policy.withDefaults(),
+		circuits: map[string]*circuit{},
+	}
+	b.streamMethods = streamMethods{b.ChatStreamEvents}
+	return b
+}
+
+// Name returns the name of the wrapped provider, so a Fallback reports it.
+func (b *Breaker) Name() string { return b.name }
+
+// State returns the state of the circuit for model; an empty model is the
+// provider's default.
+func (b *Breaker) State(model string) BreakerState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	c, ok := b.circuits[model]
+	switch {
+	case !ok:
+		return BreakerClosed
+	case c.state == BreakerOpen && !time.Now().Before(c.retryAt):
+		return BreakerHalfOpen
+	default:
+		return c.state
+	}
+}
+
+func (b *Breaker) ChatStreamEvents(ctx context.Context, messages []assistant.Message, tools []assistant.Tool, toolChoice assistant.ToolChoice, opts ...assistant.GenerationOption) (<-chan assistant.Event, error) {
+	done, err := b.allow(ctx, assistant.NewGenerationOptions(opts...).Model)
+	if err != nil {
+		return nil, err
+	}
+
+	events, err := b.inner.ChatStreamEvents(ctx, messages, tools, toolChoice, opts...)
+	if err != nil {
+		done(err)
+		return nil, err
+	}
+
+	out := make(chan assistant.Event)
+	go func() {
+		defer close(out)
+
+		var streamErr error
+		for ev := range events {
+			if ev.Type == assistant.EventError {
+				streamErr = ev.Err
+			}
+			if !assistant.SendEvent(ctx, out, ev) {
+				streamErr = ctx.Err()
+				break
+			}
+		}
+		done(streamErr)
+	}()
+	return out, nil
+}
+
+func (b *Breaker) Chat(ctx context.Context, messages []assistant.Message, opts assistant.ChatOptions) (*assistant.Response, error) {
+	done, err := b.allow(ctx, opts.Model)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := b.inner.Chat(ctx, messages, opts)
+	done(err)
+	return resp, err
+}
+
+// allow admits a request for model, or returns a *CircuitOpenError. The
+// returned done function must be called with the request's outcome.
+func (b *Breaker) allow(ctx context.Context, model string) (done func(error), err error) {
+	b.mu.Lock()
+	c, ok := b.circuits[model]
+	if !ok {
+		c = &circuit{}
+		b.circuits[model] = c
+	}
+
+	from := c.state
+	if c.state == BreakerOpen && !time.Now().Before(c.retryAt) {
+		c.state = BreakerHalfOpen
+	}
+	switch {
+	case c.state == BreakerOpen,
+		c.state == BreakerHalfOpen && c.trials >= b.policy.HalfOpenRequests:
+		retryAt := c.retryAt
+		b.mu.Unlock()
+		b.notify(model, from, c.state)
+		return nil, &CircuitOpenError{Provider: b.name, Model: model, RetryAt: retryAt}
+	case c.state == BreakerHalfOpen:
+		c.trials++
+	}
+	trial := c.state == BreakerHalfOpen
+	to := c.state
+	b.mu.Unlock()
+	b.notify(model, from, to)
+
+	var once sync.Once
+	return func(err error) {
+		once.Do(func() { b.record(ctx, model, c, trial, err) })
+	}, nil
+}
+
+// record updates the circuit with the outcome of a request.
+func (b *Breaker) record(ctx context.Context, model string, c *circuit, trial bool, err error) {
+	b.mu.Lock()
+	from := c.state
+	if trial {
+		c.trials--
+	}
+
+	switch {
+	case err != nil && (ctx.Err() != nil || errors.Is(err, context.Canceled)):
+		// The caller gave up; that says nothing about the backend.
+	case err != nil && b.policy.IsFailure(err):
+		c.failures++
+		if c.state == BreakerHalfOpen || c.failures >= b.policy.FailureThreshold {
+			c.state = BreakerOpen
+			c.retryAt = time.Now().Add(b.policy.Cooldown)
+		}
+	default:
+		c.failures = 0
+		if c.state == BreakerHalfOpen && trial {
+			c.state = BreakerClosed
+		}
+	}
+	to := c.state
+	b.mu.Unlock()
+	b.notify(model, from, to)
+}
+
+func (b *Breaker) notify(model string, from, to BreakerState) {
+	if from != to && b.policy.OnStateChange != nil {
+		b.policy.OnStateChange(b.name, model, from, to)
+	}
+}
@@ -0,0 +1,168 @@
+package provider_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sburchfield/go-assistant-api/assistant"
+	"github.com/sburchfield/go-assistant-api/assistant/provider"
+)
+
+// transitions records the state changes reported by a breaker.
+type transitions struct {
+	mu  sync.Mutex
+	log []string
+}
+
+func (tr *transitions) record(name, model string, from, to provider.BreakerState) {
+	tr.mu.Lock()
+	defer tr.mu.Unlock()
+	tr.log = append(tr.log, fmt.Sprintf("%s/%s: %s->%s", name, model, from, to))
+}
+
+func (tr *transitions) String() string {
+	tr.mu.Lock()
+	defer tr.mu.Unlock()
+	return fmt.Sprint(tr.log)
+}
+
+func TestCircuitBreaker_OpensAndRecovers(t *testing.T) {
+	inner := script(step{err: errRateLimited}, streamError(errRateLimited), reply("Hi"))
+	var tr transitions
+	b := provider.CircuitBreaker(provider.Named("bedrock", inner), provider.BreakerPolicy{
+		FailureThreshold: 2,
+		Cooldown:         20 * time.Millisecond,
+		OnStateChange:    tr.record,
+	})
+
+	for i := 0; i < 2; i++ {
+		if _, err := collect(t, b); !errors.Is(err, errRateLimited) {
+			t.Fatalf("request %d: err = %v, want errRateLimited", i, err)
+		}
+	}
+	if b.State("") != provider.BreakerOpen {
+		t.Fatalf("state = %v, want open", b.State(""))
+	}
+
+	// While open, requests fail fast without reaching the backend.
+	_, err := collect(t, b)
+	var openErr *provider.CircuitOpenError
+	if !errors.As(err, &openErr) || openErr.Provider != "bedrock" || inner.Calls() != 2 {
+		t.Fatalf("err = %v after %d calls, want CircuitOpenError after 2", err, inner.Calls())
+	}
+	if retryable, _ := provider.IsRetryable(err); retryable {
+		t.Error("IsRetryable = true for an open circuit, want false")
+	}
+
+	time.Sleep(25 * time.Millisecond)
+	if b.State("") != provider.BreakerHalfOpen {
+		t.Fatalf("state = %v, want half-open", b.State(""))
+	}
+	resp, err := collect(t, b)
+	if err != nil || resp.Message.Content != "Hi" {
+		t.Fatalf("trial: content = %q, err = %v", resp.Message.Content, err)
+	}
+	if b.State("") != provider.BreakerClosed {
+		t.Errorf("state = %v, want closed", b.State(""))
+	}
+
+	want := "[bedrock/: closed->open bedrock/: open->half-open bedrock/: half-open->closed]"
+	if tr.String() != want {
+		t.Errorf("transitions = %s, want %s", tr.String(), want)
+	}
+}
+
+func TestCircuitBreaker_FailedTrialReopens(t *testing.T) {
+	inner := script(step{err: errRateLimited})
+	b := provider.CircuitBreaker(inner, provider.BreakerPolicy{FailureThreshold: 1, Cooldown: 10 * time.Millisecond})
+
+	collect(t, b)
+	time.Sleep(15 * time.Millisecond)
+	if _, err := collect(t, b); !errors.Is(err, errRateLimited) {
+		t.Fatalf("trial: err = %v, want errRateLimited", err)
+	}
+	if b.State("") != provider.BreakerOpen || inner.Calls() != 2 {
+		t.Errorf("state = %v after %d calls, want open after 2", b.State(""), inner.Calls())
+	}
+}
+
+func TestCircuitBreaker_IgnoresNonFailures(t *testing.T) {
+	inner := script(step{err: errBadRequest})
+	b := provider.CircuitBreaker(inner, provider.BreakerPolicy{FailureThreshold: 1})
+
+	for i := 0; i < 3; i++ {
+		if _, err := collect(t, b); !errors.Is(err, errBadRequest) {
+			t.Fatalf("request %d: err = %v, want errBadRequest", i, err)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	b = provider.CircuitBreaker(script(step{err: context.Canceled}), provider.BreakerPolicy{FailureThreshold: 1})
+	b.ChatStreamEvents(ctx, hello, nil, "")
+	if b.State("") != provider.BreakerClosed {
+		t.Errorf("state after cancellation = %v, want closed", b.State(""))
+	}
+}
+
+func TestCircuitBreaker_PerModel(t *testing.T) {
+	inner := script(step{err: errRateLimited}, reply("Hi"))
+	b := provider.CircuitBreaker(inner, provider.BreakerPolicy{FailureThreshold: 1, Cooldown: time.Minute})
+
+	if _, err := b.ChatStreamEvents(context.Background(), hello, nil, "", assistant.WithModel("big")); err == nil {
+		t.Fatal("expected an error")
+	}
+	if b.State("big") != provider.BreakerOpen || b.State("small") != provider.BreakerClosed {
+		t.Errorf("states = %v, %v; want open, closed", b.State("big"), b.State("small"))
+	}
+
+	resp, err := b.Chat(context.Background(), hello, assistant.ChatOptions{GenerationOptions: assistant.GenerationOptions{Model: "small"}})
+	if err != nil || resp.Message.Content != "Hi" {
+		t.Errorf("other model: resp = %+v, err = %v", resp, err)
+	}
+	var openErr *provider.CircuitOpenError
+	_, err = b.Chat(context.Background(), hello, assistant.ChatOptions{GenerationOptions: assistant.GenerationOptions{Model: "big"}})
+	if !errors.As(err, &openErr) || openErr.Model != "big" {
+		t.Errorf("err = %v, want CircuitOpenError for big", err)
+	}
+}
+
+func TestCircuitBreaker_FallbackSkipsOpenCircuit(t *testing.T) {
+	primary := script(step{err: errRateLimited})
+	b := provider.CircuitBreaker(provider.Named("primary", primary), provider.BreakerPolicy{FailureThreshold: 1, Cooldown: time.Minute})
+	p := provider.Fallback(b, provider.Named("secondary", script(reply("From backup"))))
+
+	for i := 0; i < 3; i++ {
+		resp, err := collect(t, p)
+		if err != nil || resp.Metadata == nil || resp.Metadata.Provider != "secondary" {
+			t.Fatalf("request %d: metadata = %+v, err = %v", i, resp.Metadata, err)
+		}
+	}
+	if primary.Calls() != 1 {
+		t.Errorf("primary calls = %d, want 1", primary.Calls())
+	}
+}
+
+func TestCircuitBreaker_RetryDoesNotWaitOutCooldown(t *testing.T) {
+	inner := script(step{err: errRateLimited})
+	b := provider.CircuitBreaker(inner, provider.BreakerPolicy{FailureThreshold: 1, Cooldown: time.Minute})
+	collect(t, b)
+
+	var delays []time.Duration
+	_, err := collect(t, provider.Retry(b, fastRetry(&delays)))
+	var openErr *provider.CircuitOpenError
+	if !errors.As(err, &openErr) || len(delays) != 0 || inner.Calls() != 1 {
+		t.Errorf("err = %v after %d retries, want CircuitOpenError without retrying", err, len(delays))
+	}
+
+	// An open circuit further down says nothing about the backend either.
+	outer := provider.CircuitBreaker(b, provider.BreakerPolicy{FailureThreshold: 1})
+	collect(t, outer)
+	if outer.State("") != provider.BreakerClosed {
+		t.Errorf("outer state = %v, want closed", outer.State(""))
+	}
+}
@@ -0,0 +1,171 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/sburchfield/go-assistant-api/assistant/provider/anthropic"
+	"github.com/sburchfield/go-assistant-api/assistant/provider/bedrock"
+	"github.com/sburchfield/go-assistant-api/assistant/provider/gemini"
+	"github.com/sburchfield/go-assistant-api/assistant/provider/openai"
+)
+
+func init() {
+	Register("openai", newOpenAI)
+	Register("gemini", newGemini)
+	Register("bedrock", newBedrock)
+	Register("anthropic", newAnthropic)
+}
+
+// newOpenAI builds an OpenAI, Azure OpenAI or OpenAI-compatible client.
+//
+// Options: org_id, project_id, api_mode ("responses" selects the Responses
+// API), reasoning_effort, reasoning_summary, azure_endpoint, azure_deployment,
+// azure_api_version, azure_api_key and azure_ad_token.
+func newOpenAI(ctx context.Context, cfg Config) (ChatProvider, error) {
+	apiKey, model := cfg.APIKey, cfg.Model
+
+	var opts []openai.Option
+	if cfg.Temperature != nil {
+		opts = append(opts, openai.WithTemperature(*cfg.Temperature))
+	}
+	if cfg.APIKeySource != nil {
+		opts = append(opts, openai.WithAPIKeyProvider(cfg.APIKeySource.Secret))
+	}
+	if orgID := cfg.Options["org_id"]; orgID != "" {
+		opts = append(opts, openai.WithOrganization(orgID))
+	}
+	if projectID := cfg.Options["project_id"]; projectID != "" {
+		opts = append(opts, openai.WithProject(projectID))
+	}
+	if effort, summary := cfg.Options["reasoning_effort"], cfg.Options["reasoning_summary"]; effort != "" || summary != "" {
+		opts = append(opts, openai.WithReasoning(effort, summary))
+	}
+
+	if endpoint := cfg.Options["azure_endpoint"]; endpoint != "" {
+		// Azure OpenAI: the deployment stands in for the model name.
+		deployment := cfg.Options["azure_deployment"]
+		if model == "" {
+			model = deployment
+		}
+		if key := cfg.Options["azure_api_key"]; key != "" {
+			apiKey = key
+		}
+		opts = append(opts, openai.WithAzure(endpoint, deployment, cfg.Options["azure_api_version"]))
+		if token := cfg.Options["azure_ad_token"]; token != "" {
+			opts = append(opts, openai.WithAzureADToken(token))
+		} else if apiKey == "" && cfg.APIKeySource == nil {
+			return nil, fmt.Errorf("openai: Azure requires an API key or AD token")
+		}
+		if model == "" {
+			return nil, fmt.Errorf("openai: Azure requires a deployment or model")
+		}
+	} else {
+		// Self-hosted OpenAI-compatible servers usually need no key.
+		if cfg.BaseURL != "" {
+			opts = append(opts, openai.WithBaseURL(cfg.BaseURL))
+		}
+		if (apiKey == "" && cfg.APIKeySource == nil && cfg.BaseURL == "") || model == "" {
+			return nil, fmt.Errorf("openai: missing API key or model")
+		}
+	}
+
+	switch cfg.Options["api_mode"] {
+	case "", "chat":
+		return openai.NewClient(apiKey, model, 0, opts...), nil
+	case "responses":
+		return openai.NewResponsesClient(apiKey, model, 0, opts...), nil
+	default:
+		return nil, fmt.Errorf("openai: unknown api_mode %q", cfg.Options["api_mode"])
+	}
+}
+
+// newGemini builds a Gemini client. An API key selects the Gemini Developer
+// API; otherwise Vertex AI is used.
+//
+// A key or credentials read from a secret source are fetched again when
+// needed, so rotated secrets are picked up.
+//
+// Options: project_id, location, and the GCP credentials JSON as either
+// credentials_secret (a secret reference, see ParseSecretSource) or
+// secret_name (an AWS Secrets Manager secret).
+func newGemini(ctx context.Context, cfg Config) (ChatProvider, error) {
+	if cfg.Model == "" {
+		return nil, fmt.Errorf("gemini: missing model")
+	}
+
+	if cfg.APIKeySource != nil {
+		return gemini.NewClient(ctx, "", "", cfg.Model, cfg.temperature(), "", gemini.WithAPIKeyProvider(cfg.APIKeySource.Secret))
+	}
+	if cfg.APIKey != "" {
+		return gemini.NewClient(ctx, "", "", cfg.Model, cfg.temperature(), "", gemini.WithAPIKey(cfg.APIKey))
+	}
+
+	projectID, location := cfg.Options["project_id"], cfg.Options["location"]
+	if projectID == "" || location == "" {
+		return nil, fmt.Errorf("gemini: missing project_id or location (or set an API key)")
+	}
+
+	var credentials SecretSource
+	if ref := cfg.Options["credentials_secret"]; ref != "" {
+		src, err := ParseSecretSource(ref)
+		if err != nil {
+			return nil, fmt.Errorf("gemini: %w", err)
+		}
+		credentials = src
+	} else if secretName := cfg.Options["secret_name"]; secretName != "" {
+		credentials = &AWSSecretSource{SecretID: secretName}
+	}
+
+	var opts []gemini.Option
+	if credentials != nil {
+		opts = append(opts, gemini.WithCredentialsProvider(CachedSecret(credentials, cfg.SecretTTL).Secret))
+	}
+	return gemini.NewClient(ctx, projectID, location, cfg.Model, cfg.temperature(), "", opts...)
+}
+
+// newBedrock builds a Bedrock client using the default AWS credentials, which
+// the AWS SDK refreshes itself. It takes no API key.
+//
+// Options: region (defaults to us-east-1).
+func newBedrock(ctx context.Context, cfg Config) (ChatProvider, error) {
+	if cfg.Model == "" {
+		return nil, fmt.Errorf("bedrock: missing model")
+	}
+	if cfg.APIKey != "" || cfg.APIKeySource != nil {
+		return nil, fmt.Errorf("bedrock: API keys are not supported; configure AWS credentials instead")
+	}
+
+	region := cfg.Options["region"]
+	if region == "" {
+		region = "us-east-1" // Default region
+	}
+
+	return bedrock.NewClient(ctx, region, cfg.Model, cfg.temperature())
+}
+
+// newAnthropic builds an Anthropic Messages API client.
+//
+// Options: thinking_budget (enables extended thinking with that many tokens).
+func newAnthropic(ctx context.Context, cfg Config) (ChatProvider, error) {
+	if (cfg.APIKey == "" && cfg.APIKeySource == nil) || cfg.Model == "" {
+		return nil, fmt.Errorf("anthropic: missing API key or model")
+	}
+
+	var opts []anthropic.Option
+	if budget := cfg.Options["thinking_budget"]; budget != "" {
+		tokens, err := strconv.Atoi(budget)
+		if err != nil || tokens <= 0 {
+			return nil, fmt.Errorf("anthropic: invalid thinking_budget %q", budget)
+		}
+		opts = append(opts, anthropic.WithThinking(tokens))
+	}
+	if cfg.APIKeySource != nil {
+		opts = append(opts, anthropic.WithAPIKeyProvider(cfg.APIKeySource.Secret))
+	}
+	if cfg.BaseURL != "" {
+		opts = append(opts, anthropic.WithBaseURL(cfg.BaseURL))
+	}
+	return anthropic.NewClient(cfg.APIKey, cfg.Model, cfg.temperature(), opts...), nil
+}
@@ -0,0 +1,366 @@
+package provider
+
+import (
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/sburchfield/go-assistant-api/assistant"
+)
+
+// CacheStore stores responses under the keys computed by CacheKey.
+type CacheStore interface {
+	Get(ctx context.Context, key string) (resp *assistant.Response, ok bool, err error)
+	Set(ctx context.Context, key string, resp *assistant.Response) error
+}
+
+// CachePolicy configures Cache. Zero fields select the defaults.
+type CachePolicy struct {
+	// Store holds the cached responses. Defaults to a MemoryCache of 1000
+	// entries.
+	Store CacheStore
+	// Model is the model the provider uses when a request does not pick one
+	// with assistant.WithModel. It is part of every key, so providers of the
+	// same type serving different models can share a Store.
+	Model string
+	// Temperature is the provider's default temperature, if known. Requests
+	// that do not set their own are cached when it is zero.
+	Temperature *float32
+	// Force caches every request. By default only requests whose temperature,
+	// set with assistant.WithTemperature or defaulted from Temperature, is
+	// zero are cached, since sampled responses are expected to vary.
+	Force bool
+	// ChunkSize splits replayed text into deltas of this many characters, to
+	// look like a live stream. Zero replays the text as a single delta.
+	ChunkSize int
+	// ChunkDelay is the pause between replayed text deltas.
+	ChunkDelay time.Duration
+}
+
+// CacheKey returns the cache key of a request: a hash of the provider name,
+// the model and every input that shapes the response.
+func CacheKey(provider string, messages []assistant.Message, tools []assistant.Tool, toolChoice assistant.ToolChoice, opts assistant.GenerationOptions) string {
+	// Maps, such as tool parameter schemas, are marshaled with sorted keys,
+	// so equal requests produce equal JSON.
+	canonical, _ := json.Marshal(struct {
+		Provider   string                      `json:"provider"`
+		Messages   []assistant.Message         `json:"messages"`
+		Tools      []assistant.Tool            `json:"tools,omitempty"`
+		ToolChoice assistant.ToolChoice        `json:"tool_choice,omitempty"`
+		Options    assistant.GenerationOptions `json:"options"`
+	}{provider, messages, tools, toolChoice, opts})
+	sum := sha256.Sum256(canonical)
+	return hex.EncodeToString(sum[:])
+}
+
+// Cache wraps p so that responses to repeated, identical requests are served
+// from policy.Store instead of the backend. Hits are replayed as an ordinary
+// event stream, starting with an assistant.EventMetadata event whose Cached
+// field is set. Only complete, successful responses are stored; errors from
+// the store are treated as misses.
+//
+// Keys are scoped by the provider's name (see Named) and policy.Model, so
+// wrap each backend under its own name when several share a Store.
+func Cache(p ChatProvider, policy CachePolicy) ChatProvider {
+	if policy.Store == nil {
+		policy.Store = NewMemoryCache(1000)
+	}
+	c := &cacheProvider{inner: p, name: providerName(p), policy: policy}
+	c.streamMethods = streamMethods{c.ChatStreamEvents}
+	return c
+}
+
+type cacheProvider struct {
+	streamMethods
+	inner  ChatProvider
+	name   string
+	policy CachePolicy
+}
+
+func (c *cacheProvider) Name() string { return c.name }
+
+// effective fills in the provider defaults that opts leaves unset, so that a
+// request relying on a default and one naming it explicitly share a key.
+func (c *cacheProvider) effective(opts assistant.GenerationOptions) assistant.GenerationOptions {
+	if opts.Model == "" {
+		opts.Model = c.policy.Model
+	}
+	if opts.Temperature == nil {
+		opts.Temperature = c.policy.Temperature
+	}
+	return opts
+}
+
+func (c *cacheProvider) cacheable(opts assistant.GenerationOptions) bool {
+	return c.policy.Force || (opts.Temperature != nil && *opts.Temperature == 0)
+}
+
+func (c *cacheProvider) ChatStreamEvents(ctx context.Context, messages []assistant.Message, tools []assistant.Tool, toolChoice assistant.ToolChoice, opts ...assistant.GenerationOption) (<-chan assistant.Event, error) {
+	o := c.effective(assistant.NewGenerationOptions(opts...))
+	if !c.cacheable(o) {
+		return c.inner.ChatStreamEvents(ctx, messages, tools, toolChoice, opts...)
+	}
+
+	key := CacheKey(c.name, messages, tools, toolChoice, o)
+	if resp, ok, err := c.policy.Store.Get(ctx, key); err == nil && ok {
+		return c.replay(ctx, resp), nil
+	}
+
+	events, err := c.inner.ChatStreamEvents(ctx, messages, tools, toolChoice, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make(chan assistant.Event)
+	go func() {
+		defer close(out)
+
+		var seen []assistant.Event
+		finished := false
+		for ev := range events {
+			seen = append(seen, ev)
+			finished = finished || ev.Type == assistant.EventFinish
+			if !assistant.SendEvent(ctx, out, ev) {
+				return
+			}
+		}
+		// Providers end the stream without an error when ctx is done, so
+		// only a stream that reached its finish event is complete.
+		if !finished || ctx.Err() != nil {
+			return
+		}
+
+		collected := make(chan assistant.Event, len(seen))
+		for _, ev := range seen {
+			collected <- ev
+		}
+		close(collected)
+		if resp, err := assistant.Collect(collected); err == nil {
+			c.policy.Store.Set(ctx, key, resp)
+		}
+	}()
+	return out, nil
+}
+
+func (c *cacheProvider) Chat(ctx context.Context, messages []assistant.Message, opts assistant.ChatOptions) (*assistant.Response, error) {
+	o := c.effective(opts.GenerationOptions)
+	if !c.cacheable(o) {
+		return c.inner.Chat(ctx, messages, opts)
+	}
+
+	key := CacheKey(c.name, messages, opts.Tools, opts.ToolChoice, o)
+	if resp, ok, err := c.policy.Store.Get(ctx, key); err == nil && ok {
+		hit := *resp
+		hit.Metadata = cachedMetadata(resp)
+		return &hit, nil
+	}
+
+	resp, err := c.inner.Chat(ctx, messages, opts)
+	if err == nil {
+		c.policy.Store.Set(ctx, key, resp)
+	}
+	return resp, err
+}
+
+// replay streams a cached response as the events that produced it.
+func (c *cacheProvider) replay(ctx context.Context, resp *assistant.Response) <-chan assistant.Event {
+	events := []assistant.Event{{Type: assistant.EventMetadata, Metadata: cachedMetadata(resp)}}
+	for _, chunk := range chunkText(resp.Message.Content, c.policy.ChunkSize) {
+		events = append(events, assistant.Event{Type: assistant.EventTextDelta, Text: chunk})
+	}
+	for i, tc := range resp.Message.ToolCalls {
+		events = append(events,
+			assistant.Event{Type: assistant.EventToolCallStart, ToolCall: &assistant.ToolCallDelta{Index: i, ID: tc.ID, Name: tc.Function.Name}},
+			assistant.Event{Type: assistant.EventToolCallDelta, ToolCall: &assistant.ToolCallDelta{Index: i, ID: tc.ID, Arguments: tc.Function.Arguments}},
+			assistant.Event{Type: assistant.EventToolCallEnd, ToolCall: &assistant.ToolCallDelta{Index: i, ID: tc.ID}},
+		)
+	}
+	events = append(events, assistant.Event{Type: assistant.EventFinish, FinishReason: resp.FinishReason})
+	if resp.Usage != nil {
+		usage := *resp.Usage
+		events = append(events, assistant.Event{Type: assistant.EventUsage, Usage: &usage})
+	}
+
+	out := make(chan assistant.Event)
+	go func() {
+		defer close(out)
+
+		delayed := false
+		for _, ev := range events {
+			if ev.Type == assistant.EventTextDelta && c.policy.ChunkDelay > 0 {
+				if delayed && !sleep(ctx, c.policy.ChunkDelay) {
+					return
+				}
+				delayed = true
+			}
+			if !assistant.SendEvent(ctx, out, ev) {
+				return
+			}
+		}
+	}()
+	return out
+}
+
+// cachedMetadata returns the metadata of resp, marked as cached.
+func cachedMetadata(resp *assistant.Response) *assistant.StreamMetadata {
+	var md assistant.StreamMetadata
+	if resp.Metadata != nil {
+		md = *resp.Metadata
+	}
+	md.Cached = true
+	return &md
+}
+
+// chunkText splits s into pieces of size characters, or returns it whole if
+// size is not positive.
+func chunkText(s string, size int) []string {
+	if s == "" {
+		return nil
+	}
+	runes := []rune(s)
+	if size <= 0 || size >= len(runes) {
+		return []string{s}
+	}
+	var chunks []string
+	for start := 0; start < len(runes); start += size {
+		chunks = append(chunks, string(runes[start:min(start+size, len(runes))]))
+	}
+	return chunks
+}
+
+// sleep waits for d, and reports false if ctx is done first.
+func sleep(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// MemoryCache is an in-memory CacheStore that evicts the least recently used
+// response once it holds its capacity.
+type MemoryCache struct {
+	mu       sync.Mutex
+	capacity int
+	order    *list.List // of *memoryEntry, most recently used first
+	entries  map[string]*list.Element
+}
+
+type memoryEntry struct {
+	key  string
+	resp assistant.Response
+}
+
+// NewMemoryCache creates a MemoryCache holding up to capacity responses.
+func NewMemoryCache(capacity int) *MemoryCache {
+	return &MemoryCache{capacity: max(capacity, 1), order: list.New(), entries: map[string]*list.Element{}}
+}
+
+func (m *MemoryCache) Get(ctx context.Context, key string) (*assistant.Response, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	el, ok := m.entries[key]
+	if !ok {
+		return nil, false, nil
+	}
+	m.order.MoveToFront(el)
+	resp := el.Value.(*memoryEntry).resp
+	return &resp, true, nil
+}
+
+func (m *MemoryCache) Set(ctx context.Context, key string, resp *assistant.Response) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if el, ok := m.entries[key]; ok {
+		el.Value.(*memoryEntry).resp = *resp
+		m.order.MoveToFront(el)
+		return nil
+	}
+	m.entries[key] = m.order.PushFront(&memoryEntry{key: key, resp: *resp})
+	for m.order.Len() > m.capacity {
+		oldest := m.order.Back()
+		m.order.Remove(oldest)
+		delete(m.entries, oldest.Value.(*memoryEntry).key)
+	}
+	return nil
+}
+
+// Len returns the number of cached responses.
+func (m *MemoryCache) Len() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.order.Len()
+}
+
+// FileCache is a CacheStore that keeps each response as a JSON file in a
+// directory, so the cache survives restarts and can be shared by processes.
+type FileCache struct {
+	Dir string
+}
+
+// NewFileCache creates a FileCache in dir, which is created on first use.
+func NewFileCache(dir string) *FileCache {
+	return &FileCache{Dir: dir}
+}
+
+func (f *FileCache) path(key string) string {
+	return filepath.Join(f.Dir, key+".json")
+}
+
+func (f *FileCache) Get(ctx context.Context, key string) (*assistant.Response, bool, error) {
+	data, err := os.ReadFile(f.path(key))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to read cached response: %w", err)
+	}
+	var resp assistant.Response
+	if err := json.Unmarshal(data, &resp); err != nil {
+		return nil, false, fmt.Errorf("failed to decode cached response: %w", err)
+	}
+	return &resp, true, nil
+}
+
+func (f *FileCache) Set(ctx context.Context, key string, resp *assistant.Response) error {
+	data, err := json.Marshal(resp)
+	if err != nil {
+		return fmt.Errorf("failed to encode response: %w", err)
+	}
+	if err := os.MkdirAll(f.Dir, 0o755); err != nil {
+		return fmt.Errorf("failed to create cache directory: %w", err)
+	}
+
+	// Write to a temporary file and rename it, so readers never see a
+	// partial response.
+	tmp, err := os.CreateTemp(f.Dir, key+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to write cached response: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write cached response: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write cached response: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), f.path(key)); err != nil {
+		return fmt.Errorf("failed to write cached response: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,263 @@
+package provider_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/sburchfield/go-assistant-api/assistant"
+	"github.com/sburchfield/go-assistant-api/assistant/provider"
+)
+
+// toolReply is a successful response with text and a tool call.
+var toolReply = step{events: []assistant.Event{
+	{Type: assistant.EventTextDelta, Text: "Let me check."},
+	{Type: assistant.EventToolCallStart, ToolCall: &assistant.ToolCallDelta{Index: 0, ID: "call_1", Name: "lookup"}},
+	{Type: assistant.EventToolCallDelta, ToolCall: &assistant.ToolCallDelta{Index: 0, Arguments: `{"q":"go"}`}},
+	{Type: assistant.EventToolCallEnd, ToolCall: &assistant.ToolCallDelta{Index: 0}},
+	{Type: assistant.EventFinish, FinishReason: assistant.FinishReasonToolCalls},
+	{Type: assistant.EventUsage, Usage: &assistant.UsageMetadata{PromptTokenCount: 10, CandidatesTokenCount: 5, TotalTokenCount: 15}},
+}}
+
+var deterministic = assistant.WithTemperature(0)
+
+func stream(t *testing.T, p provider.ChatProvider, opts ...assistant.GenerationOption) ([]assistant.Event, *assistant.Response) {
+	t.Helper()
+	events, err := p.ChatStreamEvents(context.Background(), hello, nil, "", opts...)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var seen []assistant.Event
+	for ev := range events {
+		seen = append(seen, ev)
+	}
+	replay := make(chan assistant.Event, len(seen))
+	for _, ev := range seen {
+		replay <- ev
+	}
+	close(replay)
+	resp, err := assistant.Collect(replay)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return seen, resp
+}
+
+func TestCache_ReplaysStream(t *testing.T) {
+	inner := script(toolReply)
+	p := provider.Cache(inner, provider.CachePolicy{ChunkSize: 4})
+
+	_, miss := stream(t, p, deterministic)
+	if miss.Metadata != nil {
+		t.Errorf("miss metadata = %+v, want none", miss.Metadata)
+	}
+
+	events, hit := stream(t, p, deterministic)
+	if inner.Calls() != 1 {
+		t.Fatalf("calls = %d, want 1", inner.Calls())
+	}
+	if hit.Message.Content != "Let me check." || hit.FinishReason != assistant.FinishReasonToolCalls {
+		t.Errorf("hit = %+v", hit)
+	}
+	if len(hit.ToolCalls) != 1 || hit.ToolCalls[0].ID != "call_1" || hit.ToolCalls[0].Function.Name != "lookup" || hit.ToolCalls[0].Function.Arguments != `{"q":"go"}` {
+		t.Errorf("tool calls = %+v", hit.ToolCalls)
+	}
+	if hit.Usage == nil || *hit.Usage != *miss.Usage {
+		t.Errorf("usage = %+v, want %+v", hit.Usage, miss.Usage)
+	}
+	if hit.Metadata == nil || !hit.Metadata.Cached {
+		t.Errorf("metadata = %+v, want cached", hit.Metadata)
+	}
+
+	var deltas int
+	for _, ev := range events {
+		if ev.Type == assistant.EventTextDelta {
+			deltas++
+		}
+	}
+	if deltas != 4 {
+		t.Errorf("text deltas = %d, want 4 chunks of up to 4 characters", deltas)
+	}
+}
+
+func TestCache_OnlyDeterministicRequests(t *testing.T) {
+	inner := script(reply("Hi"))
+	p := provider.Cache(inner, provider.CachePolicy{})
+	stream(t, p)
+	stream(t, p)
+	stream(t, p, assistant.WithTemperature(0.7))
+	stream(t, p, assistant.WithTemperature(0.7))
+	if inner.Calls() != 4 {
+		t.Errorf("calls = %d, want 4", inner.Calls())
+	}
+
+	inner = script(reply("Hi"))
+	p = provider.Cache(inner, provider.CachePolicy{Force: true})
+	stream(t, p, assistant.WithTemperature(0.7))
+	stream(t, p, assistant.WithTemperature(0.7))
+	stream(t, p, assistant.WithTemperature(0.7), assistant.WithModel("other"))
+	if inner.Calls() != 2 {
+		t.Errorf("forced: calls = %d, want 2", inner.Calls())
+	}
+}
+
+func TestCache_ProviderDefaults(t *testing.T) {
+	// Two clients of the same type with different default models share a
+	// store without serving each other's responses.
+	store := provider.NewMemoryCache(10)
+	mini, large := script(reply("mini")), script(reply("large"))
+	pMini := provider.Cache(mini, provider.CachePolicy{Store: store, Model: "gpt-4o-mini"})
+	pLarge := provider.Cache(large, provider.CachePolicy{Store: store, Model: "gpt-4o"})
+
+	stream(t, pMini, deterministic)
+	if _, resp := stream(t, pLarge, deterministic); resp.Message.Content != "large" || large.Calls() != 1 {
+		t.Errorf("content = %q after %d calls, want large after 1", resp.Message.Content, large.Calls())
+	}
+	// Naming the default model explicitly hits the same entry.
+	if _, resp := stream(t, pMini, deterministic, assistant.WithModel("gpt-4o-mini")); resp.Message.Content != "mini" || mini.Calls() != 1 {
+		t.Errorf("content = %q after %d calls, want mini after 1", resp.Message.Content, mini.Calls())
+	}
+
+	// A client built with temperature zero is cached without a per-request
+	// temperature.
+	zero := float32(0)
+	inner := script(reply("Hi"))
+	p := provider.Cache(inner, provider.CachePolicy{Temperature: &zero})
+	stream(t, p)
+	stream(t, p, deterministic)
+	stream(t, p, assistant.WithTemperature(0.7))
+	if inner.Calls() != 2 {
+		t.Errorf("calls = %d, want 2", inner.Calls())
+	}
+}
+
+func TestCache_ErrorsAreNotCached(t *testing.T) {
+	inner := script(streamError(errRateLimited), reply("Hi"))
+	p := provider.Cache(inner, provider.CachePolicy{})
+
+	events, err := p.ChatStreamEvents(context.Background(), hello, nil, "", deterministic)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := assistant.Collect(events); !errors.Is(err, errRateLimited) {
+		t.Fatalf("err = %v, want errRateLimited", err)
+	}
+	if _, resp := stream(t, p, deterministic); resp.Message.Content != "Hi" || inner.Calls() != 2 {
+		t.Errorf("content = %q after %d calls, want Hi after 2", resp.Message.Content, inner.Calls())
+	}
+}
+
+// stallingProvider sends some text, then ends the stream without a finish
+// event once ctx is done, like the built-in providers.
+type stallingProvider struct {
+	provider.ChatProvider
+}
+
+func (stallingProvider) ChatStreamEvents(ctx context.Context, messages []assistant.Message, tools []assistant.Tool, toolChoice assistant.ToolChoice, opts ...assistant.GenerationOption) (<-chan assistant.Event, error) {
+	out := make(chan assistant.Event)
+	go func() {
+		defer close(out)
+		if assistant.SendEvent(ctx, out, assistant.Event{Type: assistant.EventTextDelta, Text: "Hel"}) {
+			<-ctx.Done()
+		}
+	}()
+	return out, nil
+}
+
+func TestCache_CancelledStreamsAreNotCached(t *testing.T) {
+	store := provider.NewMemoryCache(10)
+	p := provider.Cache(stallingProvider{}, provider.CachePolicy{Store: store})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	events, err := p.ChatStreamEvents(ctx, hello, nil, "", deterministic)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	<-events
+	cancel()
+	for range events {
+	}
+	if store.Len() != 0 {
+		t.Errorf("store holds %d responses after a cancelled stream, want 0", store.Len())
+	}
+}
+
+func TestCache_Chat(t *testing.T) {
+	inner := script(reply("Hi"))
+	p := provider.Cache(provider.Named("openai", inner), provider.CachePolicy{})
+	opts := assistant.ChatOptions{GenerationOptions: assistant.NewGenerationOptions(deterministic)}
+
+	for i := 0; i < 2; i++ {
+		resp, err := p.Chat(context.Background(), hello, opts)
+		if err != nil || resp.Message.Content != "Hi" {
+			t.Fatalf("request %d: resp = %+v, err = %v", i, resp, err)
+		}
+		if cached := resp.Metadata != nil && resp.Metadata.Cached; cached != (i == 1) {
+			t.Errorf("request %d: metadata = %+v", i, resp.Metadata)
+		}
+	}
+	// Streams and Chat share entries.
+	if _, resp := stream(t, p, deterministic); !resp.Metadata.Cached || inner.Calls() != 1 {
+		t.Errorf("stream after Chat: metadata = %+v, calls = %d", resp.Metadata, inner.Calls())
+	}
+}
+
+func TestCacheKey(t *testing.T) {
+	tool := func(params map[string]interface{}) []assistant.Tool {
+		return []assistant.Tool{{Type: "function", Function: assistant.ToolFunction{Name: "lookup", Parameters: params}}}
+	}
+	opts := assistant.NewGenerationOptions(deterministic)
+	key := provider.CacheKey("openai", hello, tool(map[string]interface{}{"type": "object", "required": []string{"q"}}), "", opts)
+
+	if other := provider.CacheKey("openai", hello, tool(map[string]interface{}{"required": []string{"q"}, "type": "object"}), "", opts); other != key {
+		t.Error("key depends on map order")
+	}
+	for name, other := range map[string]string{
+		"provider": provider.CacheKey("gemini", hello, tool(map[string]interface{}{"type": "object", "required": []string{"q"}}), "", opts),
+		"tools":    provider.CacheKey("openai", hello, nil, "", opts),
+		"model":    provider.CacheKey("openai", hello, tool(map[string]interface{}{"type": "object", "required": []string{"q"}}), "", assistant.NewGenerationOptions(deterministic, assistant.WithModel("gpt-4o"))),
+		"messages": provider.CacheKey("openai", []assistant.Message{{Role: assistant.RoleUser, Content: "Hi"}}, tool(map[string]interface{}{"type": "object", "required": []string{"q"}}), "", opts),
+	} {
+		if other == key {
+			t.Errorf("key ignores %s", name)
+		}
+	}
+}
+
+func TestMemoryCache_EvictsLeastRecentlyUsed(t *testing.T) {
+	ctx := context.Background()
+	m := provider.NewMemoryCache(2)
+	m.Set(ctx, "a", &assistant.Response{Message: assistant.Message{Content: "A"}})
+	m.Set(ctx, "b", &assistant.Response{Message: assistant.Message{Content: "B"}})
+	m.Get(ctx, "a")
+	m.Set(ctx, "c", &assistant.Response{Message: assistant.Message{Content: "C"}})
+
+	if _, ok, _ := m.Get(ctx, "b"); ok {
+		t.Error("b should have been evicted")
+	}
+	if resp, ok, _ := m.Get(ctx, "a"); !ok || resp.Message.Content != "A" {
+		t.Errorf("a = %+v, %v", resp, ok)
+	}
+	if m.Len() != 2 {
+		t.Errorf("len = %d, want 2", m.Len())
+	}
+}
+
+func TestFileCache(t *testing.T) {
+	ctx := context.Background()
+	f := provider.NewFileCache(t.TempDir() + "/cache")
+
+	if _, ok, err := f.Get(ctx, "missing"); ok || err != nil {
+		t.Fatalf("missing: ok = %v, err = %v", ok, err)
+	}
+
+	p := provider.Cache(script(toolReply), provider.CachePolicy{Store: f})
+	stream(t, p, deterministic)
+
+	// A new wrapper over the same directory serves the stored response.
+	inner := script(reply("Hi"))
+	_, resp := stream(t, provider.Cache(inner, provider.CachePolicy{Store: provider.NewFileCache(f.Dir)}), deterministic)
+	if inner.Calls() != 0 || resp.Message.Content != "Let me check." || len(resp.ToolCalls) != 1 || resp.Usage.TotalTokenCount != 15 {
+		t.Errorf("resp = %+v after %d calls", resp, inner.Calls())
+	}
+}
@@ -0,0 +1,272 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/sburchfield/go-assistant-api/assistant"
+)
+
+type tenantKey struct{}
+
+// WithTenant returns a context whose requests are queued as tenant by a
+// ConcurrencyLimit provider.
+func WithTenant(ctx context.Context, tenant string) context.Context {
+	return context.WithValue(ctx, tenantKey{}, tenant)
+}
+
+// TenantFromContext returns the tenant set with WithTenant, or "".
+func TenantFromContext(ctx context.Context) string {
+	tenant, _ := ctx.Value(tenantKey{}).(string)
+	return tenant
+}
+
+// ConcurrencyPolicy configures ConcurrencyLimit. Zero fields select the
+// defaults.
+type ConcurrencyPolicy struct {
+	// MaxConcurrent is the number of requests in flight at once. Defaults
+	// to 10.
+	MaxConcurrent int
+	// Weights sets each tenant's share of the slots while several tenants
+	// are waiting. Tenants without an entry, including "", weigh 1.
+	Weights map[string]int
+	// QueueTimeout bounds how long a request waits for a slot before failing
+	// with a *QueueTimeoutError. Zero waits until the request's context is
+	// done.
+	QueueTimeout time.Duration
+	// OnDequeue, if set, is called when a request leaves the queue, with how
+	// long it waited and a nil err if it was admitted.
+	OnDequeue func(tenant string, waited time.Duration, err error)
+}
+
+// QueueTimeoutError is returned when a request waited QueueTimeout without
+// getting a slot. HTTP servers usually answer it with 503 Service Unavailable
+// or 429 Too Many Requests.
+type QueueTimeoutError struct {
+	Provider string
+	Tenant   string
+	Waited   time.Duration
+}
+
+func (e *QueueTimeoutError) Error() string {
+	return fmt.Sprintf("no free slot on %s for tenant %q after %s", e.Provider, e.Tenant, e.Waited)
+}
+
+// ConcurrencyLimit wraps p so that at most policy.MaxConcurrent requests are
+// in flight; a stream holds its slot until it ends. Waiting requests are
+// admitted in weighted fair order across the tenants set with WithTenant, so
+// a tenant with many queued requests cannot starve the others.
+func ConcurrencyLimit(p ChatProvider, policy ConcurrencyPolicy) ChatProvider {
+	if policy.MaxConcurrent <= 0 {
+		policy.MaxConcurrent = 10
+	}
+	c := &concurrencyProvider{
+		inner:   p,
+		name:    providerName(p),
+		policy:  policy,
+		tenants: map[string]*tenantQueue{},
+	}
+	c.streamMethods = streamMethods{c.ChatStreamEvents}
+	return c
+}
+
+type concurrencyProvider struct {
+	streamMethods
+	inner  ChatProvider
+	name   string
+	policy ConcurrencyPolicy
+
+	mu       sync.Mutex
+	inFlight int
+	queued   int
+	// vtime is the start tag of the last admitted request. Requests are
+	// tagged on arrival and admitted lowest tag first (start-time fair
+	// queueing), so each tenant's share follows its weight. Tags only matter
+	// while requests are queued, so tenants are forgotten once none are.
+	vtime   float64
+	tenants map[string]*tenantQueue
+}
+
+type tenantQueue struct {
+	waiters []*waiter
+	cost    float64 // 1/weight
+	// served is the finish tag of the tenant's last admitted request and
+	// lastFinish that of its last queued one.
+	served     float64
+	lastFinish float64
+}
+
+type waiter struct {
+	arrival  float64 // vtime when the request arrived
+	start    float64
+	finish   float64
+	ready    chan struct{}
+	admitted bool
+}
+
+func (c *concurrencyProvider) Name() string { return c.name }
+
+func (c *concurrencyProvider) ChatStreamEvents(ctx context.Context, messages []assistant.Message, tools []assistant.Tool, toolChoice assistant.ToolChoice, opts ...assistant.GenerationOption) (<-chan assistant.Event, error) {
+	if err := c.acquire(ctx); err != nil {
+		return nil, err
+	}
+
+	events, err := c.inner.ChatStreamEvents(ctx, messages, tools, toolChoice, opts...)
+	if err != nil {
+		c.release()
+		return nil, err
+	}
+
+	out := make(chan assistant.Event)
+	go func() {
+		defer close(out)
+		defer c.release()
+		for ev := range events {
+			if !assistant.SendEvent(ctx, out, ev) {
+				return
+			}
+		}
+	}()
+	return out, nil
+}
+
+func (c *concurrencyProvider) Chat(ctx context.Context, messages []assistant.Message, opts assistant.ChatOptions) (*assistant.Response, error) {
+	if err := c.acquire(ctx); err != nil {
+		return nil, err
+	}
+	defer c.release()
+	return c.inner.Chat(ctx, messages, opts)
+}
+
+// acquire waits for a slot for the tenant of ctx.
+func (c *concurrencyProvider) acquire(ctx context.Context) error {
+	tenant := TenantFromContext(ctx)
+	weight := 1
+	if w, ok := c.policy.Weights[tenant]; ok && w > 0 {
+		weight = w
+	}
+
+	c.mu.Lock()
+	t, ok := c.tenants[tenant]
+	if !ok {
+		t = &tenantQueue{cost: 1 / float64(weight), served: c.vtime, lastFinish: c.vtime}
+		c.tenants[tenant] = t
+	}
+	w := &waiter{arrival: c.vtime, start: max(c.vtime, t.lastFinish), ready: make(chan struct{})}
+	w.finish = w.start + t.cost
+	t.lastFinish = w.finish
+
+	if c.inFlight < c.policy.MaxConcurrent && c.queued == 0 {
+		t.served = w.finish
+		c.vtime = w.start
+		c.inFlight++
+		c.mu.Unlock()
+		c.dequeued(tenant, 0, nil)
+		return nil
+	}
+	t.waiters = append(t.waiters, w)
+	c.queued++
+	c.mu.Unlock()
+
+	enqueued := time.Now()
+	var timeout <-chan time.Time
+	if c.policy.QueueTimeout > 0 {
+		timer := time.NewTimer(c.policy.QueueTimeout)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
+	var err error
+	select {
+	case <-w.ready:
+	case <-ctx.Done():
+		err = fmt.Errorf("waiting for a free slot: %w", ctx.Err())
+	case <-timeout:
+		err = &QueueTimeoutError{Provider: c.name, Tenant: tenant, Waited: time.Since(enqueued)}
+	}
+	if err != nil {
+		c.mu.Lock()
+		if w.admitted {
+			// The slot arrived as we gave up; take it after all.
+			err = nil
+		} else {
+			c.remove(t, w)
+		}
+		c.mu.Unlock()
+	}
+	c.dequeued(tenant, time.Since(enqueued), err)
+	return err
+}
+
+// release frees a slot and hands it to the next waiting request.
+func (c *concurrencyProvider) release() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.inFlight--
+	for c.inFlight < c.policy.MaxConcurrent && c.queued > 0 {
+		var next *tenantQueue
+		for name, t := range c.tenants {
+			switch {
+			case len(t.waiters) == 0:
+				// An idle tenant that has caught up keeps no state.
+				if t.lastFinish <= c.vtime {
+					delete(c.tenants, name)
+				}
+			case next == nil || t.waiters[0].start < next.waiters[0].start:
+				next = t
+			}
+		}
+		w := next.waiters[0]
+		next.waiters = next.waiters[1:]
+		next.served = w.finish
+		c.queued--
+		c.vtime = w.start
+		c.inFlight++
+		w.admitted = true
+		close(w.ready)
+	}
+	c.pruneIdle()
+}
+
+// remove takes w, which gave up waiting, out of t's queue. The tenant is not
+// charged for it: the requests queued behind it are tagged again as if it
+// had never arrived.
+func (c *concurrencyProvider) remove(t *tenantQueue, w *waiter) {
+	for i, queued := range t.waiters {
+		if queued != w {
+			continue
+		}
+		t.waiters = append(t.waiters[:i], t.waiters[i+1:]...)
+		c.queued--
+
+		prev := t.served
+		if i > 0 {
+			prev = t.waiters[i-1].finish
+		}
+		for _, later := range t.waiters[i:] {
+			later.start = max(later.arrival, prev)
+			later.finish = later.start + t.cost
+			prev = later.finish
+		}
+		t.lastFinish = prev
+		break
+	}
+	c.pruneIdle()
+}
+
+// pruneIdle forgets every tenant once nothing is queued, so the map only
+// holds the tenants seen since the queue last drained.
+func (c *concurrencyProvider) pruneIdle() {
+	if c.queued == 0 {
+		clear(c.tenants)
+	}
+}
+
+func (c *concurrencyProvider) dequeued(tenant string, waited time.Duration, err error) {
+	if c.policy.OnDequeue != nil {
+		c.policy.OnDequeue(tenant, waited, err)
+	}
+}
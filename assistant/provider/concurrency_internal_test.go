@@ -0,0 +1,23 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"testing"
+)
+
+func TestConcurrencyLimit_ForgetsIdleTenants(t *testing.T) {
+	c := ConcurrencyLimit(nil, ConcurrencyPolicy{MaxConcurrent: 2}).(*concurrencyProvider)
+
+	for i := 0; i < 100; i++ {
+		if err := c.acquire(WithTenant(context.Background(), fmt.Sprint("tenant-", i))); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		c.release()
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.tenants) != 0 {
+		t.Errorf("%d tenants kept after every request finished, want 0", len(c.tenants))
+	}
+}
@@ -0,0 +1,187 @@
+package provider_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sburchfield/go-assistant-api/assistant"
+	"github.com/sburchfield/go-assistant-api/assistant/provider"
+)
+
+// gatedProvider records the tenant of each request. Its first stream does not
+// finish until the gate is closed; the rest reply at once.
+type gatedProvider struct {
+	provider.ChatProvider
+	gate chan struct{}
+
+	mu      sync.Mutex
+	tenants []string
+}
+
+func (g *gatedProvider) ChatStreamEvents(ctx context.Context, messages []assistant.Message, tools []assistant.Tool, toolChoice assistant.ToolChoice, opts ...assistant.GenerationOption) (<-chan assistant.Event, error) {
+	g.mu.Lock()
+	g.tenants = append(g.tenants, provider.TenantFromContext(ctx))
+	first := len(g.tenants) == 1
+	g.mu.Unlock()
+
+	out := make(chan assistant.Event)
+	go func() {
+		defer close(out)
+		if first {
+			<-g.gate
+		}
+		assistant.SendEvent(ctx, out, assistant.Event{Type: assistant.EventTextDelta, Text: "Hi"})
+	}()
+	return out, nil
+}
+
+func (g *gatedProvider) Tenants() []string {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return append([]string(nil), g.tenants...)
+}
+
+// request streams a response for tenant and reports the outcome on errs.
+func request(p provider.ChatProvider, tenant string, errs chan<- error) {
+	events, err := p.ChatStreamEvents(provider.WithTenant(context.Background(), tenant), hello, nil, "")
+	if err == nil {
+		_, err = assistant.Collect(events)
+	}
+	errs <- err
+}
+
+func TestConcurrencyLimit_WeightedFairQueue(t *testing.T) {
+	inner := &gatedProvider{gate: make(chan struct{})}
+	p := provider.ConcurrencyLimit(inner, provider.ConcurrencyPolicy{
+		MaxConcurrent: 1,
+		Weights:       map[string]int{"chat": 3},
+	})
+
+	errs := make(chan error)
+	go request(p, "batch", errs)
+	for len(inner.Tenants()) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	// The batch tenant queues four more requests before chat queues two.
+	for i := 0; i < 4; i++ {
+		go request(p, "batch", errs)
+	}
+	for i := 0; i < 2; i++ {
+		go request(p, "chat", errs)
+	}
+	time.Sleep(50 * time.Millisecond)
+	if got := inner.Tenants(); len(got) != 1 {
+		t.Fatalf("%d requests in flight, want 1", len(got))
+	}
+
+	close(inner.gate)
+	for i := 0; i < 7; i++ {
+		if err := <-errs; err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	want := []string{"batch", "chat", "chat", "batch", "batch", "batch", "batch"}
+	got := inner.Tenants()
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("admission order = %v, want %v", got, want)
+		}
+	}
+}
+
+func TestConcurrencyLimit_QueueTimeout(t *testing.T) {
+	inner := &gatedProvider{gate: make(chan struct{})}
+
+	type dequeue struct {
+		tenant string
+		waited time.Duration
+		err    error
+	}
+	var mu sync.Mutex
+	var dequeues []dequeue
+	p := provider.ConcurrencyLimit(provider.Named("openai", inner), provider.ConcurrencyPolicy{
+		MaxConcurrent: 1,
+		QueueTimeout:  20 * time.Millisecond,
+		OnDequeue: func(tenant string, waited time.Duration, err error) {
+			mu.Lock()
+			defer mu.Unlock()
+			dequeues = append(dequeues, dequeue{tenant, waited, err})
+		},
+	})
+
+	// The first request takes the only slot and keeps it.
+	held, err := p.ChatStreamEvents(context.Background(), hello, nil, "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer func() {
+		close(inner.gate)
+		for range held {
+		}
+	}()
+
+	_, err = p.ChatStreamEvents(provider.WithTenant(context.Background(), "acme"), hello, nil, "")
+	var timeoutErr *provider.QueueTimeoutError
+	if !errors.As(err, &timeoutErr) || timeoutErr.Provider != "openai" || timeoutErr.Tenant != "acme" || timeoutErr.Waited < 20*time.Millisecond {
+		t.Fatalf("err = %v, want QueueTimeoutError for acme", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := p.Chat(ctx, hello, assistant.ChatOptions{}); !errors.Is(err, context.Canceled) || errors.As(err, &timeoutErr) {
+		t.Errorf("cancelled: err = %v, want context.Canceled", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(dequeues) != 3 || dequeues[0].err != nil || dequeues[0].waited != 0 {
+		t.Fatalf("dequeues = %+v", dequeues)
+	}
+	if dequeues[1].tenant != "acme" || !errors.As(dequeues[1].err, &timeoutErr) || dequeues[1].waited < 20*time.Millisecond {
+		t.Errorf("timed out dequeue = %+v", dequeues[1])
+	}
+}
+
+func TestConcurrencyLimit_AbandonedRequestsAreNotCharged(t *testing.T) {
+	inner := &gatedProvider{gate: make(chan struct{})}
+	p := provider.ConcurrencyLimit(inner, provider.ConcurrencyPolicy{MaxConcurrent: 1})
+
+	held, err := p.ChatStreamEvents(context.Background(), hello, nil, "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	errs := make(chan error)
+	for i := 0; i < 2; i++ {
+		go request(p, "b", errs)
+	}
+	time.Sleep(20 * time.Millisecond)
+	// Tenant a gives up on three requests, then queues one it keeps.
+	for i := 0; i < 3; i++ {
+		ctx, cancel := context.WithTimeout(provider.WithTenant(context.Background(), "a"), 5*time.Millisecond)
+		if _, err := p.Chat(ctx, hello, assistant.ChatOptions{}); !errors.Is(err, context.DeadlineExceeded) {
+			t.Fatalf("err = %v, want context.DeadlineExceeded", err)
+		}
+		cancel()
+	}
+	go request(p, "a", errs)
+	time.Sleep(20 * time.Millisecond)
+
+	close(inner.gate)
+	for range held {
+	}
+	for i := 0; i < 3; i++ {
+		if err := <-errs; err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	// a is admitted before b's second request, as if its abandoned requests
+	// had never been queued.
+	got := inner.Tenants()
+	if len(got) != 4 || got[3] != "b" {
+		t.Errorf("admission order = %v, want a before b's second request", got)
+	}
+}
@@ -6,104 +6,116 @@ import (
 	"fmt"
 	"os"
 	"strconv"
-
-	"github.com/aws/aws-sdk-go-v2/config"
-	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
-	"github.com/sburchfield/go-assistant-api/assistant/provider/bedrock"
-	"github.com/sburchfield/go-assistant-api/assistant/provider/gemini"
-	"github.com/sburchfield/go-assistant-api/assistant/provider/openai"
+	"strings"
+	"time"
 )
 
-// getSecretFromAWS retrieves a secret from AWS Secrets Manager
-func getSecretFromAWS(ctx context.Context, secretName string) (string, error) {
-	cfg, err := config.LoadDefaultConfig(ctx)
-	if err != nil {
-		return "", fmt.Errorf("unable to load SDK config: %w", err)
-	}
+// envAliases maps provider options to environment variables that predate the
+// <NAME>_<OPTION> convention.
+var envAliases = map[string]map[string]string{
+	"openai": {
+		"azure_endpoint":    "AZURE_OPENAI_ENDPOINT",
+		"azure_deployment":  "AZURE_OPENAI_DEPLOYMENT",
+		"azure_api_version": "AZURE_OPENAI_API_VERSION",
+		"azure_api_key":     "AZURE_OPENAI_API_KEY",
+		"azure_ad_token":    "AZURE_OPENAI_AD_TOKEN",
+	},
+	"bedrock": {
+		"region": "AWS_REGION",
+	},
+}
 
-	client := secretsmanager.NewFromConfig(cfg)
-	result, err := client.GetSecretValue(ctx, &secretsmanager.GetSecretValueInput{
-		SecretId: &secretName,
-	})
+// NewProviderFromEnv builds the provider named by LLM_PROVIDER, configured by
+// ConfigFromEnv.
+func NewProviderFromEnv() (ChatProvider, error) {
+	name := os.Getenv("LLM_PROVIDER")
+	cfg, err := ConfigFromEnv(name)
 	if err != nil {
-		return "", fmt.Errorf("failed to get secret: %w", err)
-	}
-
-	if result.SecretString != nil {
-		return *result.SecretString, nil
+		return nil, err
 	}
-
-	return "", fmt.Errorf("secret is not a string")
+	return New(context.Background(), name, cfg)
 }
 
-func NewProviderFromEnv() (ChatProvider, error) {
-	provider := os.Getenv("LLM_PROVIDER")
-	temperature := float32(0) // Default temperature
-	switch provider {
-	case "openai":
-		apiKey := os.Getenv("OPENAI_API_KEY")
-		model := os.Getenv("OPENAI_MODEL")
-		temperatureStr := os.Getenv("TEMPERATURE")
-		if temperatureStr != "" {
-			if t, err := strconv.ParseFloat(temperatureStr, 32); err == nil {
-				temperature = float32(t)
-			}
+// ConfigFromEnv builds the Config for the provider registered under name from
+// environment variables prefixed with the upper-cased name, e.g. for "openai":
+//
+//	OPENAI_MODEL, OPENAI_API_KEY, OPENAI_BASE_URL -> Model, APIKey, BaseURL
+//	OPENAI_API_KEY_SECRET                         -> APIKeySource
+//	OPENAI_ORG_ID                                 -> Options["org_id"]
+//
+// <NAME>_API_KEY_SECRET is a secret reference (see ParseSecretSource). It and
+// any secrets the provider reads itself are cached for SECRET_TTL (a duration,
+// default DefaultSecretTTL). TEMPERATURE sets the temperature for every
+// provider.
+func ConfigFromEnv(name string) (Config, error) {
+	prefix := envPrefix(name)
+	cfg := Config{
+		Model:   os.Getenv(prefix + "MODEL"),
+		APIKey:  os.Getenv(prefix + "API_KEY"),
+		BaseURL: os.Getenv(prefix + "BASE_URL"),
+		Options: map[string]string{},
+	}
+	if t, err := strconv.ParseFloat(os.Getenv("TEMPERATURE"), 32); err == nil {
+		temperature := float32(t)
+		cfg.Temperature = &temperature
+	}
+	ttl, err := parseSecretTTL(os.Getenv("SECRET_TTL"))
+	if err != nil {
+		return Config{}, fmt.Errorf("SECRET_TTL: %w", err)
+	}
+	cfg.SecretTTL = ttl
+	if ref := os.Getenv(prefix + "API_KEY_SECRET"); ref != "" {
+		src, err := ParseSecretSource(ref)
+		if err != nil {
+			return Config{}, fmt.Errorf("%sAPI_KEY_SECRET: %w", prefix, err)
 		}
+		cfg.APIKeySource = CachedSecret(src, cfg.SecretTTL)
+	}
 
-		if apiKey == "" || model == "" {
-			return nil, fmt.Errorf("missing OPENAI_API_KEY or OPENAI_MODEL")
+	for _, kv := range os.Environ() {
+		key, value, _ := strings.Cut(kv, "=")
+		option, ok := strings.CutPrefix(key, prefix)
+		if !ok || value == "" {
+			continue
 		}
-		return openai.NewClient(apiKey, model, temperature), nil
-	case "gemini":
-		ctx := context.Background()
-		projectID := os.Getenv("GEMINI_PROJECT_ID")
-		location := os.Getenv("GEMINI_LOCATION")
-		model := os.Getenv("GEMINI_MODEL")
-		temperatureStr := os.Getenv("TEMPERATURE")
-		if temperatureStr != "" {
-			if t, err := strconv.ParseFloat(temperatureStr, 32); err == nil {
-				temperature = float32(t)
-			}
+		switch option {
+		case "MODEL", "API_KEY", "API_KEY_SECRET", "BASE_URL":
+		default:
+			cfg.Options[strings.ToLower(option)] = value
 		}
-
-		if projectID == "" || location == "" || model == "" {
-			return nil, fmt.Errorf("missing GEMINI_PROJECT_ID, GEMINI_LOCATION, or GEMINI_MODEL")
-		}
-
-		// Check if we should use AWS Secrets Manager for credentials
-		secretName := os.Getenv("GEMINI_SECRET_NAME")
-		var credentialsJSON string
-
-		if secretName != "" {
-			// Fetch from AWS Secrets Manager
-			secret, err := getSecretFromAWS(ctx, secretName)
-			if err != nil {
-				return nil, fmt.Errorf("failed to fetch GCP credentials from AWS Secrets Manager: %w", err)
-			}
-			credentialsJSON = secret
+	}
+	for option, key := range envAliases[name] {
+		if value := os.Getenv(key); value != "" {
+			cfg.Options[option] = value
 		}
+	}
+	return cfg, nil
+}
 
-		return gemini.NewClient(ctx, projectID, location, model, temperature, credentialsJSON)
-	case "bedrock":
-		ctx := context.Background()
-		region := os.Getenv("AWS_REGION")
-		model := os.Getenv("BEDROCK_MODEL")
-		temperatureStr := os.Getenv("TEMPERATURE")
-		if temperatureStr != "" {
-			if t, err := strconv.ParseFloat(temperatureStr, 32); err == nil {
-				temperature = float32(t)
-			}
-		}
+// parseSecretTTL parses a secret cache duration; an empty ttl parses as zero,
+// which selects DefaultSecretTTL.
+func parseSecretTTL(ttl string) (time.Duration, error) {
+	if ttl == "" {
+		return 0, nil
+	}
+	d, err := time.ParseDuration(ttl)
+	if err != nil {
+		return 0, fmt.Errorf("invalid secret TTL %q: %w", ttl, err)
+	}
+	return d, nil
+}
 
-		if region == "" {
-			region = "us-east-1" // Default region
+// envPrefix returns the environment variable prefix for a provider name:
+// upper-cased, with anything other than letters and digits replaced by '_'.
+func envPrefix(name string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z':
+			return r - 'a' + 'A'
+		case r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+			return r
+		default:
+			return '_'
 		}
-		if model == "" {
-			return nil, fmt.Errorf("missing BEDROCK_MODEL")
-		}
-
-		return bedrock.NewClient(ctx, region, model, temperature)
-	default:
-		return nil, fmt.Errorf("unsupported provider: %s", provider)
-	}
+	}, name) + "_"
 }
@@ -0,0 +1,154 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/sburchfield/go-assistant-api/assistant"
+)
+
+// Named gives p a name, reported as the serving provider by Fallback.
+func Named(name string, p ChatProvider) ChatProvider {
+	return &namedProvider{ChatProvider: p, name: name}
+}
+
+type namedProvider struct {
+	ChatProvider
+	name string
+}
+
+func (n *namedProvider) Name() string { return n.name }
+
+// providerName returns the name given to p with Named, or its type.
+func providerName(p ChatProvider) string {
+	if n, ok := p.(interface{ Name() string }); ok {
+		return n.Name()
+	}
+	return fmt.Sprintf("%T", p)
+}
+
+// Fallback returns a provider that sends each request to primary and, if it
+// cannot serve it, to each of secondaries in turn. A backend is skipped when
+// it fails to start a stream, or when its stream or Chat call fails with a
+// retryable error (see IsRetryable) or is refused by an open circuit or a
+// rate limit, before producing any output; once output has been delivered,
+// errors are passed through as they are.
+//
+// An assistant.EventMetadata event following the first event of the stream,
+// and the Metadata of Chat responses, name the backend that served the
+// request. Give backends readable names with Named.
+func Fallback(primary ChatProvider, secondaries ...ChatProvider) ChatProvider {
+	f := &fallbackProvider{providers: append([]ChatProvider{primary}, secondaries...)}
+	f.streamMethods = streamMethods{f.ChatStreamEvents}
+	return f
+}
+
+type fallbackProvider struct {
+	streamMethods
+	providers []ChatProvider
+}
+
+func (f *fallbackProvider) ChatStreamEvents(ctx context.Context, messages []assistant.Message, tools []assistant.Tool, toolChoice assistant.ToolChoice, opts ...assistant.GenerationOption) (<-chan assistant.Event, error) {
+	next := 0
+	var errs []error
+	cancel := context.CancelFunc(func() {})
+
+	// open starts a stream on the next backend that accepts the request. Each
+	// backend gets its own context so an abandoned stream stops producing.
+	open := func() (<-chan assistant.Event, string, error) {
+		for next < len(f.providers) && ctx.Err() == nil {
+			p := f.providers[next]
+			next++
+			cancel()
+			var attemptCtx context.Context
+			attemptCtx, cancel = context.WithCancel(ctx)
+			events, err := p.ChatStreamEvents(attemptCtx, messages, tools, toolChoice, opts...)
+			if err == nil {
+				return events, providerName(p), nil
+			}
+			errs = append(errs, fmt.Errorf("%s: %w", providerName(p), err))
+		}
+		cancel()
+		if err := ctx.Err(); err != nil {
+			errs = append(errs, err)
+		}
+		return nil, "", fmt.Errorf("all providers failed: %w", errors.Join(errs...))
+	}
+
+	events, name, err := open()
+	if err != nil {
+		return nil, err
+	}
+
+	out := make(chan assistant.Event)
+	go func() {
+		defer close(out)
+		defer func() { cancel() }()
+
+		for {
+			ev, ok := <-events
+			if ok && ev.Type == assistant.EventError && next < len(f.providers) {
+				if failOver(ev.Err) {
+					errs = append(errs, fmt.Errorf("%s: %w", name, ev.Err))
+					if events, name, err = open(); err != nil {
+						assistant.SendEvent(ctx, out, assistant.Event{Type: assistant.EventError, Err: err})
+						return
+					}
+					continue
+				}
+			}
+
+			// This backend is serving the request: pass its first event
+			// through, so wrappers such as Retry still see an error first,
+			// then name it and pass the rest of the stream through.
+			if ok && !assistant.SendEvent(ctx, out, ev) {
+				return
+			}
+			metadata := assistant.Event{Type: assistant.EventMetadata, Metadata: &assistant.StreamMetadata{Provider: name}}
+			if !assistant.SendEvent(ctx, out, metadata) || !ok {
+				return
+			}
+			for ev := range events {
+				if !assistant.SendEvent(ctx, out, ev) {
+					return
+				}
+			}
+			return
+		}
+	}()
+	return out, nil
+}
+
+func (f *fallbackProvider) Chat(ctx context.Context, messages []assistant.Message, opts assistant.ChatOptions) (*assistant.Response, error) {
+	var errs []error
+	for _, p := range f.providers {
+		resp, err := p.Chat(ctx, messages, opts)
+		if err == nil {
+			if resp.Metadata == nil {
+				resp.Metadata = &assistant.StreamMetadata{Provider: providerName(p)}
+			}
+			return resp, nil
+		}
+		if !failOver(err) {
+			return nil, err
+		}
+		errs = append(errs, fmt.Errorf("%s: %w", providerName(p), err))
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	return nil, fmt.Errorf("all providers failed: %w", errors.Join(errs...))
+}
+
+// failOver reports whether a request that failed with err should go to the
+// next backend: the failure is transient, or a local wrapper refused it.
+func failOver(err error) bool {
+	var openErr *CircuitOpenError
+	var limitErr *RateLimitError
+	if errors.As(err, &openErr) || errors.As(err, &limitErr) {
+		return true
+	}
+	retryable, _ := IsRetryable(err)
+	return retryable
+}
@@ -0,0 +1,169 @@
+package provider_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sburchfield/go-assistant-api/assistant"
+	"github.com/sburchfield/go-assistant-api/assistant/provider"
+)
+
+// recordingProvider records the messages and tools of each request.
+type recordingProvider struct {
+	*scriptedProvider
+	messages [][]assistant.Message
+	tools    [][]assistant.Tool
+}
+
+func (r *recordingProvider) ChatStreamEvents(ctx context.Context, messages []assistant.Message, tools []assistant.Tool, toolChoice assistant.ToolChoice, opts ...assistant.GenerationOption) (<-chan assistant.Event, error) {
+	r.messages = append(r.messages, messages)
+	r.tools = append(r.tools, tools)
+	return r.scriptedProvider.ChatStreamEvents(ctx, messages, tools, toolChoice, opts...)
+}
+
+func TestFallback_StartError(t *testing.T) {
+	primary := script(step{err: errors.New("dial tcp: connection refused")})
+	secondary := &recordingProvider{scriptedProvider: script(reply("From backup"))}
+
+	tools := []assistant.Tool{{Type: "function", Function: assistant.ToolFunction{Name: "lookup"}}}
+	events, err := provider.Fallback(provider.Named("bedrock", primary), provider.Named("openai", secondary)).
+		ChatStreamEvents(context.Background(), hello, tools, assistant.ToolChoiceAuto)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	resp, err := assistant.Collect(events)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if resp.Message.Content != "From backup" {
+		t.Errorf("content = %q, want From backup", resp.Message.Content)
+	}
+	if resp.Metadata == nil || resp.Metadata.Provider != "openai" {
+		t.Errorf("metadata = %+v, want provider openai", resp.Metadata)
+	}
+	// The secondary receives the same conversation and tools.
+	if len(secondary.messages) != 1 || secondary.messages[0][0].Content != "Hello" || secondary.tools[0][0].Function.Name != "lookup" {
+		t.Errorf("secondary got messages %v and tools %v", secondary.messages, secondary.tools)
+	}
+}
+
+func TestFallback_RetryableErrorBeforeOutput(t *testing.T) {
+	primary := script(streamError(errRateLimited))
+	secondary := script(reply("From backup"))
+
+	stream, err := provider.Fallback(provider.Named("primary", primary), provider.Named("secondary", secondary)).
+		ChatStreamWithUsage(context.Background(), hello)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var text strings.Builder
+	for chunk := range stream.TextChannel {
+		text.WriteString(chunk)
+	}
+
+	if text.String() != "From backup" || stream.Err() != nil {
+		t.Errorf("text = %q, err = %v; want From backup", text.String(), stream.Err())
+	}
+	if md := stream.GetMetadata(); md == nil || md.Provider != "secondary" {
+		t.Errorf("metadata = %+v, want provider secondary", md)
+	}
+}
+
+func TestFallback_ServedByPrimary(t *testing.T) {
+	primary := script(reply("From primary"))
+	secondary := script(reply("From backup"))
+
+	resp, err := collect(t, provider.Fallback(primary, secondary))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if resp.Message.Content != "From primary" || secondary.Calls() != 0 {
+		t.Errorf("content = %q, secondary calls = %d; want primary only", resp.Message.Content, secondary.Calls())
+	}
+	// Unnamed providers are identified by type.
+	if resp.Metadata == nil || resp.Metadata.Provider != "*provider_test.scriptedProvider" {
+		t.Errorf("metadata = %+v", resp.Metadata)
+	}
+}
+
+func TestFallback_NoFailoverAfterOutputOrForFatalErrors(t *testing.T) {
+	partial := script(step{events: []assistant.Event{
+		{Type: assistant.EventTextDelta, Text: "Partial"},
+		{Type: assistant.EventError, Err: errRateLimited},
+	}})
+	secondary := script(reply("From backup"))
+	resp, err := collect(t, provider.Fallback(partial, secondary))
+	if !errors.Is(err, errRateLimited) || resp.Message.Content != "Partial" || secondary.Calls() != 0 {
+		t.Errorf("after output: content = %q, err = %v, secondary calls = %d", resp.Message.Content, err, secondary.Calls())
+	}
+
+	fatal := script(streamError(errBadRequest))
+	secondary = script(reply("From backup"))
+	if _, err := collect(t, provider.Fallback(fatal, secondary)); !errors.Is(err, errBadRequest) || secondary.Calls() != 0 {
+		t.Errorf("non-retryable: err = %v, secondary calls = %d", err, secondary.Calls())
+	}
+}
+
+func TestFallback_AllFail(t *testing.T) {
+	errDown := errors.New("connection refused")
+	p := provider.Fallback(
+		provider.Named("a", script(step{err: errDown})),
+		provider.Named("b", script(streamError(errRateLimited))),
+		provider.Named("c", script(step{err: errBadRequest})),
+	)
+
+	_, err := collect(t, p)
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	for _, want := range []error{errDown, errRateLimited, errBadRequest} {
+		if !errors.Is(err, want) {
+			t.Errorf("error %q does not wrap %q", err, want)
+		}
+	}
+	if !strings.Contains(err.Error(), "a: ") || !strings.Contains(err.Error(), "c: ") {
+		t.Errorf("error %q should name each provider", err)
+	}
+}
+
+func TestFallback_Chat(t *testing.T) {
+	p := provider.Fallback(
+		provider.Named("primary", script(step{err: errRateLimited})),
+		provider.Named("secondary", script(reply("From backup"))),
+	)
+
+	resp, err := p.Chat(context.Background(), hello, assistant.ChatOptions{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if resp.Message.Content != "From backup" || resp.Metadata == nil || resp.Metadata.Provider != "secondary" {
+		t.Errorf("resp = %+v, metadata = %+v", resp, resp.Metadata)
+	}
+}
+
+func TestFallback_ChatFatalError(t *testing.T) {
+	secondary := script(reply("From backup"))
+	p := provider.Fallback(script(step{err: errBadRequest}), secondary)
+
+	if _, err := p.Chat(context.Background(), hello, assistant.ChatOptions{}); !errors.Is(err, errBadRequest) || secondary.Calls() != 0 {
+		t.Errorf("err = %v, secondary calls = %d; want errBadRequest without failing over", err, secondary.Calls())
+	}
+}
+
+func TestFallback_RetriesWhenAllFail(t *testing.T) {
+	primary := script(step{err: errRateLimited}, reply("From primary"))
+	secondary := script(streamError(errRateLimited), reply("From backup"))
+
+	var delays []time.Duration
+	resp, err := collect(t, provider.Retry(provider.Fallback(provider.Named("primary", primary), secondary), fastRetry(&delays)))
+	if err != nil || resp.Message.Content != "From primary" || len(delays) != 1 {
+		t.Fatalf("content = %q, err = %v after %d retries; want From primary after 1", resp.Message.Content, err, len(delays))
+	}
+	if resp.Metadata == nil || resp.Metadata.Provider != "primary" {
+		t.Errorf("metadata = %+v, want provider primary", resp.Metadata)
+	}
+}
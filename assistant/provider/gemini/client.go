@@ -2,12 +2,17 @@ package gemini
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/json"
 	"errors"
 	"fmt"
-	"log"
-	"os"
+	"net/http"
+	"strings"
 	"sync"
+	"time"
 
+	"cloud.google.com/go/auth"
+	"cloud.google.com/go/auth/credentials"
 	"github.com/sburchfield/go-assistant-api/assistant"
 	"google.golang.org/genai"
 )
@@ -18,56 +23,186 @@ type Client struct {
 	temperature float32
 }
 
-func NewClient(ctx context.Context, projectID, location, modelID string, temperature float32, credentialsJSON string) (*Client, error) {
-	// If credentials JSON is provided (from AWS Secrets Manager), write to temp file
-	// and set GOOGLE_APPLICATION_CREDENTIALS env var
-	var cleanupFunc func()
+// Option customizes the genai client configuration built by NewClient.
+type Option func(*genai.ClientConfig)
 
-	if credentialsJSON != "" {
-		tmpFile, err := os.CreateTemp("", "gcp-credentials-*.json")
-		if err != nil {
-			return nil, fmt.Errorf("failed to create temp file for credentials: %w", err)
-		}
+// WithAPIKey switches the client to the Gemini Developer API (AI Studio)
+// backend, authenticating with apiKey instead of a GCP project. The project
+// and location passed to NewClient are ignored.
+func WithAPIKey(apiKey string) Option {
+	return func(cfg *genai.ClientConfig) {
+		cfg.Backend = genai.BackendGeminiAPI
+		cfg.APIKey = apiKey
+		cfg.Project = ""
+		cfg.Location = ""
+	}
+}
 
-		if _, err := tmpFile.WriteString(credentialsJSON); err != nil {
-			tmpFile.Close()
-			os.Remove(tmpFile.Name())
-			return nil, fmt.Errorf("failed to write credentials to temp file: %w", err)
+// WithCredentials authenticates Vertex AI requests with creds instead of
+// Application Default Credentials.
+func WithCredentials(creds *auth.Credentials) Option {
+	return func(cfg *genai.ClientConfig) {
+		cfg.Credentials = creds
+	}
+}
+
+// WithAPIKeyProvider is like WithAPIKey, but apiKey is called for every
+// request, so a rotated key is picked up without building a new client. Pass
+// it after any option that sets the HTTP client, which it wraps.
+func WithAPIKeyProvider(apiKey func(context.Context) (string, error)) Option {
+	return func(cfg *genai.ClientConfig) {
+		// genai refuses to build a client without a key; the transport
+		// replaces this one on every request.
+		WithAPIKey("unset")(cfg)
+		httpClient := &http.Client{}
+		if cfg.HTTPClient != nil {
+			*httpClient = *cfg.HTTPClient
 		}
-		tmpFile.Close()
+		httpClient.Transport = &apiKeyTransport{base: httpClient.Transport, apiKey: apiKey}
+		cfg.HTTPClient = httpClient
+	}
+}
+
+// apiKeyTransport sets a freshly fetched Gemini API key on each request.
+type apiKeyTransport struct {
+	base   http.RoundTripper
+	apiKey func(context.Context) (string, error)
+}
 
-		// Set environment variable temporarily
-		oldCreds := os.Getenv("GOOGLE_APPLICATION_CREDENTIALS")
-		os.Setenv("GOOGLE_APPLICATION_CREDENTIALS", tmpFile.Name())
+func (t *apiKeyTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	apiKey, err := t.apiKey(req.Context())
+	if err != nil {
+		return nil, fmt.Errorf("failed to get API key: %w", err)
+	}
+	req = req.Clone(req.Context())
+	req.Header.Set("x-goog-api-key", apiKey)
 
-		// Setup cleanup function
-		cleanupFunc = func() {
-			os.Remove(tmpFile.Name())
-			if oldCreds != "" {
-				os.Setenv("GOOGLE_APPLICATION_CREDENTIALS", oldCreds)
-			} else {
-				os.Unsetenv("GOOGLE_APPLICATION_CREDENTIALS")
-			}
+	base := t.base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return base.RoundTrip(req)
+}
+
+// WithCredentialsProvider authenticates Vertex AI requests with the service
+// account or external account JSON returned by credentialsJSON. It is called
+// whenever a token is needed and the credentials are parsed again when the
+// JSON changes, so rotated credentials are picked up; wrap it in a cache if
+// fetching is expensive.
+func WithCredentialsProvider(credentialsJSON func(context.Context) (string, error)) Option {
+	return WithCredentials(auth.NewCredentials(&auth.CredentialsOptions{
+		TokenProvider: &rotatingTokenProvider{credentialsJSON: credentialsJSON},
+	}))
+}
+
+// rotatingTokenProvider issues tokens from the latest credentials JSON.
+type rotatingTokenProvider struct {
+	credentialsJSON func(context.Context) (string, error)
+
+	mu    sync.Mutex
+	json  string
+	creds *auth.Credentials
+}
+
+func (p *rotatingTokenProvider) Token(ctx context.Context) (*auth.Token, error) {
+	credentialsJSON, err := p.credentialsJSON(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get GCP credentials: %w", err)
+	}
+
+	p.mu.Lock()
+	if credentialsJSON != p.json {
+		creds, err := parseCredentials(credentialsJSON)
+		if err != nil {
+			p.mu.Unlock()
+			return nil, err
 		}
+		p.json, p.creds = credentialsJSON, creds
 	}
+	creds := p.creds
+	p.mu.Unlock()
 
+	token, err := creds.Token(ctx)
+	if err != nil {
+		return nil, err
+	}
+	// The parsed credentials cache the token until it expires. The copy is
+	// reported as expired so that genai's own cache asks again on every
+	// request, which is when a rotated JSON is noticed.
+	rotating := *token
+	rotating.Expiry = time.Now()
+	return &rotating, nil
+}
+
+// cloudPlatformScope is the OAuth scope Vertex AI requests are authorized with.
+const cloudPlatformScope = "https://www.googleapis.com/auth/cloud-platform"
+
+// NewClient creates a Gemini client. By default it targets Vertex AI in the
+// given project and location; pass WithAPIKey to use the Gemini Developer API.
+//
+// credentialsJSON may hold a service account or external account (workload
+// identity federation) configuration. It is parsed in memory, so clients with
+// different identities can be created concurrently in one process. When it is
+// empty, Application Default Credentials are used.
+func NewClient(ctx context.Context, projectID, location, modelID string, temperature float32, credentialsJSON string, opts ...Option) (*Client, error) {
 	// Build client config
 	clientConfig := &genai.ClientConfig{
 		Project:  projectID,
 		Location: location,
 		Backend:  genai.BackendVertexAI,
 	}
+	for _, opt := range opts {
+		opt(clientConfig)
+	}
 
-	// Create the client (will use GOOGLE_APPLICATION_CREDENTIALS if set)
-	client, err := genai.NewClient(ctx, clientConfig)
+	if clientConfig.Backend != genai.BackendVertexAI {
+		if credentialsJSON != "" {
+			return nil, errors.New("credentials JSON is only supported with the Vertex AI backend")
+		}
+		return NewClientWithConfig(ctx, clientConfig, modelID, temperature)
+	}
 
-	// Clean up credentials file if we created one
-	if cleanupFunc != nil {
-		cleanupFunc()
+	if credentialsJSON != "" {
+		if clientConfig.Credentials != nil {
+			return nil, errors.New("credentials JSON cannot be combined with WithCredentials")
+		}
+		creds, err := parseCredentials(credentialsJSON)
+		if err != nil {
+			return nil, err
+		}
+		clientConfig.Credentials = creds
 	}
 
+	return NewClientWithConfig(ctx, clientConfig, modelID, temperature)
+}
+
+// parseCredentials parses a service account or external account
+// configuration. Other credential types, such as authorized users, are
+// rejected rather than trusted because the JSON may come from outside.
+func parseCredentials(credentialsJSON string) (*auth.Credentials, error) {
+	var file struct {
+		Type credentials.CredType `json:"type"`
+	}
+	if err := json.Unmarshal([]byte(credentialsJSON), &file); err != nil {
+		return nil, fmt.Errorf("failed to parse GCP credentials: %w", err)
+	}
+	if file.Type != credentials.ServiceAccount && file.Type != credentials.ExternalAccount {
+		return nil, fmt.Errorf("unsupported GCP credentials type %q: want %s or %s", file.Type, credentials.ServiceAccount, credentials.ExternalAccount)
+	}
+	creds, err := credentials.NewCredentialsFromJSON(file.Type, []byte(credentialsJSON), &credentials.DetectOptions{
+		Scopes: []string{cloudPlatformScope},
+	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to create vertex ai client: %w", err)
+		return nil, fmt.Errorf("failed to parse GCP credentials: %w", err)
+	}
+	return creds, nil
+}
+
+// NewClientWithConfig creates a new Gemini client from a pre-built genai client configuration.
+func NewClientWithConfig(ctx context.Context, clientConfig *genai.ClientConfig, modelID string, temperature float32) (*Client, error) {
+	client, err := genai.NewClient(ctx, clientConfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create gemini client: %w", err)
 	}
 
 	return &Client{
@@ -86,124 +221,366 @@ func (c *Client) ChatStreamWithTools(
 	messages []assistant.Message,
 	tools []assistant.Tool,
 	toolChoice assistant.ToolChoice,
+	opts ...assistant.GenerationOption,
 ) (<-chan string, error) {
+	events, err := c.ChatStreamEvents(ctx, messages, tools, toolChoice, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return assistant.TextWithToolCalls(ctx, events), nil
+}
+
+func (c *Client) ChatStreamWithUsage(ctx context.Context, messages []assistant.Message) (*assistant.StreamResult, error) {
+	return c.ChatStreamWithToolsAndUsage(ctx, messages, nil, assistant.ToolChoiceAuto)
+}
+
+func (c *Client) ChatStreamWithToolsAndUsage(
+	ctx context.Context,
+	messages []assistant.Message,
+	tools []assistant.Tool,
+	toolChoice assistant.ToolChoice,
+	opts ...assistant.GenerationOption,
+) (*assistant.StreamResult, error) {
+	events, err := c.ChatStreamEvents(ctx, messages, tools, toolChoice, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return assistant.NewStreamResult(ctx, events), nil
+}
+
+// ChatStreamEvents streams the response with GenerateContentStream and reports
+// it as typed events, forwarding each part as soon as its chunk arrives.
+func (c *Client) ChatStreamEvents(
+	ctx context.Context,
+	messages []assistant.Message,
+	tools []assistant.Tool,
+	toolChoice assistant.ToolChoice,
+	opts ...assistant.GenerationOption,
+) (<-chan assistant.Event, error) {
 	if len(messages) == 0 {
 		return nil, errors.New("ChatStream: no messages provided")
 	}
 
-	var contents []*genai.Content
-	for _, msg := range messages {
-		role := "user"
-		if msg.Role == "assistant" || msg.Role == "model" {
-			role = "model"
-		}
-		contents = append(contents, &genai.Content{
-			Role:  role,
-			Parts: []*genai.Part{{Text: msg.Content}},
-		})
-	}
+	contents, system := convertMessages(messages)
+	model, config := c.generateConfig(assistant.NewGenerationOptions(opts...), tools, toolChoice)
+	config.SystemInstruction = system
 
-	out := make(chan string)
+	out := make(chan assistant.Event)
 	go func() {
 		defer close(out)
 
-		// Create generation config
-		config := &genai.GenerateContentConfig{
-			Temperature: &c.temperature,
+		send := func(ev assistant.Event) bool {
+			return assistant.SendEvent(ctx, out, ev)
 		}
 
-		// Generate content (non-streaming for now)
-		resp, err := c.client.Models.GenerateContent(ctx, c.modelID, contents, config)
-		if err != nil {
-			log.Printf("failed to generate content: %v", err)
-			return
-		}
+		var finishReason genai.FinishReason
+		var usage *genai.GenerateContentResponseUsageMetadata
+		toolIndex := 0
+		for resp, err := range c.client.Models.GenerateContentStream(ctx, model, contents, config) {
+			if err != nil {
+				send(assistant.Event{Type: assistant.EventError, Err: fmt.Errorf("failed to generate content: %w", err)})
+				return
+			}
 
-		for _, cand := range resp.Candidates {
-			if cand.Content != nil {
-				for _, part := range cand.Content.Parts {
-					if part.Text != "" {
-						out <- part.Text
+			for _, cand := range resp.Candidates {
+				if cand.Content != nil {
+					for _, part := range cand.Content.Parts {
+						if part.FunctionCall != nil {
+							if !sendToolCall(send, toolIndex, part.FunctionCall) {
+								return
+							}
+							toolIndex++
+							continue
+						}
+						if part.Text != "" && !send(assistant.Event{Type: assistant.EventTextDelta, Text: part.Text}) {
+							return
+						}
 					}
 				}
+				if cand.FinishReason != "" {
+					finishReason = cand.FinishReason
+				}
+			}
+
+			// Every chunk may carry usage; the final chunk holds the totals.
+			if resp.UsageMetadata != nil {
+				usage = resp.UsageMetadata
 			}
 		}
+
+		if finishReason != "" && !send(assistant.Event{Type: assistant.EventFinish, FinishReason: toolCallFinishReason(normalizeFinishReason(finishReason), toolIndex > 0)}) {
+			return
+		}
+
+		if usage != nil {
+			send(assistant.Event{
+				Type: assistant.EventUsage,
+				Usage: &assistant.UsageMetadata{
+					PromptTokenCount:     usage.PromptTokenCount,
+					CandidatesTokenCount: usage.CandidatesTokenCount,
+					TotalTokenCount:      usage.TotalTokenCount,
+				},
+			})
+		}
 	}()
 
 	return out, nil
 }
 
-func (c *Client) ChatStreamWithUsage(ctx context.Context, messages []assistant.Message) (*assistant.StreamResult, error) {
-	return c.ChatStreamWithToolsAndUsage(ctx, messages, nil, assistant.ToolChoiceAuto)
-}
-
-func (c *Client) ChatStreamWithToolsAndUsage(
-	ctx context.Context,
-	messages []assistant.Message,
-	tools []assistant.Tool,
-	toolChoice assistant.ToolChoice,
-) (*assistant.StreamResult, error) {
+// Chat returns the complete response using GenerateContent.
+func (c *Client) Chat(ctx context.Context, messages []assistant.Message, opts assistant.ChatOptions) (*assistant.Response, error) {
 	if len(messages) == 0 {
-		return nil, errors.New("ChatStream: no messages provided")
+		return nil, errors.New("Chat: no messages provided")
 	}
 
-	var contents []*genai.Content
-	for _, msg := range messages {
-		role := "user"
-		if msg.Role == "assistant" || msg.Role == "model" {
-			role = "model"
+	contents, system := convertMessages(messages)
+	model, config := c.generateConfig(opts.GenerationOptions, opts.Tools, opts.ToolChoice)
+	config.SystemInstruction = system
+
+	resp, err := c.client.Models.GenerateContent(ctx, model, contents, config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate content: %w", err)
+	}
+
+	result := &assistant.Response{
+		Message:      assistant.Message{Role: assistant.RoleAssistant},
+		FinishReason: assistant.FinishReasonStop,
+	}
+	for _, cand := range resp.Candidates {
+		if cand.Content != nil {
+			for _, part := range cand.Content.Parts {
+				if part.FunctionCall != nil {
+					result.Message.ToolCalls = append(result.Message.ToolCalls, assistant.ToolCall{
+						ID:   toolCallID(part.FunctionCall),
+						Type: "function",
+						Function: assistant.FunctionCall{
+							Name:      part.FunctionCall.Name,
+							Arguments: functionArgs(part.FunctionCall),
+						},
+					})
+					continue
+				}
+				result.Message.Content += part.Text
+			}
+		}
+		if cand.FinishReason != "" {
+			result.FinishReason = normalizeFinishReason(cand.FinishReason)
 		}
-		contents = append(contents, &genai.Content{
-			Role:  role,
-			Parts: []*genai.Part{{Text: msg.Content}},
-		})
 	}
+	result.ToolCalls = result.Message.ToolCalls
+	result.FinishReason = toolCallFinishReason(result.FinishReason, len(result.ToolCalls) > 0)
+	if resp.UsageMetadata != nil {
+		result.Usage = &assistant.UsageMetadata{
+			PromptTokenCount:     resp.UsageMetadata.PromptTokenCount,
+			CandidatesTokenCount: resp.UsageMetadata.CandidatesTokenCount,
+			TotalTokenCount:      resp.UsageMetadata.TotalTokenCount,
+		}
+	}
+	return result, nil
+}
 
-	out := make(chan string)
-	var usageMetadata *assistant.UsageMetadata
-	var usageMu sync.Mutex
+// generateConfig returns the model and generation config for a request,
+// applying per-request generation options over the client defaults.
+func (c *Client) generateConfig(gen assistant.GenerationOptions, tools []assistant.Tool, toolChoice assistant.ToolChoice) (string, *genai.GenerateContentConfig) {
+	model := c.modelID
+	if gen.Model != "" {
+		model = gen.Model
+	}
 
-	go func() {
-		defer close(out)
+	temperature := c.temperature
+	if gen.Temperature != nil {
+		temperature = *gen.Temperature
+	}
 
-		config := &genai.GenerateContentConfig{
-			Temperature: &c.temperature,
+	config := &genai.GenerateContentConfig{
+		Temperature:   &temperature,
+		TopP:          gen.TopP,
+		StopSequences: gen.StopSequences,
+	}
+	if gen.MaxTokens != nil {
+		config.MaxOutputTokens = int32(*gen.MaxTokens)
+	}
+	if gen.Seed != nil {
+		seed := int32(*gen.Seed)
+		config.Seed = &seed
+	}
+	if len(tools) > 0 {
+		config.Tools, config.ToolConfig = convertTools(tools, toolChoice)
+	}
+
+	return model, config
+}
+
+// convertTools converts assistant tools to Gemini function declarations and
+// maps the tool choice onto a function calling mode.
+func convertTools(tools []assistant.Tool, toolChoice assistant.ToolChoice) ([]*genai.Tool, *genai.ToolConfig) {
+	declarations := make([]*genai.FunctionDeclaration, len(tools))
+	for i, t := range tools {
+		declarations[i] = &genai.FunctionDeclaration{
+			Name:        t.Function.Name,
+			Description: t.Function.Description,
+		}
+		if t.Function.Parameters != nil {
+			declarations[i].ParametersJsonSchema = t.Function.Parameters
 		}
+	}
 
-		resp, err := c.client.Models.GenerateContent(ctx, c.modelID, contents, config)
-		if err != nil {
-			log.Printf("failed to generate content: %v", err)
+	var mode genai.FunctionCallingConfigMode
+	switch toolChoice {
+	case assistant.ToolChoiceAuto:
+		mode = genai.FunctionCallingConfigModeAuto
+	case assistant.ToolChoiceRequired:
+		mode = genai.FunctionCallingConfigModeAny
+	case assistant.ToolChoiceNone:
+		mode = genai.FunctionCallingConfigModeNone
+	}
+
+	var config *genai.ToolConfig
+	if mode != "" {
+		config = &genai.ToolConfig{FunctionCallingConfig: &genai.FunctionCallingConfig{Mode: mode}}
+	}
+
+	return []*genai.Tool{{FunctionDeclarations: declarations}}, config
+}
+
+// syntheticIDPrefix marks tool call IDs made up by this client. Gemini does not
+// always assign IDs to function calls, so they are synthesized for callers and
+// stripped again before the conversation is sent back.
+const syntheticIDPrefix = "gemini_call_"
+
+// toolCallID returns the ID Gemini assigned to fc, or a synthesized one.
+func toolCallID(fc *genai.FunctionCall) string {
+	if fc.ID != "" {
+		return fc.ID
+	}
+	return syntheticIDPrefix + strings.ToLower(rand.Text())
+}
+
+// functionArgs returns the arguments of fc as a JSON object string.
+func functionArgs(fc *genai.FunctionCall) string {
+	if len(fc.Args) == 0 {
+		return "{}"
+	}
+	args, err := json.Marshal(fc.Args)
+	if err != nil {
+		return "{}"
+	}
+	return string(args)
+}
+
+// sendToolCall reports a function call as a complete start/delta/end sequence,
+// since Gemini delivers each call whole rather than in fragments.
+func sendToolCall(send func(assistant.Event) bool, index int, fc *genai.FunctionCall) bool {
+	id := toolCallID(fc)
+	return send(assistant.Event{Type: assistant.EventToolCallStart, ToolCall: &assistant.ToolCallDelta{Index: index, ID: id, Name: fc.Name}}) &&
+		send(assistant.Event{Type: assistant.EventToolCallDelta, ToolCall: &assistant.ToolCallDelta{Index: index, ID: id, Arguments: functionArgs(fc)}}) &&
+		send(assistant.Event{Type: assistant.EventToolCallEnd, ToolCall: &assistant.ToolCallDelta{Index: index, ID: id}})
+}
+
+// toolCallFinishReason reports tool-calls when the model requested tools.
+// Gemini finishes those turns with STOP, unlike the other providers.
+func toolCallFinishReason(reason assistant.FinishReason, calledTools bool) assistant.FinishReason {
+	if calledTools && reason == assistant.FinishReasonStop {
+		return assistant.FinishReasonToolCalls
+	}
+	return reason
+}
+
+// convertMessages converts assistant.Message to Gemini content, returning
+// system messages separately as the system instruction. Assistant tool calls
+// become FunctionCall parts and tool results become FunctionResponse parts,
+// matched to the call they answer by ID. Gemini expects user and model turns
+// to alternate, so adjacent messages with the same role are merged into one
+// turn and messages without any content are dropped.
+func convertMessages(messages []assistant.Message) ([]*genai.Content, *genai.Content) {
+	var contents []*genai.Content
+	var system *genai.Content
+	toolNames := map[string]string{}
+
+	appendTurn := func(role string, parts []*genai.Part) {
+		if len(parts) == 0 {
 			return
 		}
-
-		// Capture usage metadata
-		if resp.UsageMetadata != nil {
-			usageMu.Lock()
-			usageMetadata = &assistant.UsageMetadata{
-				PromptTokenCount:     resp.UsageMetadata.PromptTokenCount,
-				CandidatesTokenCount: resp.UsageMetadata.CandidatesTokenCount,
-				TotalTokenCount:      resp.UsageMetadata.TotalTokenCount,
-			}
-			usageMu.Unlock()
+		if last := len(contents) - 1; last >= 0 && contents[last].Role == role {
+			contents[last].Parts = append(contents[last].Parts, parts...)
+			return
 		}
+		contents = append(contents, &genai.Content{Role: role, Parts: parts})
+	}
 
-		for _, cand := range resp.Candidates {
-			if cand.Content != nil {
-				for _, part := range cand.Content.Parts {
-					if part.Text != "" {
-						out <- part.Text
-					}
+	for _, msg := range messages {
+		switch msg.Role {
+		case assistant.RoleSystem:
+			if msg.Content == "" {
+				continue
+			}
+			if system == nil {
+				system = &genai.Content{}
+			}
+			system.Parts = append(system.Parts, &genai.Part{Text: msg.Content})
+		case assistant.RoleAssistant, "model":
+			var parts []*genai.Part
+			if msg.Content != "" {
+				parts = append(parts, &genai.Part{Text: msg.Content})
+			}
+			for _, tc := range msg.ToolCalls {
+				toolNames[tc.ID] = tc.Function.Name
+				var args map[string]any
+				if err := json.Unmarshal([]byte(tc.Function.Arguments), &args); err != nil {
+					args = map[string]any{}
 				}
+				parts = append(parts, &genai.Part{FunctionCall: &genai.FunctionCall{
+					ID:   geminiID(tc.ID),
+					Name: tc.Function.Name,
+					Args: args,
+				}})
+			}
+			appendTurn("model", parts)
+		case assistant.RoleTool:
+			appendTurn("user", []*genai.Part{{FunctionResponse: &genai.FunctionResponse{
+				ID:       geminiID(msg.ToolCallID),
+				Name:     toolNames[msg.ToolCallID],
+				Response: functionResponse(msg.Content),
+			}}})
+		default:
+			if msg.Content != "" {
+				appendTurn("user", []*genai.Part{{Text: msg.Content}})
 			}
 		}
-	}()
+	}
+	return contents, system
+}
 
-	return &assistant.StreamResult{
-		TextChannel: out,
-		GetUsage: func() *assistant.UsageMetadata {
-			usageMu.Lock()
-			defer usageMu.Unlock()
-			return usageMetadata
-		},
-	}, nil
+// geminiID returns id unless it was synthesized by this client.
+func geminiID(id string) string {
+	if strings.HasPrefix(id, syntheticIDPrefix) {
+		return ""
+	}
+	return id
+}
+
+// functionResponse wraps a tool result for Gemini. JSON objects are passed
+// through as-is; anything else is reported under the "output" key.
+func functionResponse(content string) map[string]any {
+	var response map[string]any
+	if err := json.Unmarshal([]byte(content), &response); err == nil && response != nil {
+		return response
+	}
+	return map[string]any{"output": content}
+}
+
+// normalizeFinishReason maps a Gemini finish reason onto assistant.FinishReason.
+func normalizeFinishReason(reason genai.FinishReason) assistant.FinishReason {
+	switch reason {
+	case genai.FinishReasonMaxTokens:
+		return assistant.FinishReasonLength
+	case genai.FinishReasonSafety, genai.FinishReasonRecitation, genai.FinishReasonBlocklist,
+		genai.FinishReasonProhibitedContent, genai.FinishReasonSPII, genai.FinishReasonImageSafety,
+		genai.FinishReasonImageProhibitedContent:
+		return assistant.FinishReasonContentFilter
+	case genai.FinishReasonMalformedFunctionCall, genai.FinishReasonUnexpectedToolCall:
+		return assistant.FinishReasonError
+	default:
+		return assistant.FinishReasonStop
+	}
 }
@@ -2,96 +2,382 @@ package gemini_test
 
 import (
 	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"runtime"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
-	"cloud.google.com/go/ai/generativelanguage/apiv1beta/generativelanguagepb"
 	"github.com/sburchfield/go-assistant-api/assistant"
 	"github.com/sburchfield/go-assistant-api/assistant/provider/gemini"
-	"google.golang.org/grpc"
-	"google.golang.org/grpc/test/bufconn"
+	"github.com/sburchfield/go-assistant-api/internal/testutil"
+	"google.golang.org/genai"
 )
 
-const bufSize = 1024 * 1024
+// newTestClient returns a client whose requests are served by handler instead of Vertex AI.
+func newTestClient(t *testing.T, handler http.HandlerFunc) *gemini.Client {
+	t.Helper()
 
-func TestChatStream_Gemini(t *testing.T) {
-	listener := bufconn.Listen(bufSize)
-	server := grpc.NewServer()
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	client, err := gemini.NewClientWithConfig(context.Background(), &genai.ClientConfig{
+		Project:     "fake-project-id",
+		Location:    "fake-location",
+		Backend:     genai.BackendVertexAI,
+		HTTPClient:  server.Client(),
+		HTTPOptions: genai.HTTPOptions{BaseURL: server.URL},
+	}, "gemini-pro", 0.7)
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+	return client
+}
 
-	generativelanguagepb.RegisterGenerativeServiceServer(server, &mockGenerativeServer{})
-	go func() {
-		_ = server.Serve(listener)
-	}()
+func TestNewClient_APIKey(t *testing.T) {
+	var path, apiKey string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path, apiKey = r.URL.Path, r.Header.Get("x-goog-api-key")
+		streamChunks(helloWorldChunks...)(w, r)
+	}))
+	t.Cleanup(server.Close)
 
-	ctx := context.Background()
-	client, err := gemini.NewClient(ctx, "fake-project-id", "fake-location", "gemini-pro", 0.7, "")
+	client, err := gemini.NewClient(context.Background(), "", "", "gemini-pro", 0.7, "",
+		gemini.WithAPIKey("test-key"),
+		func(cfg *genai.ClientConfig) {
+			cfg.HTTPClient = server.Client()
+			cfg.HTTPOptions.BaseURL = server.URL
+		},
+	)
 	if err != nil {
 		t.Fatalf("failed to create client: %v", err)
 	}
 
-	stream, err := client.ChatStream(ctx, []assistant.Message{
-		{Role: assistant.RoleUser, Content: "Say something"},
+	resp, err := assistant.Collect(mustStreamEvents(t, client))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if resp.Message.Content != "Hello world" {
+		t.Errorf("expected 'Hello world', got %q", resp.Message.Content)
+	}
+	if apiKey != "test-key" {
+		t.Errorf("expected API key header 'test-key', got %q", apiKey)
+	}
+	if strings.Contains(path, "projects/") {
+		t.Errorf("expected a Gemini API path without a project, got %s", path)
+	}
+}
+
+func TestNewClient_APIKeyRejectsCredentialsJSON(t *testing.T) {
+	_, err := gemini.NewClient(context.Background(), "", "", "gemini-pro", 0.7, `{"type":"service_account"}`, gemini.WithAPIKey("test-key"))
+	if err == nil {
+		t.Fatal("expected error when combining an API key with credentials JSON")
+	}
+}
+
+// serviceAccountJSON returns credentials for a throwaway service account whose
+// tokens are minted by tokenURL.
+func serviceAccountJSON(t *testing.T, email, tokenURL string) string {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatalf("failed to marshal key: %v", err)
+	}
+	creds, _ := json.Marshal(map[string]string{
+		"type":           "service_account",
+		"project_id":     "fake-project-id",
+		"private_key_id": "key-1",
+		"private_key":    string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})),
+		"client_email":   email,
+		"token_uri":      tokenURL,
 	})
+	return string(creds)
+}
+
+func TestNewClient_CredentialsJSON(t *testing.T) {
+	t.Setenv("GOOGLE_APPLICATION_CREDENTIALS", "/nonexistent/adc.json")
+
+	var mu sync.Mutex
+	authorized := map[string]bool{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/token" {
+			// Echo the service account back as the access token so each
+			// client's identity can be told apart.
+			_ = r.ParseForm()
+			parts := strings.Split(r.PostForm.Get("assertion"), ".")
+			claims, _ := base64.RawURLEncoding.DecodeString(parts[1])
+			var jwt struct {
+				Iss string `json:"iss"`
+			}
+			_ = json.Unmarshal(claims, &jwt)
+			w.Header().Set("Content-Type", "application/json")
+			_ = json.NewEncoder(w).Encode(map[string]any{"access_token": jwt.Iss, "token_type": "Bearer", "expires_in": 3600})
+			return
+		}
+		mu.Lock()
+		authorized[strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")] = true
+		mu.Unlock()
+		respondWith(helloWorld)(w, r)
+	}))
+	t.Cleanup(server.Close)
+
+	// Clients with different identities can be built and used concurrently.
+	emails := []string{"alpha@fake-project-id.iam.gserviceaccount.com", "beta@fake-project-id.iam.gserviceaccount.com"}
+	var wg sync.WaitGroup
+	for _, email := range emails {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			client, err := gemini.NewClient(context.Background(), "fake-project-id", "fake-location", "gemini-pro", 0.7,
+				serviceAccountJSON(t, email, server.URL+"/token"),
+				func(cfg *genai.ClientConfig) { cfg.HTTPOptions.BaseURL = server.URL },
+			)
+			if err != nil {
+				t.Errorf("failed to create client: %v", err)
+				return
+			}
+			resp, err := client.Chat(context.Background(), []assistant.Message{
+				{Role: assistant.RoleUser, Content: "Say something"},
+			}, assistant.ChatOptions{})
+			if err != nil {
+				t.Errorf("unexpected error: %v", err)
+				return
+			}
+			if resp.Message.Content != "Hello world" {
+				t.Errorf("expected 'Hello world', got %q", resp.Message.Content)
+			}
+		}()
+	}
+	wg.Wait()
+
+	for _, email := range emails {
+		if !authorized[email] {
+			t.Errorf("expected a request authorized as %s, got %v", email, authorized)
+		}
+	}
+	if got := os.Getenv("GOOGLE_APPLICATION_CREDENTIALS"); got != "/nonexistent/adc.json" {
+		t.Errorf("expected GOOGLE_APPLICATION_CREDENTIALS to be left alone, got %q", got)
+	}
+}
+
+func TestNewClient_APIKeyProvider(t *testing.T) {
+	var apiKeys []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		apiKeys = append(apiKeys, r.Header.Get("x-goog-api-key"))
+		streamChunks(helloWorldChunks...)(w, r)
+	}))
+	t.Cleanup(server.Close)
+
+	key := "key-1"
+	client, err := gemini.NewClient(context.Background(), "", "", "gemini-pro", 0.7, "",
+		func(cfg *genai.ClientConfig) {
+			cfg.HTTPClient = server.Client()
+			cfg.HTTPOptions.BaseURL = server.URL
+		},
+		gemini.WithAPIKeyProvider(func(context.Context) (string, error) { return key, nil }),
+	)
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+
+	// A rotated key is used by the next request.
+	for _, k := range []string{"key-1", "key-2"} {
+		key = k
+		if _, err := assistant.Collect(mustStreamEvents(t, client)); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if len(apiKeys) != 2 || apiKeys[0] != "key-1" || apiKeys[1] != "key-2" {
+		t.Errorf("expected API keys [key-1 key-2], got %v", apiKeys)
+	}
+}
+
+func TestNewClient_CredentialsProvider(t *testing.T) {
+	var authorized []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/token" {
+			_ = r.ParseForm()
+			parts := strings.Split(r.PostForm.Get("assertion"), ".")
+			claims, _ := base64.RawURLEncoding.DecodeString(parts[1])
+			var jwt struct {
+				Iss string `json:"iss"`
+			}
+			_ = json.Unmarshal(claims, &jwt)
+			w.Header().Set("Content-Type", "application/json")
+			_ = json.NewEncoder(w).Encode(map[string]any{"access_token": jwt.Iss, "token_type": "Bearer", "expires_in": 3600})
+			return
+		}
+		authorized = append(authorized, strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer "))
+		respondWith(helloWorld)(w, r)
+	}))
+	t.Cleanup(server.Close)
+
+	credentialsJSON := serviceAccountJSON(t, "alpha@fake-project-id.iam.gserviceaccount.com", server.URL+"/token")
+	client, err := gemini.NewClient(context.Background(), "fake-project-id", "fake-location", "gemini-pro", 0.7, "",
+		gemini.WithCredentialsProvider(func(context.Context) (string, error) { return credentialsJSON, nil }),
+		func(cfg *genai.ClientConfig) { cfg.HTTPOptions.BaseURL = server.URL },
+	)
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+
+	chat := func() {
+		t.Helper()
+		if _, err := client.Chat(context.Background(), []assistant.Message{
+			{Role: assistant.RoleUser, Content: "Say something"},
+		}, assistant.ChatOptions{}); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	chat()
+	// Rotated credentials are used by the next request.
+	credentialsJSON = serviceAccountJSON(t, "beta@fake-project-id.iam.gserviceaccount.com", server.URL+"/token")
+	chat()
+
+	want := []string{"alpha@fake-project-id.iam.gserviceaccount.com", "beta@fake-project-id.iam.gserviceaccount.com"}
+	if len(authorized) != 2 || authorized[0] != want[0] || authorized[1] != want[1] {
+		t.Errorf("expected requests authorized as %v, got %v", want, authorized)
+	}
+}
+
+func TestNewClient_InvalidCredentialsJSON(t *testing.T) {
+	for _, credentialsJSON := range []string{
+		`{"type":"unknown"}`,
+		`{"type":"authorized_user","client_id":"id","client_secret":"secret","refresh_token":"token"}`,
+		`not json`,
+	} {
+		_, err := gemini.NewClient(context.Background(), "fake-project-id", "fake-location", "gemini-pro", 0.7, credentialsJSON)
+		if err == nil {
+			t.Errorf("expected error for unsupported credentials %s", credentialsJSON)
+		}
+	}
+}
+
+// mustStreamEvents starts a single-message event stream on client.
+func mustStreamEvents(t *testing.T, client *gemini.Client) <-chan assistant.Event {
+	t.Helper()
+	events, err := client.ChatStreamEvents(context.Background(), []assistant.Message{
+		{Role: assistant.RoleUser, Content: "Say something"},
+	}, nil, assistant.ToolChoiceAuto)
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
+	return events
+}
 
-	var result string
-	for msg := range stream {
-		result += msg
+// isStream reports whether r is a GenerateContentStream request.
+func isStream(r *http.Request) bool {
+	return strings.HasSuffix(r.URL.Path, ":streamGenerateContent")
+}
+
+// respondWith serves resp as a GenerateContent response, or as a single
+// server-sent event when the client is streaming.
+func respondWith(resp *genai.GenerateContentResponse) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if isStream(r) {
+			streamChunks(resp)(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(resp)
 	}
+}
 
-	expected := "Hello world"
-	if result != expected {
-		t.Errorf("expected %q, got %q", expected, result)
+// streamChunks serves each chunk as a server-sent event, the way
+// GenerateContentStream receives them.
+func streamChunks(chunks ...*genai.GenerateContentResponse) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, chunk := range chunks {
+			writeChunk(w, chunk)
+		}
 	}
 }
 
-type mockGenerativeServer struct {
-	generativelanguagepb.UnimplementedGenerativeServiceServer
+// writeChunk writes a single server-sent event and flushes it to the client.
+func writeChunk(w http.ResponseWriter, chunk *genai.GenerateContentResponse) {
+	data, _ := json.Marshal(chunk)
+	fmt.Fprintf(w, "data: %s\n\n", data)
+	w.(http.Flusher).Flush()
 }
 
-func (m *mockGenerativeServer) StreamGenerateContent(req *generativelanguagepb.GenerateContentRequest, stream generativelanguagepb.GenerativeService_StreamGenerateContentServer) error {
-	resp1 := &generativelanguagepb.GenerateContentResponse{
-		Candidates: []*generativelanguagepb.Candidate{
-			{
-				Content: &generativelanguagepb.Content{
-					Parts: []*generativelanguagepb.Part{
-						{Data: &generativelanguagepb.Part_Text{Text: "Hello"}},
-					},
-				},
+var helloWorld = &genai.GenerateContentResponse{
+	Candidates: []*genai.Candidate{
+		{
+			Content: &genai.Content{
+				Role:  "model",
+				Parts: []*genai.Part{{Text: "Hello"}, {Text: " world"}},
 			},
+			FinishReason: genai.FinishReasonStop,
 		},
-	}
-	resp2 := &generativelanguagepb.GenerateContentResponse{
-		Candidates: []*generativelanguagepb.Candidate{
+	},
+	UsageMetadata: &genai.GenerateContentResponseUsageMetadata{
+		PromptTokenCount:     3,
+		CandidatesTokenCount: 2,
+		TotalTokenCount:      5,
+	},
+}
+
+// helloWorldChunks is helloWorld as the stream of chunks Gemini sends. Usage
+// is reported on every chunk, with the totals on the last one.
+var helloWorldChunks = []*genai.GenerateContentResponse{
+	{
+		Candidates: []*genai.Candidate{
+			{Content: &genai.Content{Role: "model", Parts: []*genai.Part{{Text: "Hello"}}}},
+		},
+		UsageMetadata: &genai.GenerateContentResponseUsageMetadata{PromptTokenCount: 3, CandidatesTokenCount: 1, TotalTokenCount: 4},
+	},
+	{
+		Candidates: []*genai.Candidate{
 			{
-				Content: &generativelanguagepb.Content{
-					Parts: []*generativelanguagepb.Part{
-						{Data: &generativelanguagepb.Part_Text{Text: " world"}},
-					},
-				},
+				Content:      &genai.Content{Role: "model", Parts: []*genai.Part{{Text: " world"}}},
+				FinishReason: genai.FinishReasonStop,
 			},
 		},
-	}
-	_ = stream.Send(resp1)
-	_ = stream.Send(resp2)
-	return nil
+		UsageMetadata: &genai.GenerateContentResponseUsageMetadata{PromptTokenCount: 3, CandidatesTokenCount: 2, TotalTokenCount: 5},
+	},
 }
 
-func TestChatStreamWithUsage_Gemini(t *testing.T) {
-	listener := bufconn.Listen(bufSize)
-	server := grpc.NewServer()
-
-	generativelanguagepb.RegisterGenerativeServiceServer(server, &mockGenerativeServer{})
-	go func() {
-		_ = server.Serve(listener)
-	}()
-
+func TestChatStream_Gemini(t *testing.T) {
 	ctx := context.Background()
-	client, err := gemini.NewClient(ctx, "fake-project-id", "fake-location", "gemini-pro", 0.7, "")
+	client := newTestClient(t, streamChunks(helloWorldChunks...))
+
+	stream, err := client.ChatStream(ctx, []assistant.Message{
+		{Role: assistant.RoleUser, Content: "Say something"},
+	})
 	if err != nil {
-		t.Fatalf("failed to create client: %v", err)
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var result string
+	for msg := range stream {
+		result += msg
+	}
+
+	expected := "Hello world"
+	if result != expected {
+		t.Errorf("expected %q, got %q", expected, result)
 	}
+}
+
+func TestChatStreamWithUsage_Gemini(t *testing.T) {
+	ctx := context.Background()
+	client := newTestClient(t, streamChunks(helloWorldChunks...))
 
 	result, err := client.ChatStreamWithUsage(ctx, []assistant.Message{
 		{Role: assistant.RoleUser, Content: "Say something"},
@@ -120,21 +406,446 @@ func TestChatStreamWithUsage_Gemini(t *testing.T) {
 		t.Fatal("expected non-nil UsageMetadata after consuming TextChannel")
 	}
 
-	// Verify token counts are populated (in real usage, these would be > 0)
-	// For mock test, we just verify the struct is properly returned
-	t.Logf("Usage metadata - PromptTokens: %d, CandidatesTokens: %d, TotalTokens: %d",
-		usage.PromptTokenCount, usage.CandidatesTokenCount, usage.TotalTokenCount)
+	if usage.PromptTokenCount != 3 || usage.CandidatesTokenCount != 2 || usage.TotalTokenCount != 5 {
+		t.Errorf("unexpected usage metadata: %+v", usage)
+	}
 }
 
-func TestChatStreamWithToolsAndUsage_NoMessages(t *testing.T) {
+func TestChatStreamEvents_Gemini(t *testing.T) {
 	ctx := context.Background()
-	client, err := gemini.NewClient(ctx, "fake-project-id", "fake-location", "gemini-pro", 0.7, "")
+	client := newTestClient(t, streamChunks(helloWorldChunks...))
+
+	events, err := client.ChatStreamEvents(ctx, []assistant.Message{
+		{Role: assistant.RoleUser, Content: "Say something"},
+	}, nil, assistant.ToolChoiceAuto)
 	if err != nil {
-		t.Fatalf("failed to create client: %v", err)
+		t.Fatalf("unexpected error: %v", err)
 	}
 
-	_, err = client.ChatStreamWithToolsAndUsage(ctx, []assistant.Message{}, nil, assistant.ToolChoiceAuto)
+	var got []assistant.EventType
+	for ev := range events {
+		got = append(got, ev.Type)
+		if ev.Type == assistant.EventFinish && ev.FinishReason != assistant.FinishReasonStop {
+			t.Errorf("expected finish reason 'stop', got '%s'", ev.FinishReason)
+		}
+	}
+
+	expected := []assistant.EventType{
+		assistant.EventTextDelta,
+		assistant.EventTextDelta,
+		assistant.EventFinish,
+		assistant.EventUsage,
+	}
+	if len(got) != len(expected) {
+		t.Fatalf("expected events %v, got %v", expected, got)
+	}
+	for i := range expected {
+		if got[i] != expected[i] {
+			t.Errorf("event %d: expected %s, got %s", i, expected[i], got[i])
+		}
+	}
+}
+
+func TestChatStreamEvents_GeminiStreamsIncrementally(t *testing.T) {
+	firstSeen := make(chan struct{})
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		writeChunk(w, helloWorldChunks[0])
+		// Hold back the rest of the response until the first part has
+		// reached the caller.
+		select {
+		case <-firstSeen:
+		case <-r.Context().Done():
+			return
+		}
+		writeChunk(w, helloWorldChunks[1])
+	})
+
+	events, err := client.ChatStreamEvents(context.Background(), []assistant.Message{
+		{Role: assistant.RoleUser, Content: "Say something"},
+	}, nil, assistant.ToolChoiceAuto)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	select {
+	case ev := <-events:
+		if ev.Type != assistant.EventTextDelta || ev.Text != "Hello" {
+			t.Fatalf("expected first text delta 'Hello', got %+v", ev)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("expected the first part before the response completed")
+	}
+	close(firstSeen)
+
+	resp, err := assistant.Collect(events)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if resp.Message.Content != " world" {
+		t.Errorf("expected remaining content ' world', got %q", resp.Message.Content)
+	}
+	if resp.Usage == nil || resp.Usage.TotalTokenCount != 5 {
+		t.Errorf("expected usage from the final chunk, got %+v", resp.Usage)
+	}
+}
+
+func TestChatStreamEvents_GeminiError(t *testing.T) {
+	ctx := context.Background()
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, `{"error":{"code":429,"message":"quota exceeded","status":"RESOURCE_EXHAUSTED"}}`, http.StatusTooManyRequests)
+	})
+
+	events, err := client.ChatStreamEvents(ctx, []assistant.Message{
+		{Role: assistant.RoleUser, Content: "Say something"},
+	}, nil, assistant.ToolChoiceAuto)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var last assistant.Event
+	for ev := range events {
+		last = ev
+	}
+	if last.Type != assistant.EventError || last.Err == nil {
+		t.Fatalf("expected a terminal error event, got %+v", last)
+	}
+}
+
+func TestChat_Gemini(t *testing.T) {
+	client := newTestClient(t, respondWith(helloWorld))
+
+	resp, err := client.Chat(context.Background(), []assistant.Message{
+		{Role: assistant.RoleUser, Content: "Say something"},
+	}, assistant.ChatOptions{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if resp.Message.Role != assistant.RoleAssistant || resp.Message.Content != "Hello world" {
+		t.Errorf("unexpected message: %+v", resp.Message)
+	}
+	if resp.FinishReason != assistant.FinishReasonStop {
+		t.Errorf("expected finish reason 'stop', got '%s'", resp.FinishReason)
+	}
+	if resp.Usage == nil || resp.Usage.TotalTokenCount != 5 {
+		t.Errorf("unexpected usage metadata: %+v", resp.Usage)
+	}
+}
+
+func TestChat_GeminiGenerationOptions(t *testing.T) {
+	var path string
+	var body struct {
+		GenerationConfig map[string]any `json:"generationConfig"`
+	}
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.Path
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		respondWith(helloWorld)(w, r)
+	})
+
+	_, err := client.Chat(context.Background(), []assistant.Message{
+		{Role: assistant.RoleUser, Content: "Say something"},
+	}, assistant.ChatOptions{GenerationOptions: assistant.NewGenerationOptions(
+		assistant.WithModel("gemini-1.5-flash"),
+		assistant.WithMaxTokens(128),
+		assistant.WithSeed(3),
+	)})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if !strings.Contains(path, "gemini-1.5-flash") {
+		t.Errorf("expected model override in request path, got %s", path)
+	}
+	if body.GenerationConfig["maxOutputTokens"] != float64(128) || body.GenerationConfig["seed"] != float64(3) {
+		t.Errorf("unexpected generation config: %v", body.GenerationConfig)
+	}
+}
+
+var weatherTool = assistant.Tool{
+	Type: "function",
+	Function: assistant.ToolFunction{
+		Name:        "get_weather",
+		Description: "Get the weather for a location",
+		Parameters: map[string]interface{}{
+			"type":       "object",
+			"properties": map[string]interface{}{"location": map[string]interface{}{"type": "string"}},
+		},
+	},
+}
+
+var weatherCall = &genai.GenerateContentResponse{
+	Candidates: []*genai.Candidate{
+		{
+			Content: &genai.Content{
+				Role: "model",
+				Parts: []*genai.Part{{FunctionCall: &genai.FunctionCall{
+					Name: "get_weather",
+					Args: map[string]any{"location": "Paris"},
+				}}},
+			},
+			FinishReason: genai.FinishReasonStop,
+		},
+	},
+}
+
+func TestChatStreamEvents_GeminiToolCalls(t *testing.T) {
+	client := newTestClient(t, respondWith(weatherCall))
+
+	events, err := client.ChatStreamEvents(context.Background(), []assistant.Message{
+		{Role: assistant.RoleUser, Content: "What's the weather in Paris?"},
+	}, []assistant.Tool{weatherTool}, assistant.ToolChoiceAuto)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	resp, err := assistant.Collect(events)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(resp.ToolCalls) != 1 {
+		t.Fatalf("expected 1 tool call, got %+v", resp.ToolCalls)
+	}
+	tc := resp.ToolCalls[0]
+	if tc.ID == "" {
+		t.Error("expected a synthesized tool call ID")
+	}
+	if tc.Function.Name != "get_weather" || tc.Function.Arguments != `{"location":"Paris"}` {
+		t.Errorf("unexpected tool call: %+v", tc)
+	}
+	if resp.FinishReason != assistant.FinishReasonToolCalls {
+		t.Errorf("expected finish reason 'tool-calls', got '%s'", resp.FinishReason)
+	}
+}
+
+func TestChat_GeminiToolCalls(t *testing.T) {
+	client := newTestClient(t, respondWith(weatherCall))
+
+	resp, err := client.Chat(context.Background(), []assistant.Message{
+		{Role: assistant.RoleUser, Content: "What's the weather in Paris?"},
+	}, assistant.ChatOptions{Tools: []assistant.Tool{weatherTool}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(resp.Message.ToolCalls) != 1 || resp.Message.ToolCalls[0].Function.Name != "get_weather" {
+		t.Fatalf("unexpected tool calls: %+v", resp.Message.ToolCalls)
+	}
+	if resp.FinishReason != assistant.FinishReasonToolCalls {
+		t.Errorf("expected finish reason 'tool-calls', got '%s'", resp.FinishReason)
+	}
+}
+
+func TestChatStreamEvents_GeminiToolRequest(t *testing.T) {
+	var body struct {
+		Contents   []*genai.Content  `json:"contents"`
+		Tools      []*genai.Tool     `json:"tools"`
+		ToolConfig *genai.ToolConfig `json:"toolConfig"`
+	}
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		respondWith(helloWorld)(w, r)
+	})
+
+	events, err := client.ChatStreamEvents(context.Background(), []assistant.Message{
+		{Role: assistant.RoleUser, Content: "What's the weather in Paris?"},
+		{Role: assistant.RoleAssistant, ToolCalls: []assistant.ToolCall{{
+			ID:       "gemini_call_abc",
+			Type:     "function",
+			Function: assistant.FunctionCall{Name: "get_weather", Arguments: `{"location":"Paris"}`},
+		}}},
+		{Role: assistant.RoleTool, ToolCallID: "gemini_call_abc", Content: "Sunny, 22C"},
+	}, []assistant.Tool{weatherTool}, assistant.ToolChoiceRequired)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for range events {
+	}
+
+	if len(body.Tools) != 1 || len(body.Tools[0].FunctionDeclarations) != 1 {
+		t.Fatalf("expected 1 function declaration, got %+v", body.Tools)
+	}
+	decl := body.Tools[0].FunctionDeclarations[0]
+	if decl.Name != "get_weather" || decl.ParametersJsonSchema == nil {
+		t.Errorf("unexpected function declaration: %+v", decl)
+	}
+	if body.ToolConfig == nil || body.ToolConfig.FunctionCallingConfig.Mode != genai.FunctionCallingConfigModeAny {
+		t.Errorf("expected function calling mode ANY, got %+v", body.ToolConfig)
+	}
+
+	if len(body.Contents) != 3 {
+		t.Fatalf("expected 3 contents, got %d", len(body.Contents))
+	}
+	call := body.Contents[1].Parts[0].FunctionCall
+	if body.Contents[1].Role != "model" || call == nil || call.Name != "get_weather" || call.Args["location"] != "Paris" {
+		t.Errorf("unexpected function call content: %+v", body.Contents[1])
+	}
+	if call != nil && call.ID != "" {
+		t.Errorf("expected synthesized ID to be stripped, got %q", call.ID)
+	}
+	result := body.Contents[2].Parts[0].FunctionResponse
+	if result == nil || result.Name != "get_weather" || result.Response["output"] != "Sunny, 22C" {
+		t.Errorf("unexpected function response content: %+v", body.Contents[2])
+	}
+}
+
+// describeContent summarizes a turn as "role: part, part" for comparison.
+func describeContent(c *genai.Content) string {
+	parts := make([]string, len(c.Parts))
+	for i, p := range c.Parts {
+		switch {
+		case p.FunctionCall != nil:
+			parts[i] = "call " + p.FunctionCall.Name
+		case p.FunctionResponse != nil:
+			parts[i] = "result " + p.FunctionResponse.Name
+		default:
+			parts[i] = p.Text
+		}
+	}
+	return c.Role + ": " + strings.Join(parts, ", ")
+}
+
+func TestChatStreamEvents_GeminiMessageConversion(t *testing.T) {
+	weatherCall := assistant.ToolCall{
+		ID:       "call_1",
+		Type:     "function",
+		Function: assistant.FunctionCall{Name: "get_weather", Arguments: `{"location":"Paris"}`},
+	}
+	timeCall := assistant.ToolCall{
+		ID:       "call_2",
+		Type:     "function",
+		Function: assistant.FunctionCall{Name: "get_time", Arguments: `{}`},
+	}
+
+	tests := []struct {
+		name         string
+		messages     []assistant.Message
+		wantSystem   string
+		wantContents []string
+	}{
+		{
+			name: "system messages become the system instruction",
+			messages: []assistant.Message{
+				{Role: assistant.RoleSystem, Content: "Be brief."},
+				{Role: assistant.RoleSystem, Content: "Answer in French."},
+				{Role: assistant.RoleUser, Content: "Hello"},
+			},
+			wantSystem:   "Be brief., Answer in French.",
+			wantContents: []string{"user: Hello"},
+		},
+		{
+			name: "adjacent user turns are merged",
+			messages: []assistant.Message{
+				{Role: assistant.RoleUser, Content: "Hello"},
+				{Role: assistant.RoleUser, Content: "Are you there?"},
+				{Role: assistant.RoleAssistant, Content: "Yes"},
+			},
+			wantContents: []string{"user: Hello, Are you there?", "model: Yes"},
+		},
+		{
+			name: "tool-call-only assistant turn has no empty text part",
+			messages: []assistant.Message{
+				{Role: assistant.RoleUser, Content: "Weather and time in Paris?"},
+				{Role: assistant.RoleAssistant, ToolCalls: []assistant.ToolCall{weatherCall, timeCall}},
+				{Role: assistant.RoleTool, ToolCallID: "call_1", Content: "Sunny"},
+				{Role: assistant.RoleTool, ToolCallID: "call_2", Content: "14:00"},
+				{Role: assistant.RoleAssistant, Content: "Sunny, and it is 14:00."},
+			},
+			wantContents: []string{
+				"user: Weather and time in Paris?",
+				"model: call get_weather, call get_time",
+				"user: result get_weather, result get_time",
+				"model: Sunny, and it is 14:00.",
+			},
+		},
+		{
+			name: "empty turns are dropped",
+			messages: []assistant.Message{
+				{Role: assistant.RoleUser, Content: "Hello"},
+				{Role: assistant.RoleAssistant, Content: ""},
+				{Role: assistant.RoleUser, Content: "Still there?"},
+			},
+			wantContents: []string{"user: Hello, Still there?"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var body struct {
+				Contents          []*genai.Content `json:"contents"`
+				SystemInstruction *genai.Content   `json:"systemInstruction"`
+			}
+			client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+				_ = json.NewDecoder(r.Body).Decode(&body)
+				respondWith(helloWorld)(w, r)
+			})
+
+			events, err := client.ChatStreamEvents(context.Background(), tt.messages, nil, assistant.ToolChoiceAuto)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			for range events {
+			}
+
+			var system string
+			if body.SystemInstruction != nil {
+				body.SystemInstruction.Role = ""
+				system = strings.TrimPrefix(describeContent(body.SystemInstruction), ": ")
+			}
+			if system != tt.wantSystem {
+				t.Errorf("expected system instruction %q, got %q", tt.wantSystem, system)
+			}
+
+			got := make([]string, len(body.Contents))
+			for i, c := range body.Contents {
+				got[i] = describeContent(c)
+			}
+			if strings.Join(got, "\n") != strings.Join(tt.wantContents, "\n") {
+				t.Errorf("unexpected contents:\n got: %q\nwant: %q", got, tt.wantContents)
+			}
+		})
+	}
+}
+
+func TestChatStreamWithToolsAndUsage_NoMessages(t *testing.T) {
+	ctx := context.Background()
+	client := newTestClient(t, respondWith(helloWorld))
+
+	_, err := client.ChatStreamWithToolsAndUsage(ctx, []assistant.Message{}, nil, assistant.ToolChoiceAuto)
 	if err == nil {
 		t.Fatal("expected error for empty messages")
 	}
 }
+
+func TestChatStreamEvents_AbandonedReader(t *testing.T) {
+	started := make(chan struct{})
+	released := make(chan struct{})
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		// Hold the request open until the client gives up on it. The body
+		// must be consumed for the server to notice the client hanging up.
+		_, _ = io.Copy(io.Discard, r.Body)
+		close(started)
+		<-r.Context().Done()
+		close(released)
+	})
+	baseline := runtime.NumGoroutine()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	_, err := client.ChatStreamEvents(ctx, []assistant.Message{
+		{Role: assistant.RoleUser, Content: "Say something"},
+	}, nil, assistant.ToolChoiceAuto)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	<-started
+	cancel()
+
+	select {
+	case <-released:
+	case <-time.After(2 * time.Second):
+		t.Fatal("expected the HTTP request to be released after cancellation")
+	}
+	testutil.WaitForGoroutines(t, baseline)
+}
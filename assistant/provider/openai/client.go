@@ -2,7 +2,13 @@ package openai
 
 import (
 	"context"
-	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sync/atomic"
+	"time"
 
 	openai "github.com/sashabaranov/go-openai"
 	"github.com/sburchfield/go-assistant-api/assistant"
@@ -17,6 +23,7 @@ type ChatStream interface {
 // OpenAIClient defines the subset of the OpenAI SDK used by our code.
 type OpenAIClient interface {
 	CreateChatCompletionStream(ctx context.Context, req openai.ChatCompletionRequest) (ChatStream, error)
+	CreateChatCompletion(ctx context.Context, req openai.ChatCompletionRequest) (openai.ChatCompletionResponse, error)
 }
 
 // sdkWrapper wraps the actual OpenAI client to match our OpenAIClient interface.
@@ -25,32 +32,488 @@ type sdkWrapper struct {
 }
 
 func (s *sdkWrapper) CreateChatCompletionStream(ctx context.Context, req openai.ChatCompletionRequest) (ChatStream, error) {
-	return s.inner.CreateChatCompletionStream(ctx, req)
+	ctx, hint := withRetryAfterHint(ctx)
+	stream, err := s.inner.CreateChatCompletionStream(ctx, req)
+	return stream, hint.wrap(err)
+}
+
+func (s *sdkWrapper) CreateChatCompletion(ctx context.Context, req openai.ChatCompletionRequest) (openai.ChatCompletionResponse, error) {
+	ctx, hint := withRetryAfterHint(ctx)
+	resp, err := s.inner.CreateChatCompletion(ctx, req)
+	return resp, hint.wrap(err)
+}
+
+// RetryAfterError wraps an error from a rate-limited or overloaded request
+// with the delay the server asked for in its Retry-After header. The
+// underlying *openai.APIError remains reachable with errors.As.
+type RetryAfterError struct {
+	Err        error
+	RetryAfter time.Duration
+}
+
+func (e *RetryAfterError) Error() string { return e.Err.Error() }
+func (e *RetryAfterError) Unwrap() error { return e.Err }
+
+// retryAfterHint carries the Retry-After delay seen by authTransport back to
+// sdkWrapper, since go-openai does not expose response headers on errors.
+type retryAfterHint struct {
+	delay atomic.Int64
+}
+
+type retryAfterKey struct{}
+
+func withRetryAfterHint(ctx context.Context) (context.Context, *retryAfterHint) {
+	hint := &retryAfterHint{}
+	return context.WithValue(ctx, retryAfterKey{}, hint), hint
+}
+
+func (h *retryAfterHint) wrap(err error) error {
+	if err == nil {
+		return nil
+	}
+	if delay := time.Duration(h.delay.Load()); delay > 0 {
+		return &RetryAfterError{Err: err, RetryAfter: delay}
+	}
+	return err
 }
 
 type Client struct {
-	sdk         OpenAIClient
-	model       string
-	temperature float32
+	sdk             OpenAIClient
+	model           string
+	temperature     *float32
+	reasoningEffort string
 }
 
+// NewClientWithSDK creates a client that sends requests through sdk. A zero
+// temperature is not sent, so the server default applies.
 func NewClientWithSDK(sdk OpenAIClient, model string, temperature float32) *Client {
-	return &Client{
-		sdk:         sdk,
-		model:       model,
-		temperature: temperature,
+	c := &Client{
+		sdk:   sdk,
+		model: model,
+	}
+	if temperature != 0 {
+		c.temperature = &temperature
 	}
+	return c
+}
+
+// DefaultAzureAPIVersion is the Azure OpenAI api-version used unless WithAzure
+// is given one. It is the oldest GA version that accepts stream usage options.
+const DefaultAzureAPIVersion = "2024-10-21"
+
+// clientOptions collects the settings applied by Option.
+type clientOptions struct {
+	baseURL    string
+	orgID      string
+	projectID  string
+	httpClient *http.Client
+	apiKey     func(context.Context) (string, error)
+
+	temperature      *float32
+	reasoningEffort  string
+	reasoningSummary string
+
+	azureEndpoint   string
+	azureDeployment string
+	azureAPIVersion string
+	azureADToken    func(context.Context) (string, error)
+}
+
+// Option configures the endpoint and authentication used by NewClient.
+type Option func(*clientOptions)
+
+// WithBaseURL sends requests to an OpenAI-compatible endpoint such as a local
+// Ollama or vLLM server or a LiteLLM proxy, e.g. "http://localhost:11434/v1".
+func WithBaseURL(baseURL string) Option {
+	return func(o *clientOptions) { o.baseURL = baseURL }
 }
 
-func NewClient(apiKey string, model string, temperature float32) *Client {
-	return NewClientWithSDK(&sdkWrapper{inner: openai.NewClient(apiKey)}, model, temperature)
+// WithOrganization sets the OpenAI-Organization header.
+func WithOrganization(orgID string) Option {
+	return func(o *clientOptions) { o.orgID = orgID }
+}
+
+// WithProject sets the OpenAI-Project header.
+func WithProject(projectID string) Option {
+	return func(o *clientOptions) { o.projectID = projectID }
+}
+
+// WithHTTPClient sends requests through httpClient.
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(o *clientOptions) { o.httpClient = httpClient }
+}
+
+// WithAPIKeyProvider fetches the API key before every request instead of
+// using the key passed to NewClient, so rotated keys are picked up without
+// rebuilding the client. The key is sent in the api-key header for Azure.
+func WithAPIKeyProvider(apiKey func(context.Context) (string, error)) Option {
+	return func(o *clientOptions) { o.apiKey = apiKey }
+}
+
+// WithTemperature sets the default temperature even when it is 0, which the
+// temperature passed to NewClient or NewResponsesClient cannot do: there, 0
+// leaves the server default in place.
+func WithTemperature(temperature float32) Option {
+	return func(o *clientOptions) { o.temperature = &temperature }
+}
+
+// WithReasoning sets the reasoning effort ("minimal", "low", "medium" or
+// "high") of reasoning models and, for the Responses API, requests a summary
+// of the reasoning ("auto", "concise" or "detailed"), which is streamed as
+// assistant.EventReasoningDelta events. Empty values keep the model's
+// defaults. Chat Completions has no reasoning summaries, so NewClient only
+// sends the effort.
+func WithReasoning(effort, summary string) Option {
+	return func(o *clientOptions) {
+		o.reasoningEffort = effort
+		o.reasoningSummary = summary
+	}
+}
+
+// WithAzure targets an Azure OpenAI resource. endpoint is the resource URL,
+// e.g. "https://my-resource.openai.azure.com". When deployment is set every
+// request is routed to it; otherwise the model name is used as the deployment.
+// An empty apiVersion selects DefaultAzureAPIVersion. The key passed to
+// NewClient is sent in the api-key header unless WithAzureADToken is given.
+func WithAzure(endpoint, deployment, apiVersion string) Option {
+	return func(o *clientOptions) {
+		o.azureEndpoint = endpoint
+		o.azureDeployment = deployment
+		o.azureAPIVersion = apiVersion
+	}
+}
+
+// WithAzureADToken authenticates Azure OpenAI requests with a fixed Microsoft
+// Entra ID (Azure AD) access token instead of an API key.
+func WithAzureADToken(token string) Option {
+	return WithAzureADTokenProvider(func(context.Context) (string, error) { return token, nil })
+}
+
+// WithAzureADTokenProvider authenticates Azure OpenAI requests with a Microsoft
+// Entra ID (Azure AD) access token fetched before every request, so tokens can
+// be refreshed as they expire.
+func WithAzureADTokenProvider(token func(context.Context) (string, error)) Option {
+	return func(o *clientOptions) { o.azureADToken = token }
+}
+
+// NewClient creates a client for the OpenAI API, or for any OpenAI-compatible
+// backend selected with opts. A zero temperature is not sent, so the server
+// default applies; use WithTemperature(0) for greedy sampling.
+func NewClient(apiKey string, model string, temperature float32, opts ...Option) *Client {
+	var o clientOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	c := NewClientWithSDK(&sdkWrapper{inner: openai.NewClientWithConfig(o.newConfig(apiKey))}, model, temperature)
+	if o.temperature != nil {
+		c.temperature = o.temperature
+	}
+	c.reasoningEffort = o.reasoningEffort
+	return c
+}
+
+// newConfig builds the go-openai client configuration for the options.
+func (o *clientOptions) newConfig(apiKey string) openai.ClientConfig {
+	config := openai.DefaultConfig(apiKey)
+	if o.azureEndpoint != "" {
+		config = openai.DefaultAzureConfig(apiKey, o.azureEndpoint)
+		config.APIVersion = DefaultAzureAPIVersion
+		if o.azureAPIVersion != "" {
+			config.APIVersion = o.azureAPIVersion
+		}
+		if o.azureDeployment != "" {
+			deployment := o.azureDeployment
+			config.AzureModelMapperFunc = func(string) string { return deployment }
+		}
+		if o.azureADToken != nil {
+			config.APIType = openai.APITypeAzureAD
+		}
+	}
+	if o.baseURL != "" {
+		config.BaseURL = o.baseURL
+	}
+	config.OrgID = o.orgID
+	config.HTTPClient = o.newHTTPClient()
+
+	return config
+}
+
+// newHTTPClient returns the configured HTTP client, wrapped to add the
+// project, API key provider and Azure AD headers when they are set and to
+// record Retry-After delays.
+func (o *clientOptions) newHTTPClient() *http.Client {
+	httpClient := o.httpClient
+	if httpClient == nil {
+		httpClient = &http.Client{}
+	}
+
+	wrapped := *httpClient
+	transport := &authTransport{
+		base:      httpClient.Transport,
+		projectID: o.projectID,
+		token:     o.azureADToken,
+	}
+	// An Azure AD token replaces the API key entirely.
+	if o.azureADToken == nil {
+		transport.apiKey = o.apiKey
+		transport.azure = o.azureEndpoint != ""
+	}
+	wrapped.Transport = transport
+	return &wrapped
+}
+
+// authTransport adds the headers go-openai cannot set itself: the project, a
+// freshly fetched API key and a freshly fetched Azure AD bearer token. It also
+// records the Retry-After delay of failed responses for retryAfterHint.
+type authTransport struct {
+	base      http.RoundTripper
+	projectID string
+	apiKey    func(context.Context) (string, error)
+	azure     bool
+	token     func(context.Context) (string, error)
+}
+
+func (t *authTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	if t.projectID != "" {
+		req.Header.Set("OpenAI-Project", t.projectID)
+	}
+	if t.apiKey != nil {
+		apiKey, err := t.apiKey(req.Context())
+		if err != nil {
+			return nil, fmt.Errorf("failed to get API key: %w", err)
+		}
+		if t.azure {
+			req.Header.Set(openai.AzureAPIKeyHeader, apiKey)
+		} else {
+			req.Header.Set("Authorization", "Bearer "+apiKey)
+		}
+	}
+	if t.token != nil {
+		token, err := t.token(req.Context())
+		if err != nil {
+			return nil, fmt.Errorf("failed to get Azure AD token: %w", err)
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	base := t.base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	resp, err := base.RoundTrip(req)
+	if err == nil && resp.StatusCode >= http.StatusBadRequest {
+		if hint, ok := req.Context().Value(retryAfterKey{}).(*retryAfterHint); ok {
+			hint.delay.Store(int64(assistant.ParseRetryAfter(resp.Header)))
+		}
+	}
+	return resp, err
 }
 
 func (c *Client) ChatStream(ctx context.Context, messages []assistant.Message) (<-chan string, error) {
 	return c.ChatStreamWithTools(ctx, messages, nil, "")
 }
 
-func (c *Client) ChatStreamWithTools(ctx context.Context, messages []assistant.Message, tools []assistant.Tool, toolChoice assistant.ToolChoice) (<-chan string, error) {
+func (c *Client) ChatStreamWithTools(ctx context.Context, messages []assistant.Message, tools []assistant.Tool, toolChoice assistant.ToolChoice, opts ...assistant.GenerationOption) (<-chan string, error) {
+	events, err := c.ChatStreamEvents(ctx, messages, tools, toolChoice, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return assistant.TextWithToolCalls(ctx, events), nil
+}
+
+func (c *Client) ChatStreamWithUsage(ctx context.Context, messages []assistant.Message) (*assistant.StreamResult, error) {
+	return c.ChatStreamWithToolsAndUsage(ctx, messages, nil, "")
+}
+
+// ChatStreamWithToolsAndUsage streams the completion and reports the token
+// usage OpenAI sends in the final chunk.
+func (c *Client) ChatStreamWithToolsAndUsage(ctx context.Context, messages []assistant.Message, tools []assistant.Tool, toolChoice assistant.ToolChoice, opts ...assistant.GenerationOption) (*assistant.StreamResult, error) {
+	events, err := c.ChatStreamEvents(ctx, messages, tools, toolChoice, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return assistant.NewStreamResult(ctx, events), nil
+}
+
+// ChatStreamEvents streams the completion as typed events. Tool call
+// fragments are reported against their index so callers can reassemble them.
+func (c *Client) ChatStreamEvents(ctx context.Context, messages []assistant.Message, tools []assistant.Tool, toolChoice assistant.ToolChoice, opts ...assistant.GenerationOption) (<-chan assistant.Event, error) {
+	req := c.buildRequest(messages, tools, toolChoice, assistant.NewGenerationOptions(opts...))
+
+	stream, err := c.sdk.CreateChatCompletionStream(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make(chan assistant.Event)
+	go func() {
+		defer close(out)
+		// Closing the stream aborts the HTTP response if the reader went away.
+		defer stream.Close()
+
+		send := func(ev assistant.Event) bool {
+			return assistant.SendEvent(ctx, out, ev)
+		}
+
+		// OpenAI only sends the ID and name on the first fragment of each
+		// tool call, so remember them to tag the following fragments.
+		toolCallIDs := map[int]string{}
+		var toolCallOrder []int
+		endToolCalls := func() bool {
+			for _, idx := range toolCallOrder {
+				if !send(assistant.Event{
+					Type:     assistant.EventToolCallEnd,
+					ToolCall: &assistant.ToolCallDelta{Index: idx, ID: toolCallIDs[idx]},
+				}) {
+					return false
+				}
+			}
+			toolCallOrder = nil
+			return true
+		}
+
+		for {
+			resp, err := stream.Recv()
+			if errors.Is(err, io.EOF) {
+				endToolCalls()
+				return
+			}
+			if err != nil {
+				send(assistant.Event{Type: assistant.EventError, Err: err})
+				return
+			}
+
+			if resp.Usage != nil {
+				if !send(assistant.Event{
+					Type: assistant.EventUsage,
+					Usage: &assistant.UsageMetadata{
+						PromptTokenCount:     int32(resp.Usage.PromptTokens),
+						CandidatesTokenCount: int32(resp.Usage.CompletionTokens),
+						TotalTokenCount:      int32(resp.Usage.TotalTokens),
+					},
+				}) {
+					return
+				}
+			}
+
+			if len(resp.Choices) == 0 {
+				continue
+			}
+			choice := resp.Choices[0]
+
+			if choice.Delta.Content != "" {
+				if !send(assistant.Event{Type: assistant.EventTextDelta, Text: choice.Delta.Content}) {
+					return
+				}
+			}
+
+			for _, tc := range choice.Delta.ToolCalls {
+				idx := 0
+				if tc.Index != nil {
+					idx = *tc.Index
+				}
+				if _, started := toolCallIDs[idx]; !started {
+					toolCallIDs[idx] = tc.ID
+					toolCallOrder = append(toolCallOrder, idx)
+					if !send(assistant.Event{
+						Type:     assistant.EventToolCallStart,
+						ToolCall: &assistant.ToolCallDelta{Index: idx, ID: tc.ID, Name: tc.Function.Name},
+					}) {
+						return
+					}
+				}
+				if tc.Function.Arguments != "" {
+					if !send(assistant.Event{
+						Type:     assistant.EventToolCallDelta,
+						ToolCall: &assistant.ToolCallDelta{Index: idx, ID: toolCallIDs[idx], Arguments: tc.Function.Arguments},
+					}) {
+						return
+					}
+				}
+			}
+
+			if choice.FinishReason != "" && choice.FinishReason != openai.FinishReasonNull {
+				if !endToolCalls() || !send(assistant.Event{Type: assistant.EventFinish, FinishReason: finishReason(choice.FinishReason)}) {
+					return
+				}
+			}
+		}
+	}()
+
+	return out, nil
+}
+
+// Chat returns the complete completion using the non-streaming endpoint.
+func (c *Client) Chat(ctx context.Context, messages []assistant.Message, opts assistant.ChatOptions) (*assistant.Response, error) {
+	req := c.buildRequest(messages, opts.Tools, opts.ToolChoice, opts.GenerationOptions)
+	req.Stream = false
+	req.StreamOptions = nil
+
+	resp, err := c.sdk.CreateChatCompletion(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	if len(resp.Choices) == 0 {
+		return nil, errors.New("chat completion returned no choices")
+	}
+
+	choice := resp.Choices[0]
+	msg := assistant.Message{
+		Role:    assistant.RoleAssistant,
+		Content: choice.Message.Content,
+	}
+	for _, tc := range choice.Message.ToolCalls {
+		msg.ToolCalls = append(msg.ToolCalls, assistant.ToolCall{
+			ID:   tc.ID,
+			Type: string(tc.Type),
+			Function: assistant.FunctionCall{
+				Name:      tc.Function.Name,
+				Arguments: tc.Function.Arguments,
+			},
+		})
+	}
+
+	return &assistant.Response{
+		Message:      msg,
+		ToolCalls:    msg.ToolCalls,
+		FinishReason: finishReason(choice.FinishReason),
+		Usage: &assistant.UsageMetadata{
+			PromptTokenCount:     int32(resp.Usage.PromptTokens),
+			CandidatesTokenCount: int32(resp.Usage.CompletionTokens),
+			TotalTokenCount:      int32(resp.Usage.TotalTokens),
+		},
+	}, nil
+}
+
+// finishReason normalizes an OpenAI finish reason.
+func finishReason(reason openai.FinishReason) assistant.FinishReason {
+	switch reason {
+	case openai.FinishReasonLength:
+		return assistant.FinishReasonLength
+	case openai.FinishReasonToolCalls, openai.FinishReasonFunctionCall:
+		return assistant.FinishReasonToolCalls
+	case openai.FinishReasonContentFilter:
+		return assistant.FinishReasonContentFilter
+	default:
+		return assistant.FinishReasonStop
+	}
+}
+
+// nonZero maps 0 to the smallest positive float32. go-openai omits zero
+// sampling parameters from the request, which would leave the server default
+// (1.0) in place; the smallest positive value samples just as greedily.
+func nonZero(v float32) float32 {
+	if v == 0 {
+		return math.SmallestNonzeroFloat32
+	}
+	return v
+}
+
+// buildRequest converts messages, tools and generation options into a
+// streaming chat completion request.
+func (c *Client) buildRequest(messages []assistant.Message, tools []assistant.Tool, toolChoice assistant.ToolChoice, gen assistant.GenerationOptions) openai.ChatCompletionRequest {
 	input := make([]openai.ChatCompletionMessage, len(messages))
 	for i, m := range messages {
 		msg := openai.ChatCompletionMessage{
@@ -82,11 +545,36 @@ func (c *Client) ChatStreamWithTools(ctx context.Context, messages []assistant.M
 	}
 
 	req := openai.ChatCompletionRequest{
-		Model:       c.model,
-		Messages:    input,
-		Stream:      true,
-		Temperature: c.temperature,
+		Model:           c.model,
+		Messages:        input,
+		Stream:          true,
+		ReasoningEffort: c.reasoningEffort,
+		// Ask for a trailing chunk with the token usage of the whole request.
+		StreamOptions: &openai.StreamOptions{IncludeUsage: true},
+	}
+	// Reasoning models reject temperature, so the client default is left out
+	// for them.
+	if c.temperature != nil && c.reasoningEffort == "" {
+		req.Temperature = nonZero(*c.temperature)
+	}
+
+	// Apply per-request generation options
+	if gen.Model != "" {
+		req.Model = gen.Model
+	}
+	if gen.Temperature != nil {
+		req.Temperature = nonZero(*gen.Temperature)
+	}
+	if gen.MaxTokens != nil {
+		req.MaxCompletionTokens = *gen.MaxTokens
+	}
+	if gen.TopP != nil {
+		req.TopP = nonZero(*gen.TopP)
+	}
+	if len(gen.StopSequences) > 0 {
+		req.Stop = gen.StopSequences
 	}
+	req.Seed = gen.Seed
 
 	// Add tools if provided
 	if len(tools) > 0 {
@@ -98,6 +586,7 @@ func (c *Client) ChatStreamWithTools(ctx context.Context, messages []assistant.M
 					Name:        t.Function.Name,
 					Description: t.Function.Description,
 					Parameters:  t.Function.Parameters,
+					Strict:      t.Function.Strict,
 				},
 			}
 		}
@@ -107,43 +596,5 @@ func (c *Client) ChatStreamWithTools(ctx context.Context, messages []assistant.M
 		}
 	}
 
-	stream, err := c.sdk.CreateChatCompletionStream(ctx, req)
-	if err != nil {
-		return nil, err
-	}
-
-	out := make(chan string)
-	go func() {
-		defer close(out)
-		defer stream.Close()
-		for {
-			resp, err := stream.Recv()
-			if err != nil {
-				break
-			}
-
-			// Handle tool calls
-			if len(resp.Choices) > 0 && len(resp.Choices[0].Delta.ToolCalls) > 0 {
-				for _, tc := range resp.Choices[0].Delta.ToolCalls {
-					toolCallJSON, _ := json.Marshal(map[string]interface{}{
-						"type": "tool_call",
-						"id":   tc.ID,
-						"function": map[string]string{
-							"name":      tc.Function.Name,
-							"arguments": tc.Function.Arguments,
-						},
-					})
-					out <- string(toolCallJSON)
-				}
-				continue
-			}
-
-			// Handle regular content
-			if len(resp.Choices) > 0 {
-				out <- resp.Choices[0].Delta.Content
-			}
-		}
-	}()
-
-	return out, nil
+	return req
 }
@@ -3,24 +3,37 @@ package openai_test
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
 	sdk "github.com/sashabaranov/go-openai"
 	"github.com/sburchfield/go-assistant-api/assistant"
 	"github.com/sburchfield/go-assistant-api/assistant/provider/openai"
+	"github.com/sburchfield/go-assistant-api/internal/testutil"
 )
 
 type mockStream struct {
 	responses []sdk.ChatCompletionStreamResponse
 	index     int
 	closed    bool
+	err       error // returned instead of io.EOF once responses are exhausted
 }
 
 func (m *mockStream) Recv() (sdk.ChatCompletionStreamResponse, error) {
 	if m.index >= len(m.responses) {
-		return sdk.ChatCompletionStreamResponse{}, errors.New("EOF")
+		if m.err != nil {
+			return sdk.ChatCompletionStreamResponse{}, m.err
+		}
+		return sdk.ChatCompletionStreamResponse{}, io.EOF
 	}
 	resp := m.responses[m.index]
 	m.index++
@@ -32,14 +45,48 @@ func (m *mockStream) Close() error {
 	return nil
 }
 
+// endlessStream keeps producing content until it is closed.
+type endlessStream struct {
+	closeOnce sync.Once
+	closed    chan struct{}
+}
+
+func newEndlessStream() *endlessStream {
+	return &endlessStream{closed: make(chan struct{})}
+}
+
+func (m *endlessStream) Recv() (sdk.ChatCompletionStreamResponse, error) {
+	select {
+	case <-m.closed:
+		return sdk.ChatCompletionStreamResponse{}, errors.New("stream closed")
+	default:
+	}
+	return sdk.ChatCompletionStreamResponse{
+		Choices: []sdk.ChatCompletionStreamChoice{{Delta: sdk.ChatCompletionStreamChoiceDelta{Content: "more"}}},
+	}, nil
+}
+
+func (m *endlessStream) Close() error {
+	m.closeOnce.Do(func() { close(m.closed) })
+	return nil
+}
+
 type mockOpenAIClient struct {
 	stream openai.ChatStream
+	resp   sdk.ChatCompletionResponse
+	req    sdk.ChatCompletionRequest
 }
 
 func (m *mockOpenAIClient) CreateChatCompletionStream(ctx context.Context, req sdk.ChatCompletionRequest) (openai.ChatStream, error) {
+	m.req = req
 	return m.stream, nil
 }
 
+func (m *mockOpenAIClient) CreateChatCompletion(ctx context.Context, req sdk.ChatCompletionRequest) (sdk.ChatCompletionResponse, error) {
+	m.req = req
+	return m.resp, nil
+}
+
 func TestChatStream(t *testing.T) {
 	mockResp := []sdk.ChatCompletionStreamResponse{
 		{Choices: []sdk.ChatCompletionStreamChoice{{Delta: sdk.ChatCompletionStreamChoiceDelta{Content: "Hello"}}}},
@@ -73,3 +120,584 @@ func TestChatStream(t *testing.T) {
 		t.Errorf("expected '%s', got '%s'", expected, result)
 	}
 }
+
+func TestChatStreamEvents_ToolCalls(t *testing.T) {
+	zero := 0
+	mockResp := []sdk.ChatCompletionStreamResponse{
+		{Choices: []sdk.ChatCompletionStreamChoice{{Delta: sdk.ChatCompletionStreamChoiceDelta{Content: "Checking"}}}},
+		{Choices: []sdk.ChatCompletionStreamChoice{{Delta: sdk.ChatCompletionStreamChoiceDelta{ToolCalls: []sdk.ToolCall{
+			{Index: &zero, ID: "call_1", Type: sdk.ToolTypeFunction, Function: sdk.FunctionCall{Name: "get_weather"}},
+		}}}}},
+		{Choices: []sdk.ChatCompletionStreamChoice{{Delta: sdk.ChatCompletionStreamChoiceDelta{ToolCalls: []sdk.ToolCall{
+			{Index: &zero, Function: sdk.FunctionCall{Arguments: `{"location":`}},
+		}}}}},
+		{Choices: []sdk.ChatCompletionStreamChoice{{Delta: sdk.ChatCompletionStreamChoiceDelta{ToolCalls: []sdk.ToolCall{
+			{Index: &zero, Function: sdk.FunctionCall{Arguments: `"Paris"}`}},
+		}}}}},
+		{Choices: []sdk.ChatCompletionStreamChoice{{FinishReason: sdk.FinishReasonToolCalls}}},
+	}
+
+	mockClient := &mockOpenAIClient{
+		stream: &mockStream{responses: mockResp},
+	}
+
+	client := openai.NewClientWithSDK(mockClient, "gpt-3.5-turbo", 0.0)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	events, err := client.ChatStreamEvents(ctx, []assistant.Message{
+		{Role: assistant.RoleUser, Content: "Weather in Paris?"},
+	}, nil, assistant.ToolChoiceAuto)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	var got []assistant.EventType
+	var args string
+	for ev := range events {
+		got = append(got, ev.Type)
+		switch ev.Type {
+		case assistant.EventToolCallStart:
+			if ev.ToolCall.ID != "call_1" || ev.ToolCall.Name != "get_weather" {
+				t.Errorf("unexpected tool call start: %+v", ev.ToolCall)
+			}
+		case assistant.EventToolCallDelta:
+			if ev.ToolCall.ID != "call_1" {
+				t.Errorf("expected delta to carry id 'call_1', got '%s'", ev.ToolCall.ID)
+			}
+			args += ev.ToolCall.Arguments
+		case assistant.EventFinish:
+			if ev.FinishReason != assistant.FinishReasonToolCalls {
+				t.Errorf("expected finish reason 'tool-calls', got '%s'", ev.FinishReason)
+			}
+		case assistant.EventError:
+			t.Errorf("unexpected error event: %v", ev.Err)
+		}
+	}
+
+	expected := []assistant.EventType{
+		assistant.EventTextDelta,
+		assistant.EventToolCallStart,
+		assistant.EventToolCallDelta,
+		assistant.EventToolCallDelta,
+		assistant.EventToolCallEnd,
+		assistant.EventFinish,
+	}
+	if len(got) != len(expected) {
+		t.Fatalf("expected events %v, got %v", expected, got)
+	}
+	for i := range expected {
+		if got[i] != expected[i] {
+			t.Errorf("event %d: expected %s, got %s", i, expected[i], got[i])
+		}
+	}
+
+	if args != `{"location":"Paris"}` {
+		t.Errorf("unexpected arguments: %s", args)
+	}
+}
+
+func TestChatStreamWithTools_ToolCallChunks(t *testing.T) {
+	zero := 0
+	mockClient := &mockOpenAIClient{stream: &mockStream{responses: []sdk.ChatCompletionStreamResponse{
+		{Choices: []sdk.ChatCompletionStreamChoice{{Delta: sdk.ChatCompletionStreamChoiceDelta{ToolCalls: []sdk.ToolCall{
+			{Index: &zero, ID: "call_1", Type: sdk.ToolTypeFunction, Function: sdk.FunctionCall{Name: "get_weather"}},
+		}}}}},
+		{Choices: []sdk.ChatCompletionStreamChoice{{Delta: sdk.ChatCompletionStreamChoiceDelta{ToolCalls: []sdk.ToolCall{
+			{Index: &zero, Function: sdk.FunctionCall{Arguments: `{"location":"Paris"}`}},
+		}}}}},
+		{Choices: []sdk.ChatCompletionStreamChoice{{FinishReason: sdk.FinishReasonToolCalls}}},
+	}}}
+
+	client := openai.NewClientWithSDK(mockClient, "gpt-3.5-turbo", 0.0)
+	stream, err := client.ChatStreamWithTools(context.Background(), []assistant.Message{
+		{Role: assistant.RoleUser, Content: "Weather in Paris?"},
+	}, nil, assistant.ToolChoiceAuto)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	var chunks []string
+	for chunk := range stream {
+		chunks = append(chunks, chunk)
+	}
+
+	// Only the first chunk of a call carries its ID and name.
+	expected := []string{
+		`{"function":{"arguments":"","name":"get_weather"},"id":"call_1","type":"tool_call"}`,
+		`{"function":{"arguments":"{\"location\":\"Paris\"}","name":""},"id":"","type":"tool_call"}`,
+	}
+	if strings.Join(chunks, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("expected chunks %q, got %q", expected, chunks)
+	}
+}
+
+func TestChatStreamEvents_RecvError(t *testing.T) {
+	mockResp := []sdk.ChatCompletionStreamResponse{
+		{Choices: []sdk.ChatCompletionStreamChoice{{Delta: sdk.ChatCompletionStreamChoiceDelta{Content: "Hello"}}}},
+	}
+
+	mockClient := &mockOpenAIClient{
+		stream: &mockStream{responses: mockResp, err: errors.New("connection reset")},
+	}
+
+	client := openai.NewClientWithSDK(mockClient, "gpt-3.5-turbo", 0.0)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	events, err := client.ChatStreamEvents(ctx, []assistant.Message{
+		{Role: assistant.RoleUser, Content: "Say something"},
+	}, nil, "")
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	result := assistant.NewStreamResult(ctx, events)
+	for range result.TextChannel {
+	}
+
+	if err := result.Err(); err == nil || err.Error() != "connection reset" {
+		t.Errorf("expected 'connection reset' error, got %v", err)
+	}
+}
+
+func TestChatStreamWithUsage(t *testing.T) {
+	mockResp := []sdk.ChatCompletionStreamResponse{
+		{Choices: []sdk.ChatCompletionStreamChoice{{Delta: sdk.ChatCompletionStreamChoiceDelta{Content: "Hello"}}}},
+		{Choices: []sdk.ChatCompletionStreamChoice{{FinishReason: sdk.FinishReasonStop}}},
+		{Usage: &sdk.Usage{PromptTokens: 9, CompletionTokens: 1, TotalTokens: 10}},
+	}
+
+	mockClient := &mockOpenAIClient{
+		stream: &mockStream{responses: mockResp},
+	}
+
+	client := openai.NewClientWithSDK(mockClient, "gpt-3.5-turbo", 0.0)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	result, err := client.ChatStreamWithUsage(ctx, []assistant.Message{
+		{Role: assistant.RoleUser, Content: "Say something"},
+	})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	for range result.TextChannel {
+	}
+
+	if mockClient.req.StreamOptions == nil || !mockClient.req.StreamOptions.IncludeUsage {
+		t.Error("expected request to set stream_options.include_usage")
+	}
+
+	usage := result.GetUsage()
+	if usage == nil {
+		t.Fatal("expected non-nil UsageMetadata after consuming TextChannel")
+	}
+	if usage.PromptTokenCount != 9 || usage.CandidatesTokenCount != 1 || usage.TotalTokenCount != 10 {
+		t.Errorf("unexpected usage metadata: %+v", usage)
+	}
+}
+
+func TestChat(t *testing.T) {
+	mockClient := &mockOpenAIClient{
+		resp: sdk.ChatCompletionResponse{
+			Choices: []sdk.ChatCompletionChoice{{
+				Message: sdk.ChatCompletionMessage{
+					Role: sdk.ChatMessageRoleAssistant,
+					ToolCalls: []sdk.ToolCall{{
+						ID:       "call_1",
+						Type:     sdk.ToolTypeFunction,
+						Function: sdk.FunctionCall{Name: "get_weather", Arguments: `{"location":"Paris"}`},
+					}},
+				},
+				FinishReason: sdk.FinishReasonToolCalls,
+			}},
+			Usage: sdk.Usage{PromptTokens: 20, CompletionTokens: 8, TotalTokens: 28},
+		},
+	}
+
+	client := openai.NewClientWithSDK(mockClient, "gpt-3.5-turbo", 0.0)
+
+	resp, err := client.Chat(context.Background(), []assistant.Message{
+		{Role: assistant.RoleUser, Content: "Weather in Paris?"},
+	}, assistant.ChatOptions{})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if mockClient.req.Stream {
+		t.Error("expected a non-streaming request")
+	}
+	if resp.FinishReason != assistant.FinishReasonToolCalls {
+		t.Errorf("expected finish reason 'tool-calls', got '%s'", resp.FinishReason)
+	}
+	if len(resp.ToolCalls) != 1 || resp.ToolCalls[0].Function.Name != "get_weather" {
+		t.Errorf("unexpected tool calls: %+v", resp.ToolCalls)
+	}
+	if resp.Message.Role != assistant.RoleAssistant {
+		t.Errorf("expected role '%s', got '%s'", assistant.RoleAssistant, resp.Message.Role)
+	}
+	if resp.Usage == nil || resp.Usage.TotalTokenCount != 28 {
+		t.Errorf("unexpected usage metadata: %+v", resp.Usage)
+	}
+}
+
+func TestChatStreamEvents_GenerationOptions(t *testing.T) {
+	mockClient := &mockOpenAIClient{
+		stream: &mockStream{},
+	}
+
+	client := openai.NewClientWithSDK(mockClient, "gpt-3.5-turbo", 0.2)
+
+	events, err := client.ChatStreamEvents(context.Background(), []assistant.Message{
+		{Role: assistant.RoleUser, Content: "Say something"},
+	}, nil, "",
+		assistant.WithModel("gpt-4o"),
+		assistant.WithMaxTokens(64),
+		assistant.WithTopP(0.9),
+		assistant.WithStopSequences("END"),
+		assistant.WithSeed(7),
+	)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	for range events {
+	}
+
+	req := mockClient.req
+	if req.Model != "gpt-4o" {
+		t.Errorf("expected model override 'gpt-4o', got '%s'", req.Model)
+	}
+	if req.Temperature != 0.2 {
+		t.Errorf("expected default temperature 0.2, got %v", req.Temperature)
+	}
+	if req.MaxCompletionTokens != 64 || req.TopP != 0.9 {
+		t.Errorf("unexpected max tokens/top_p: %d/%v", req.MaxCompletionTokens, req.TopP)
+	}
+	if len(req.Stop) != 1 || req.Stop[0] != "END" {
+		t.Errorf("unexpected stop sequences: %v", req.Stop)
+	}
+	if req.Seed == nil || *req.Seed != 7 {
+		t.Errorf("expected seed 7, got %v", req.Seed)
+	}
+}
+
+func TestChatStreamEvents_AbandonedReader(t *testing.T) {
+	baseline := runtime.NumGoroutine()
+
+	stream := newEndlessStream()
+	client := openai.NewClientWithSDK(&mockOpenAIClient{stream: stream}, "gpt-3.5-turbo", 0.0)
+	ctx, cancel := context.WithCancel(context.Background())
+
+	events, err := client.ChatStreamEvents(ctx, []assistant.Message{
+		{Role: assistant.RoleUser, Content: "Say something"},
+	}, nil, "")
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	<-events
+	cancel()
+
+	select {
+	case <-stream.closed:
+	case <-time.After(2 * time.Second):
+		t.Fatal("expected upstream stream to be closed after cancellation")
+	}
+	testutil.WaitForGoroutines(t, baseline)
+}
+
+func TestChatStream_AbandonedReader(t *testing.T) {
+	baseline := runtime.NumGoroutine()
+
+	stream := newEndlessStream()
+	client := openai.NewClientWithSDK(&mockOpenAIClient{stream: stream}, "gpt-3.5-turbo", 0.0)
+	ctx, cancel := context.WithCancel(context.Background())
+
+	text, err := client.ChatStream(ctx, []assistant.Message{
+		{Role: assistant.RoleUser, Content: "Say something"},
+	})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	<-text
+	cancel()
+
+	select {
+	case <-stream.closed:
+	case <-time.After(2 * time.Second):
+		t.Fatal("expected upstream stream to be closed after cancellation")
+	}
+	testutil.WaitForGoroutines(t, baseline)
+}
+
+// recordRequests serves a fixed chat completion and records the requests it receives.
+func recordRequests(t *testing.T) (*httptest.Server, *[]*http.Request) {
+	t.Helper()
+	var requests []*http.Request
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = io.WriteString(w, `{"choices":[{"message":{"role":"assistant","content":"Hi"},"finish_reason":"stop"}],"usage":{"prompt_tokens":1,"completion_tokens":1,"total_tokens":2}}`)
+	}))
+	t.Cleanup(server.Close)
+	return server, &requests
+}
+
+func TestNewClient_BaseURLAndHeaders(t *testing.T) {
+	server, requests := recordRequests(t)
+
+	client := openai.NewClient("", "llama3", 0.0,
+		openai.WithBaseURL(server.URL+"/v1"),
+		openai.WithHTTPClient(server.Client()),
+		openai.WithOrganization("org-1"),
+		openai.WithProject("proj-1"),
+	)
+
+	resp, err := client.Chat(context.Background(), []assistant.Message{
+		{Role: assistant.RoleUser, Content: "Hello"},
+	}, assistant.ChatOptions{})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if resp.Message.Content != "Hi" {
+		t.Errorf("expected 'Hi', got %q", resp.Message.Content)
+	}
+
+	req := (*requests)[0]
+	if req.URL.Path != "/v1/chat/completions" {
+		t.Errorf("expected request to /v1/chat/completions, got %s", req.URL.Path)
+	}
+	if req.Header.Get("OpenAI-Organization") != "org-1" || req.Header.Get("OpenAI-Project") != "proj-1" {
+		t.Errorf("expected organization and project headers, got %v", req.Header)
+	}
+}
+
+func TestNewClient_Azure(t *testing.T) {
+	server, requests := recordRequests(t)
+
+	client := openai.NewClient("azure-key", "gpt-4o", 0.0,
+		openai.WithAzure(server.URL, "my-deployment", ""),
+		openai.WithHTTPClient(server.Client()),
+	)
+
+	if _, err := client.Chat(context.Background(), []assistant.Message{
+		{Role: assistant.RoleUser, Content: "Hello"},
+	}, assistant.ChatOptions{}); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	req := (*requests)[0]
+	if req.URL.Path != "/openai/deployments/my-deployment/chat/completions" {
+		t.Errorf("expected request to the deployment, got %s", req.URL.Path)
+	}
+	if got := req.URL.Query().Get("api-version"); got != openai.DefaultAzureAPIVersion {
+		t.Errorf("expected api-version %s, got %q", openai.DefaultAzureAPIVersion, got)
+	}
+	if req.Header.Get("api-key") != "azure-key" || req.Header.Get("Authorization") != "" {
+		t.Errorf("expected api-key authentication, got %v", req.Header)
+	}
+}
+
+func TestNewClient_AzureADTokenProvider(t *testing.T) {
+	server, requests := recordRequests(t)
+
+	calls := 0
+	client := openai.NewClient("", "gpt-4o", 0.0,
+		openai.WithAzure(server.URL, "my-deployment", "2025-01-01-preview"),
+		openai.WithAzureADTokenProvider(func(context.Context) (string, error) {
+			calls++
+			return fmt.Sprintf("token-%d", calls), nil
+		}),
+		openai.WithHTTPClient(server.Client()),
+	)
+
+	for range 2 {
+		if _, err := client.Chat(context.Background(), []assistant.Message{
+			{Role: assistant.RoleUser, Content: "Hello"},
+		}, assistant.ChatOptions{}); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+	}
+
+	if got := (*requests)[0].URL.Query().Get("api-version"); got != "2025-01-01-preview" {
+		t.Errorf("expected api-version 2025-01-01-preview, got %q", got)
+	}
+	// A fresh token is fetched for every request.
+	for i, req := range *requests {
+		if want := fmt.Sprintf("Bearer token-%d", i+1); req.Header.Get("Authorization") != want {
+			t.Errorf("request %d: expected Authorization %q, got %q", i, want, req.Header.Get("Authorization"))
+		}
+		if req.Header.Get("api-key") != "" {
+			t.Errorf("request %d: expected no api-key header with Azure AD auth", i)
+		}
+	}
+}
+
+func TestNewClient_AzureADTokenError(t *testing.T) {
+	server, _ := recordRequests(t)
+
+	client := openai.NewClient("", "gpt-4o", 0.0,
+		openai.WithAzure(server.URL, "my-deployment", ""),
+		openai.WithAzureADTokenProvider(func(context.Context) (string, error) {
+			return "", errors.New("no identity")
+		}),
+		openai.WithHTTPClient(server.Client()),
+	)
+
+	_, err := client.Chat(context.Background(), []assistant.Message{
+		{Role: assistant.RoleUser, Content: "Hello"},
+	}, assistant.ChatOptions{})
+	if err == nil || !strings.Contains(err.Error(), "no identity") {
+		t.Fatalf("expected the token error to be reported, got %v", err)
+	}
+}
+
+func TestNewClient_APIKeyProvider(t *testing.T) {
+	server, requests := recordRequests(t)
+
+	calls := 0
+	keys := func(context.Context) (string, error) {
+		calls++
+		return fmt.Sprintf("sk-%d", calls), nil
+	}
+	client := openai.NewClient("", "gpt-4o", 0.0,
+		openai.WithBaseURL(server.URL+"/v1"),
+		openai.WithHTTPClient(server.Client()),
+		openai.WithAPIKeyProvider(keys),
+	)
+	azure := openai.NewClient("", "gpt-4o", 0.0,
+		openai.WithAzure(server.URL, "my-deployment", ""),
+		openai.WithHTTPClient(server.Client()),
+		openai.WithAPIKeyProvider(keys),
+	)
+
+	for _, c := range []*openai.Client{client, client, azure} {
+		if _, err := c.Chat(context.Background(), []assistant.Message{
+			{Role: assistant.RoleUser, Content: "Hello"},
+		}, assistant.ChatOptions{}); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+	}
+
+	// A fresh key is fetched for every request.
+	for i, want := range []string{"Bearer sk-1", "Bearer sk-2"} {
+		if got := (*requests)[i].Header.Get("Authorization"); got != want {
+			t.Errorf("request %d: expected Authorization %q, got %q", i, want, got)
+		}
+	}
+	if got := (*requests)[2].Header.Get("api-key"); got != "sk-3" {
+		t.Errorf("Azure request: expected api-key sk-3, got %q", got)
+	}
+}
+
+func TestNewClient_RetryAfter(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Retry-After", "3")
+		w.WriteHeader(http.StatusTooManyRequests)
+		_, _ = io.WriteString(w, `{"error":{"message":"Rate limit reached","type":"requests","code":"rate_limit_exceeded"}}`)
+	}))
+	defer server.Close()
+
+	client := openai.NewClient("sk-test", "gpt-4o", 0.0,
+		openai.WithBaseURL(server.URL+"/v1"),
+		openai.WithHTTPClient(server.Client()),
+	)
+
+	_, err := client.ChatStreamEvents(context.Background(), []assistant.Message{
+		{Role: assistant.RoleUser, Content: "Hello"},
+	}, nil, "")
+
+	var retryErr *openai.RetryAfterError
+	if !errors.As(err, &retryErr) || retryErr.RetryAfter != 3*time.Second {
+		t.Fatalf("expected a RetryAfterError of 3s, got %v", err)
+	}
+	var apiErr *sdk.APIError
+	if !errors.As(err, &apiErr) || apiErr.HTTPStatusCode != http.StatusTooManyRequests {
+		t.Errorf("expected the underlying APIError to be reachable, got %v", err)
+	}
+}
+
+func TestNewClient_Temperature(t *testing.T) {
+	var bodies []map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]any
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("failed to decode request body: %v", err)
+		}
+		bodies = append(bodies, body)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = io.WriteString(w, `{"choices":[{"message":{"role":"assistant","content":"Hi"},"finish_reason":"stop"}]}`)
+	}))
+	defer server.Close()
+
+	newClient := func(opts ...openai.Option) *openai.Client {
+		opts = append(opts, openai.WithBaseURL(server.URL+"/v1"), openai.WithHTTPClient(server.Client()))
+		return openai.NewClient("sk-test", "gpt-4o", 0.0, opts...)
+	}
+	hello := []assistant.Message{{Role: assistant.RoleUser, Content: "Hello"}}
+	zero := assistant.ChatOptions{GenerationOptions: assistant.NewGenerationOptions(assistant.WithTemperature(0))}
+	for _, req := range []struct {
+		client *openai.Client
+		opts   assistant.ChatOptions
+	}{
+		{newClient(), assistant.ChatOptions{}},
+		{newClient(), zero},
+		{newClient(openai.WithTemperature(0)), assistant.ChatOptions{}},
+	} {
+		if _, err := req.client.Chat(context.Background(), hello, req.opts); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+	}
+
+	// An unset temperature is left to the server.
+	if temperature, ok := bodies[0]["temperature"]; ok {
+		t.Errorf("expected no temperature, got %v", temperature)
+	}
+	// An explicit zero must reach the server rather than being omitted,
+	// which would leave the server default of 1 in place.
+	for i, body := range bodies[1:] {
+		temperature, ok := body["temperature"].(float64)
+		if !ok || temperature <= 0 || temperature > 1e-6 {
+			t.Errorf("request %d: expected a near-zero temperature, got %v", i+1, body["temperature"])
+		}
+	}
+}
+
+func TestNewClient_Reasoning(t *testing.T) {
+	var body map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = io.WriteString(w, `{"choices":[{"message":{"role":"assistant","content":"Hi"},"finish_reason":"stop"}]}`)
+	}))
+	defer server.Close()
+
+	client := openai.NewClient("sk-test", "o3", 0.0,
+		openai.WithBaseURL(server.URL+"/v1"),
+		openai.WithHTTPClient(server.Client()),
+		openai.WithReasoning("high", ""),
+	)
+	tool := assistant.Tool{Type: "function", Function: assistant.ToolFunction{
+		Name:       "lookup",
+		Parameters: map[string]interface{}{"type": "object"},
+		Strict:     true,
+	}}
+	if _, err := client.Chat(context.Background(), []assistant.Message{{Role: assistant.RoleUser, Content: "Hello"}}, assistant.ChatOptions{Tools: []assistant.Tool{tool}}); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if body["reasoning_effort"] != "high" {
+		t.Errorf("expected reasoning_effort high, got %v", body["reasoning_effort"])
+	}
+	if temperature, ok := body["temperature"]; ok {
+		t.Errorf("expected no temperature for a reasoning model, got %v", temperature)
+	}
+	tools, _ := body["tools"].([]any)
+	if len(tools) != 1 || tools[0].(map[string]any)["function"].(map[string]any)["strict"] != true {
+		t.Errorf("expected a strict function, got %v", body["tools"])
+	}
+}
@@ -0,0 +1,524 @@
+package openai
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	openai "github.com/sashabaranov/go-openai"
+	"github.com/sburchfield/go-assistant-api/assistant"
+)
+
+// DefaultBaseURL is the OpenAI API endpoint used unless WithBaseURL or
+// WithAzure is given.
+const DefaultBaseURL = "https://api.openai.com/v1"
+
+// ResponsesClient streams completions from the OpenAI Responses API, which
+// newer models require for reasoning and built-in tools. It accepts the same
+// options as NewClient.
+type ResponsesClient struct {
+	httpClient  *http.Client
+	baseURL     string
+	apiKey      string
+	azure       bool
+	orgID       string
+	model       string
+	temperature *float32
+	reasoning   *reasoningConfig
+}
+
+// NewResponsesClient creates a client for the Responses API. Reasoning models
+// reject temperature, so a zero temperature is not sent and the model's
+// default applies; WithTemperature sets an explicit default, and
+// assistant.WithTemperature sets it per request.
+func NewResponsesClient(apiKey string, model string, temperature float32, opts ...Option) *ResponsesClient {
+	var o clientOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	c := &ResponsesClient{
+		httpClient:  o.newHTTPClient(),
+		baseURL:     DefaultBaseURL,
+		apiKey:      apiKey,
+		orgID:       o.orgID,
+		model:       model,
+		temperature: o.temperature,
+	}
+	if temperature != 0 && o.temperature == nil {
+		c.temperature = &temperature
+	}
+	if o.reasoningEffort != "" || o.reasoningSummary != "" {
+		c.reasoning = &reasoningConfig{Effort: o.reasoningEffort, Summary: o.reasoningSummary}
+	}
+	if o.azureEndpoint != "" {
+		// Azure serves the Responses API from its versionless v1 surface,
+		// addressed by deployment name in place of the model.
+		c.baseURL = strings.TrimRight(o.azureEndpoint, "/") + "/openai/v1"
+		c.azure = o.azureADToken == nil
+		if o.azureDeployment != "" {
+			c.model = o.azureDeployment
+		}
+	}
+	if o.baseURL != "" {
+		c.baseURL = strings.TrimRight(o.baseURL, "/")
+	}
+	return c
+}
+
+func (c *ResponsesClient) ChatStream(ctx context.Context, messages []assistant.Message) (<-chan string, error) {
+	return c.ChatStreamWithTools(ctx, messages, nil, "")
+}
+
+func (c *ResponsesClient) ChatStreamWithTools(ctx context.Context, messages []assistant.Message, tools []assistant.Tool, toolChoice assistant.ToolChoice, opts ...assistant.GenerationOption) (<-chan string, error) {
+	events, err := c.ChatStreamEvents(ctx, messages, tools, toolChoice, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return assistant.TextWithToolCalls(ctx, events), nil
+}
+
+func (c *ResponsesClient) ChatStreamWithUsage(ctx context.Context, messages []assistant.Message) (*assistant.StreamResult, error) {
+	return c.ChatStreamWithToolsAndUsage(ctx, messages, nil, "")
+}
+
+// ChatStreamWithToolsAndUsage streams the response and reports the token
+// usage sent with response.completed.
+func (c *ResponsesClient) ChatStreamWithToolsAndUsage(ctx context.Context, messages []assistant.Message, tools []assistant.Tool, toolChoice assistant.ToolChoice, opts ...assistant.GenerationOption) (*assistant.StreamResult, error) {
+	events, err := c.ChatStreamEvents(ctx, messages, tools, toolChoice, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return assistant.NewStreamResult(ctx, events), nil
+}
+
+// ChatStreamEvents streams the response as typed events. Function calls are
+// reported against their position among the calls in the response.
+func (c *ResponsesClient) ChatStreamEvents(ctx context.Context, messages []assistant.Message, tools []assistant.Tool, toolChoice assistant.ToolChoice, opts ...assistant.GenerationOption) (<-chan assistant.Event, error) {
+	req, err := c.buildRequest(messages, tools, toolChoice, assistant.NewGenerationOptions(opts...))
+	if err != nil {
+		return nil, err
+	}
+	req.Stream = true
+
+	body, err := c.post(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make(chan assistant.Event)
+	go func() {
+		defer close(out)
+		processResponseStream(ctx, body, out)
+	}()
+
+	return out, nil
+}
+
+// Chat returns the complete response using a non-streaming request.
+func (c *ResponsesClient) Chat(ctx context.Context, messages []assistant.Message, opts assistant.ChatOptions) (*assistant.Response, error) {
+	req, err := c.buildRequest(messages, opts.Tools, opts.ToolChoice, opts.GenerationOptions)
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := c.post(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+
+	var resp responseObject
+	if err := json.NewDecoder(body).Decode(&resp); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+	if resp.Error != nil {
+		return nil, resp.Error.apiError()
+	}
+
+	msg := assistant.Message{Role: assistant.RoleAssistant}
+	for _, item := range resp.Output {
+		switch item.Type {
+		case "reasoning":
+			for _, part := range item.Summary {
+				msg.Reasoning = append(msg.Reasoning, assistant.ReasoningBlock{Text: part.Text})
+			}
+		case "message":
+			for _, part := range item.Content {
+				if part.Type == "output_text" {
+					msg.Content += part.Text
+				}
+			}
+		case "function_call":
+			msg.ToolCalls = append(msg.ToolCalls, assistant.ToolCall{
+				ID:   item.CallID,
+				Type: "function",
+				Function: assistant.FunctionCall{
+					Name:      item.Name,
+					Arguments: item.Arguments,
+				},
+			})
+		}
+	}
+
+	return &assistant.Response{
+		Message:      msg,
+		ToolCalls:    msg.ToolCalls,
+		FinishReason: resp.finishReason(len(msg.ToolCalls) > 0),
+		Usage:        resp.Usage.metadata(),
+	}, nil
+}
+
+// post sends a Responses request and returns the response body. Non-2xx
+// responses are returned as an *openai.APIError, like the chat client's,
+// wrapped in a *RetryAfterError when the server sent Retry-After.
+func (c *ResponsesClient) post(ctx context.Context, req *responsesRequest) (io.ReadCloser, error) {
+	payload, err := json.Marshal(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode request: %w", err)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+"/responses", bytes.NewReader(payload))
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	if c.azure {
+		httpReq.Header.Set(openai.AzureAPIKeyHeader, c.apiKey)
+	} else if c.apiKey != "" {
+		httpReq.Header.Set("Authorization", "Bearer "+c.apiKey)
+	}
+	if c.orgID != "" {
+		httpReq.Header.Set("OpenAI-Organization", c.orgID)
+	}
+
+	resp, err := c.httpClient.Do(httpReq)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode/100 != 2 {
+		defer resp.Body.Close()
+		apiErr := &openai.APIError{Message: resp.Status}
+		var errBody struct {
+			Error *openai.APIError `json:"error"`
+		}
+		if json.NewDecoder(resp.Body).Decode(&errBody) == nil && errBody.Error != nil {
+			apiErr = errBody.Error
+		}
+		apiErr.HTTPStatus = resp.Status
+		apiErr.HTTPStatusCode = resp.StatusCode
+		if delay := assistant.ParseRetryAfter(resp.Header); delay > 0 {
+			return nil, &RetryAfterError{Err: apiErr, RetryAfter: delay}
+		}
+		return nil, apiErr
+	}
+	return resp.Body, nil
+}
+
+// buildRequest converts messages, tools and generation options into a
+// Responses request.
+func (c *ResponsesClient) buildRequest(messages []assistant.Message, tools []assistant.Tool, toolChoice assistant.ToolChoice, gen assistant.GenerationOptions) (*responsesRequest, error) {
+	if len(messages) == 0 {
+		return nil, errors.New("ChatStream: no messages provided")
+	}
+	if len(gen.StopSequences) > 0 {
+		return nil, &assistant.UnsupportedOptionError{Provider: "openai-responses", Option: "stop_sequences"}
+	}
+	if gen.Seed != nil {
+		return nil, &assistant.UnsupportedOptionError{Provider: "openai-responses", Option: "seed"}
+	}
+
+	req := &responsesRequest{
+		Model:       c.model,
+		Input:       convertInput(messages),
+		Temperature: c.temperature,
+		Reasoning:   c.reasoning,
+	}
+
+	// Apply per-request generation options
+	if gen.Model != "" {
+		req.Model = gen.Model
+	}
+	if gen.Temperature != nil {
+		req.Temperature = gen.Temperature
+	}
+	req.MaxOutputTokens = gen.MaxTokens
+	req.TopP = gen.TopP
+
+	// Add tools if provided
+	if len(tools) > 0 {
+		req.Tools = make([]responsesTool, len(tools))
+		for i, t := range tools {
+			// Functions are strict by default in the Responses API, which
+			// rejects schemas that Chat Completions accepts, so strict mode
+			// is only used when the tool asks for it.
+			req.Tools[i] = responsesTool{
+				Type:        "function",
+				Name:        t.Function.Name,
+				Description: t.Function.Description,
+				Parameters:  t.Function.Parameters,
+				Strict:      t.Function.Strict,
+			}
+		}
+		req.ToolChoice = string(toolChoice)
+	}
+
+	return req, nil
+}
+
+// convertInput converts messages to Responses input items. Tool calls and
+// their results are separate items rather than parts of a message.
+func convertInput(messages []assistant.Message) []inputItem {
+	var items []inputItem
+	for _, m := range messages {
+		switch {
+		case m.Role == assistant.RoleTool:
+			items = append(items, inputItem{Type: "function_call_output", CallID: m.ToolCallID, Output: m.Content})
+		case m.Role == assistant.RoleAssistant && len(m.ToolCalls) > 0:
+			if m.Content != "" {
+				items = append(items, inputItem{Role: m.Role, Content: m.Content})
+			}
+			for _, tc := range m.ToolCalls {
+				items = append(items, inputItem{
+					Type:      "function_call",
+					CallID:    tc.ID,
+					Name:      tc.Function.Name,
+					Arguments: tc.Function.Arguments,
+				})
+			}
+		default:
+			items = append(items, inputItem{Role: m.Role, Content: m.Content})
+		}
+	}
+	return items
+}
+
+// processResponseStream translates Responses server-sent events into
+// assistant events until the stream ends or ctx is done.
+func processResponseStream(ctx context.Context, body io.ReadCloser, out chan<- assistant.Event) {
+	// Closing the body aborts the HTTP response if the reader went away.
+	defer body.Close()
+
+	send := func(ev assistant.Event) bool {
+		return assistant.SendEvent(ctx, out, ev)
+	}
+
+	// Output items are numbered across messages, reasoning and function
+	// calls, so map the function calls onto their position among the calls,
+	// and each part of a reasoning summary onto a reasoning block.
+	toolCalls := map[int]*assistant.ToolCallDelta{}
+	type summaryPart struct{ item, part int }
+	reasoning := map[summaryPart]int{}
+
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		data, ok := strings.CutPrefix(scanner.Text(), "data:")
+		if !ok {
+			continue
+		}
+
+		var event responseStreamEvent
+		if err := json.Unmarshal([]byte(strings.TrimSpace(data)), &event); err != nil {
+			send(assistant.Event{Type: assistant.EventError, Err: fmt.Errorf("failed to decode stream event: %w", err)})
+			return
+		}
+
+		switch event.Type {
+		case "response.output_text.delta":
+			if !send(assistant.Event{Type: assistant.EventTextDelta, Text: event.Delta}) {
+				return
+			}
+		case "response.reasoning_summary_text.delta":
+			key := summaryPart{event.OutputIndex, event.SummaryIndex}
+			index, ok := reasoning[key]
+			if !ok {
+				index = len(reasoning)
+				reasoning[key] = index
+			}
+			if !send(assistant.Event{
+				Type:      assistant.EventReasoningDelta,
+				Reasoning: &assistant.ReasoningDelta{Index: index, Text: event.Delta},
+			}) {
+				return
+			}
+		case "response.output_item.added":
+			if event.Item == nil || event.Item.Type != "function_call" {
+				continue
+			}
+			tc := &assistant.ToolCallDelta{Index: len(toolCalls), ID: event.Item.CallID, Name: event.Item.Name}
+			toolCalls[event.OutputIndex] = tc
+			if !send(assistant.Event{Type: assistant.EventToolCallStart, ToolCall: tc}) {
+				return
+			}
+		case "response.function_call_arguments.delta":
+			if tc, ok := toolCalls[event.OutputIndex]; ok && event.Delta != "" {
+				if !send(assistant.Event{
+					Type:     assistant.EventToolCallDelta,
+					ToolCall: &assistant.ToolCallDelta{Index: tc.Index, ID: tc.ID, Arguments: event.Delta},
+				}) {
+					return
+				}
+			}
+		case "response.output_item.done":
+			if tc, ok := toolCalls[event.OutputIndex]; ok {
+				if !send(assistant.Event{
+					Type:     assistant.EventToolCallEnd,
+					ToolCall: &assistant.ToolCallDelta{Index: tc.Index, ID: tc.ID},
+				}) {
+					return
+				}
+			}
+		case "response.completed", "response.incomplete":
+			if event.Response == nil {
+				continue
+			}
+			if !send(assistant.Event{Type: assistant.EventFinish, FinishReason: event.Response.finishReason(len(toolCalls) > 0)}) {
+				return
+			}
+			send(assistant.Event{Type: assistant.EventUsage, Usage: event.Response.Usage.metadata()})
+			return
+		case "response.failed":
+			err := &openai.APIError{Message: "response failed"}
+			if event.Response != nil && event.Response.Error != nil {
+				err = event.Response.Error.apiError()
+			}
+			send(assistant.Event{Type: assistant.EventError, Err: err})
+			return
+		case "error":
+			send(assistant.Event{Type: assistant.EventError, Err: &openai.APIError{Code: event.Code, Message: event.Message}})
+			return
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		send(assistant.Event{Type: assistant.EventError, Err: fmt.Errorf("stream error: %w", err)})
+		return
+	}
+	send(assistant.Event{Type: assistant.EventError, Err: fmt.Errorf("stream error: %w", io.ErrUnexpectedEOF)})
+}
+
+// Wire types for the Responses API. Only the fields this package reads or
+// writes are declared.
+
+type responsesRequest struct {
+	Model           string           `json:"model"`
+	Input           []inputItem      `json:"input"`
+	Reasoning       *reasoningConfig `json:"reasoning,omitempty"`
+	Temperature     *float32         `json:"temperature,omitempty"`
+	TopP            *float32         `json:"top_p,omitempty"`
+	MaxOutputTokens *int             `json:"max_output_tokens,omitempty"`
+	Tools           []responsesTool  `json:"tools,omitempty"`
+	ToolChoice      string           `json:"tool_choice,omitempty"`
+	Stream          bool             `json:"stream,omitempty"`
+}
+
+type reasoningConfig struct {
+	Effort  string `json:"effort,omitempty"`
+	Summary string `json:"summary,omitempty"`
+}
+
+// inputItem is a message, function_call or function_call_output input item.
+type inputItem struct {
+	Type      string `json:"type,omitempty"`
+	Role      string `json:"role,omitempty"`
+	Content   string `json:"content,omitempty"`
+	CallID    string `json:"call_id,omitempty"`
+	Name      string `json:"name,omitempty"`
+	Arguments string `json:"arguments,omitempty"`
+	Output    string `json:"output,omitempty"`
+}
+
+type responsesTool struct {
+	Type        string                 `json:"type"`
+	Name        string                 `json:"name"`
+	Description string                 `json:"description,omitempty"`
+	Parameters  map[string]interface{} `json:"parameters,omitempty"`
+	Strict      bool                   `json:"strict"`
+}
+
+type responseObject struct {
+	Status            string `json:"status"`
+	IncompleteDetails *struct {
+		Reason string `json:"reason"`
+	} `json:"incomplete_details"`
+	Output []outputItem   `json:"output"`
+	Usage  responsesUsage `json:"usage"`
+	Error  *responseError `json:"error"`
+}
+
+// finishReason normalizes the response status. Completed responses that
+// called functions report tool-calls, matching Chat Completions.
+func (r *responseObject) finishReason(calledTools bool) assistant.FinishReason {
+	if r.IncompleteDetails != nil {
+		switch r.IncompleteDetails.Reason {
+		case "max_output_tokens":
+			return assistant.FinishReasonLength
+		case "content_filter":
+			return assistant.FinishReasonContentFilter
+		}
+	}
+	if r.Status == "failed" {
+		return assistant.FinishReasonError
+	}
+	if calledTools {
+		return assistant.FinishReasonToolCalls
+	}
+	return assistant.FinishReasonStop
+}
+
+type outputItem struct {
+	Type      string `json:"type"`
+	CallID    string `json:"call_id"`
+	Name      string `json:"name"`
+	Arguments string `json:"arguments"`
+	Content   []struct {
+		Type string `json:"type"`
+		Text string `json:"text"`
+	} `json:"content"`
+	Summary []struct {
+		Text string `json:"text"`
+	} `json:"summary"`
+}
+
+type responsesUsage struct {
+	InputTokens  int32 `json:"input_tokens"`
+	OutputTokens int32 `json:"output_tokens"`
+	TotalTokens  int32 `json:"total_tokens"`
+}
+
+func (u responsesUsage) metadata() *assistant.UsageMetadata {
+	return &assistant.UsageMetadata{
+		PromptTokenCount:     u.InputTokens,
+		CandidatesTokenCount: u.OutputTokens,
+		TotalTokenCount:      u.TotalTokens,
+	}
+}
+
+type responseError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *responseError) apiError() *openai.APIError {
+	return &openai.APIError{Code: e.Code, Message: e.Message}
+}
+
+type responseStreamEvent struct {
+	Type         string          `json:"type"`
+	OutputIndex  int             `json:"output_index"`
+	SummaryIndex int             `json:"summary_index"`
+	Delta        string          `json:"delta"`
+	Item         *outputItem     `json:"item"`
+	Response     *responseObject `json:"response"`
+
+	// error events
+	Code    any    `json:"code"`
+	Message string `json:"message"`
+}
@@ -0,0 +1,449 @@
+package openai_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"testing"
+	"time"
+
+	sdk "github.com/sashabaranov/go-openai"
+	"github.com/sburchfield/go-assistant-api/assistant"
+	"github.com/sburchfield/go-assistant-api/assistant/provider/openai"
+	"github.com/sburchfield/go-assistant-api/internal/testutil"
+)
+
+// newResponsesClient returns a client whose requests are served by handler instead of OpenAI.
+func newResponsesClient(t *testing.T, handler http.HandlerFunc, opts ...openai.Option) *openai.ResponsesClient {
+	t.Helper()
+
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	opts = append([]openai.Option{
+		openai.WithBaseURL(server.URL + "/v1"),
+		openai.WithHTTPClient(server.Client()),
+	}, opts...)
+	return openai.NewResponsesClient("test-key", "gpt-5", 0.7, opts...)
+}
+
+// streamResponseEvents serves each event as a server-sent event, named after its type.
+func streamResponseEvents(events ...string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, data := range events {
+			var event struct {
+				Type string `json:"type"`
+			}
+			_ = json.Unmarshal([]byte(data), &event)
+			fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event.Type, data)
+		}
+	}
+}
+
+var responsesHello = []string{
+	`{"type":"response.created","response":{"id":"resp_1","status":"in_progress"}}`,
+	`{"type":"response.output_item.added","output_index":0,"item":{"type":"message","id":"msg_1","role":"assistant","content":[]}}`,
+	`{"type":"response.output_text.delta","output_index":0,"content_index":0,"delta":"Hello"}`,
+	`{"type":"response.output_text.delta","output_index":0,"content_index":0,"delta":" world"}`,
+	`{"type":"response.output_item.done","output_index":0,"item":{"type":"message","id":"msg_1"}}`,
+	`{"type":"response.completed","response":{"id":"resp_1","status":"completed","usage":{"input_tokens":3,"output_tokens":2,"total_tokens":5}}}`,
+}
+
+var responsesWeatherCall = []string{
+	`{"type":"response.created","response":{"id":"resp_2","status":"in_progress"}}`,
+	`{"type":"response.output_item.added","output_index":0,"item":{"type":"reasoning","id":"rs_1"}}`,
+	`{"type":"response.output_item.done","output_index":0,"item":{"type":"reasoning","id":"rs_1"}}`,
+	`{"type":"response.output_item.added","output_index":1,"item":{"type":"function_call","id":"fc_1","call_id":"call_1","name":"get_weather","arguments":""}}`,
+	`{"type":"response.function_call_arguments.delta","output_index":1,"item_id":"fc_1","delta":"{\"location\":"}`,
+	`{"type":"response.function_call_arguments.delta","output_index":1,"item_id":"fc_1","delta":"\"Paris\"}"}`,
+	`{"type":"response.function_call_arguments.done","output_index":1,"item_id":"fc_1","arguments":"{\"location\":\"Paris\"}"}`,
+	`{"type":"response.output_item.done","output_index":1,"item":{"type":"function_call","id":"fc_1","call_id":"call_1","name":"get_weather","arguments":"{\"location\":\"Paris\"}"}}`,
+	`{"type":"response.completed","response":{"id":"resp_2","status":"completed","usage":{"input_tokens":20,"output_tokens":8,"total_tokens":28}}}`,
+}
+
+var weatherTool = assistant.Tool{
+	Type: "function",
+	Function: assistant.ToolFunction{
+		Name:        "get_weather",
+		Description: "Get the weather for a location",
+		Parameters: map[string]interface{}{
+			"type":       "object",
+			"properties": map[string]interface{}{"location": map[string]interface{}{"type": "string"}},
+		},
+	},
+}
+
+func TestResponsesClient_ChatStreamWithUsage(t *testing.T) {
+	client := newResponsesClient(t, streamResponseEvents(responsesHello...))
+
+	result, err := client.ChatStreamWithUsage(context.Background(), []assistant.Message{
+		{Role: assistant.RoleUser, Content: "Say something"},
+	})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	var text string
+	for chunk := range result.TextChannel {
+		text += chunk
+	}
+	if text != "Hello world" {
+		t.Errorf("expected 'Hello world', got %q", text)
+	}
+
+	usage := result.GetUsage()
+	if usage == nil || usage.PromptTokenCount != 3 || usage.CandidatesTokenCount != 2 || usage.TotalTokenCount != 5 {
+		t.Errorf("unexpected usage metadata: %+v", usage)
+	}
+	if result.GetFinishReason() != assistant.FinishReasonStop {
+		t.Errorf("expected finish reason 'stop', got '%s'", result.GetFinishReason())
+	}
+}
+
+func TestResponsesClient_ChatStreamEventsToolCalls(t *testing.T) {
+	client := newResponsesClient(t, streamResponseEvents(responsesWeatherCall...))
+
+	events, err := client.ChatStreamEvents(context.Background(), []assistant.Message{
+		{Role: assistant.RoleUser, Content: "What's the weather in Paris?"},
+	}, []assistant.Tool{weatherTool}, assistant.ToolChoiceAuto)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	resp, err := assistant.Collect(events)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if len(resp.ToolCalls) != 1 {
+		t.Fatalf("expected 1 tool call, got %+v", resp.ToolCalls)
+	}
+	tc := resp.ToolCalls[0]
+	if tc.ID != "call_1" || tc.Function.Name != "get_weather" || tc.Function.Arguments != `{"location":"Paris"}` {
+		t.Errorf("unexpected tool call: %+v", tc)
+	}
+	if resp.FinishReason != assistant.FinishReasonToolCalls {
+		t.Errorf("expected finish reason 'tool-calls', got '%s'", resp.FinishReason)
+	}
+	if resp.Usage == nil || resp.Usage.TotalTokenCount != 28 {
+		t.Errorf("unexpected usage metadata: %+v", resp.Usage)
+	}
+}
+
+func TestResponsesClient_Request(t *testing.T) {
+	var header http.Header
+	var path string
+	var body struct {
+		Model           string           `json:"model"`
+		Input           []map[string]any `json:"input"`
+		Tools           []map[string]any `json:"tools"`
+		ToolChoice      string           `json:"tool_choice"`
+		MaxOutputTokens int              `json:"max_output_tokens"`
+		Stream          bool             `json:"stream"`
+	}
+	client := newResponsesClient(t, func(w http.ResponseWriter, r *http.Request) {
+		header, path = r.Header, r.URL.Path
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		streamResponseEvents(responsesHello...)(w, r)
+	}, openai.WithOrganization("org-1"))
+
+	events, err := client.ChatStreamEvents(context.Background(), []assistant.Message{
+		{Role: assistant.RoleSystem, Content: "Be brief."},
+		{Role: assistant.RoleUser, Content: "What's the weather in Paris?"},
+		{Role: assistant.RoleAssistant, ToolCalls: []assistant.ToolCall{{
+			ID:       "call_1",
+			Type:     "function",
+			Function: assistant.FunctionCall{Name: "get_weather", Arguments: `{"location":"Paris"}`},
+		}}},
+		{Role: assistant.RoleTool, ToolCallID: "call_1", Content: "Sunny, 22C"},
+	}, []assistant.Tool{weatherTool}, assistant.ToolChoiceRequired, assistant.WithMaxTokens(64))
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	for range events {
+	}
+
+	if path != "/v1/responses" {
+		t.Errorf("expected request to /v1/responses, got %s", path)
+	}
+	if header.Get("Authorization") != "Bearer test-key" || header.Get("OpenAI-Organization") != "org-1" {
+		t.Errorf("unexpected headers: %v", header)
+	}
+	if body.Model != "gpt-5" || body.MaxOutputTokens != 64 || !body.Stream || body.ToolChoice != "required" {
+		t.Errorf("unexpected request parameters: %+v", body)
+	}
+	if len(body.Tools) != 1 || body.Tools[0]["type"] != "function" || body.Tools[0]["name"] != "get_weather" {
+		t.Errorf("unexpected tools: %+v", body.Tools)
+	}
+
+	if len(body.Input) != 4 {
+		t.Fatalf("expected 4 input items, got %+v", body.Input)
+	}
+	if body.Input[0]["role"] != "system" || body.Input[1]["role"] != "user" {
+		t.Errorf("unexpected message items: %+v", body.Input[:2])
+	}
+	if body.Input[2]["type"] != "function_call" || body.Input[2]["call_id"] != "call_1" || body.Input[2]["arguments"] != `{"location":"Paris"}` {
+		t.Errorf("unexpected function_call item: %+v", body.Input[2])
+	}
+	if body.Input[3]["type"] != "function_call_output" || body.Input[3]["call_id"] != "call_1" || body.Input[3]["output"] != "Sunny, 22C" {
+		t.Errorf("unexpected function_call_output item: %+v", body.Input[3])
+	}
+}
+
+func TestResponsesClient_RequestDefaults(t *testing.T) {
+	var bodies []map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]any
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		bodies = append(bodies, body)
+		streamResponseEvents(responsesHello...)(w, r)
+	}))
+	defer server.Close()
+
+	client := openai.NewResponsesClient("test-key", "gpt-5", 0,
+		openai.WithBaseURL(server.URL+"/v1"),
+		openai.WithHTTPClient(server.Client()),
+		openai.WithReasoning("low", "auto"),
+	)
+	strictTool := weatherTool
+	strictTool.Function.Strict = true
+	for _, opts := range [][]assistant.GenerationOption{nil, {assistant.WithTemperature(0)}} {
+		events, err := client.ChatStreamEvents(context.Background(), []assistant.Message{
+			{Role: assistant.RoleUser, Content: "What's the weather in Paris?"},
+		}, []assistant.Tool{weatherTool, strictTool}, "", opts...)
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		for range events {
+		}
+	}
+
+	if len(bodies) != 2 {
+		t.Fatalf("expected 2 requests, got %d", len(bodies))
+	}
+	// An unset temperature is left to the model, since reasoning models
+	// reject it; an explicit zero is sent.
+	if temperature, ok := bodies[0]["temperature"]; ok {
+		t.Errorf("expected no temperature, got %v", temperature)
+	}
+	if temperature, ok := bodies[1]["temperature"]; !ok || temperature != 0.0 {
+		t.Errorf("expected temperature 0, got %v", bodies[1]["temperature"])
+	}
+	reasoning, _ := bodies[0]["reasoning"].(map[string]any)
+	if reasoning["effort"] != "low" || reasoning["summary"] != "auto" {
+		t.Errorf("unexpected reasoning: %v", bodies[0]["reasoning"])
+	}
+	tools, _ := bodies[0]["tools"].([]any)
+	if len(tools) != 2 || tools[0].(map[string]any)["strict"] != false || tools[1].(map[string]any)["strict"] != true {
+		t.Errorf("expected strict false unless requested, got %v", tools)
+	}
+
+	// WithTemperature sets an explicit zero default.
+	client = openai.NewResponsesClient("test-key", "gpt-4o", 0,
+		openai.WithBaseURL(server.URL+"/v1"),
+		openai.WithHTTPClient(server.Client()),
+		openai.WithTemperature(0),
+	)
+	events, err := client.ChatStreamEvents(context.Background(), []assistant.Message{{Role: assistant.RoleUser, Content: "Hello"}}, nil, "")
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	for range events {
+	}
+	if temperature, ok := bodies[2]["temperature"]; !ok || temperature != 0.0 {
+		t.Errorf("expected temperature 0, got %v", bodies[2]["temperature"])
+	}
+}
+
+func TestResponsesClient_ReasoningSummary(t *testing.T) {
+	client := newResponsesClient(t, streamResponseEvents(
+		`{"type":"response.output_item.added","output_index":0,"item":{"type":"reasoning","id":"rs_1"}}`,
+		`{"type":"response.reasoning_summary_text.delta","output_index":0,"summary_index":0,"delta":"Checking "}`,
+		`{"type":"response.reasoning_summary_text.delta","output_index":0,"summary_index":0,"delta":"the forecast."}`,
+		`{"type":"response.reasoning_summary_text.delta","output_index":0,"summary_index":1,"delta":"It is sunny."}`,
+		`{"type":"response.output_item.done","output_index":0,"item":{"type":"reasoning","id":"rs_1"}}`,
+		`{"type":"response.output_item.added","output_index":1,"item":{"type":"message","id":"msg_1","role":"assistant","content":[]}}`,
+		`{"type":"response.output_text.delta","output_index":1,"content_index":0,"delta":"Sunny."}`,
+		`{"type":"response.completed","response":{"id":"resp_1","status":"completed"}}`,
+	))
+
+	events, err := client.ChatStreamEvents(context.Background(), []assistant.Message{
+		{Role: assistant.RoleUser, Content: "Weather in Paris?"},
+	}, nil, "")
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	resp, err := assistant.Collect(events)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	want := []assistant.ReasoningBlock{{Text: "Checking the forecast."}, {Text: "It is sunny."}}
+	if len(resp.Message.Reasoning) != len(want) || resp.Message.Reasoning[0] != want[0] || resp.Message.Reasoning[1] != want[1] {
+		t.Errorf("expected reasoning %+v, got %+v", want, resp.Message.Reasoning)
+	}
+	if resp.Message.Content != "Sunny." {
+		t.Errorf("expected 'Sunny.', got %q", resp.Message.Content)
+	}
+}
+
+func TestResponsesClient_Chat(t *testing.T) {
+	client := newResponsesClient(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = io.WriteString(w, `{
+			"id": "resp_3", "status": "incomplete",
+			"incomplete_details": {"reason": "max_output_tokens"},
+			"output": [
+				{"type": "reasoning", "id": "rs_1", "summary": [{"type": "summary_text", "text": "Greeting."}]},
+				{"type": "message", "role": "assistant", "content": [{"type": "output_text", "text": "Hello"}]}
+			],
+			"usage": {"input_tokens": 4, "output_tokens": 16, "total_tokens": 20}
+		}`)
+	})
+
+	resp, err := client.Chat(context.Background(), []assistant.Message{
+		{Role: assistant.RoleUser, Content: "Say something"},
+	}, assistant.ChatOptions{})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if resp.Message.Content != "Hello" {
+		t.Errorf("expected 'Hello', got %q", resp.Message.Content)
+	}
+	if len(resp.Message.Reasoning) != 1 || resp.Message.Reasoning[0].Text != "Greeting." {
+		t.Errorf("unexpected reasoning: %+v", resp.Message.Reasoning)
+	}
+	if resp.FinishReason != assistant.FinishReasonLength {
+		t.Errorf("expected finish reason 'length', got '%s'", resp.FinishReason)
+	}
+	if resp.Usage == nil || resp.Usage.TotalTokenCount != 20 {
+		t.Errorf("unexpected usage metadata: %+v", resp.Usage)
+	}
+}
+
+func TestResponsesClient_HTTPError(t *testing.T) {
+	client := newResponsesClient(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusTooManyRequests)
+		_, _ = io.WriteString(w, `{"error":{"message":"Rate limit reached","type":"requests","code":"rate_limit_exceeded"}}`)
+	})
+
+	_, err := client.ChatStreamEvents(context.Background(), []assistant.Message{
+		{Role: assistant.RoleUser, Content: "Say something"},
+	}, nil, "")
+
+	var apiErr *sdk.APIError
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("expected an APIError, got %v", err)
+	}
+	if apiErr.HTTPStatusCode != http.StatusTooManyRequests || apiErr.Code != "rate_limit_exceeded" {
+		t.Errorf("unexpected APIError: %+v", apiErr)
+	}
+}
+
+func TestResponsesClient_ResponseFailed(t *testing.T) {
+	client := newResponsesClient(t, streamResponseEvents(
+		responsesHello[0],
+		`{"type":"response.failed","response":{"id":"resp_1","status":"failed","error":{"code":"server_error","message":"The server had an error"}}}`,
+	))
+
+	events, err := client.ChatStreamEvents(context.Background(), []assistant.Message{
+		{Role: assistant.RoleUser, Content: "Say something"},
+	}, nil, "")
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	_, err = assistant.Collect(events)
+	var apiErr *sdk.APIError
+	if !errors.As(err, &apiErr) || apiErr.Code != "server_error" {
+		t.Fatalf("expected a server_error APIError, got %v", err)
+	}
+}
+
+func TestResponsesClient_UnsupportedStopSequences(t *testing.T) {
+	client := newResponsesClient(t, streamResponseEvents(responsesHello...))
+
+	_, err := client.ChatStreamEvents(context.Background(), []assistant.Message{
+		{Role: assistant.RoleUser, Content: "Say something"},
+	}, nil, "", assistant.WithStopSequences("END"))
+
+	var unsupported *assistant.UnsupportedOptionError
+	if !errors.As(err, &unsupported) || unsupported.Option != "stop_sequences" {
+		t.Fatalf("expected UnsupportedOptionError for stop_sequences, got %v", err)
+	}
+}
+
+func TestResponsesClient_Azure(t *testing.T) {
+	var header http.Header
+	var path, model string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header, path = r.Header, r.URL.Path
+		var body struct {
+			Model string `json:"model"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		model = body.Model
+		streamResponseEvents(responsesHello...)(w, r)
+	}))
+	t.Cleanup(server.Close)
+
+	client := openai.NewResponsesClient("azure-key", "gpt-5", 0.7,
+		openai.WithAzure(server.URL, "my-deployment", ""),
+		openai.WithHTTPClient(server.Client()),
+	)
+	events, err := client.ChatStreamEvents(context.Background(), []assistant.Message{
+		{Role: assistant.RoleUser, Content: "Say something"},
+	}, nil, "")
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	for range events {
+	}
+
+	if path != "/openai/v1/responses" || model != "my-deployment" {
+		t.Errorf("expected the deployment on the Azure v1 endpoint, got %s with model %q", path, model)
+	}
+	if header.Get("api-key") != "azure-key" || header.Get("Authorization") != "" {
+		t.Errorf("expected api-key authentication, got %v", header)
+	}
+}
+
+func TestResponsesClient_AbandonedReader(t *testing.T) {
+	released := make(chan struct{})
+	client := newResponsesClient(t, func(w http.ResponseWriter, r *http.Request) {
+		// Start the stream, then hold it open until the client gives up.
+		_, _ = io.Copy(io.Discard, r.Body)
+		streamResponseEvents(responsesHello[:3]...)(w, r)
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+		close(released)
+	})
+	baseline := runtime.NumGoroutine()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	events, err := client.ChatStreamEvents(ctx, []assistant.Message{
+		{Role: assistant.RoleUser, Content: "Say something"},
+	}, nil, "")
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if ev := <-events; ev.Type != assistant.EventTextDelta {
+		t.Fatalf("expected a text delta, got %+v", ev)
+	}
+	cancel()
+
+	select {
+	case <-released:
+	case <-time.After(2 * time.Second):
+		t.Fatal("expected the HTTP request to be released after cancellation")
+	}
+	testutil.WaitForGoroutines(t, baseline)
+}
@@ -0,0 +1,352 @@
+package provider
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Profile describes one named provider in a profiles file. String fields may
+// reference environment variables as ${VAR} or ${VAR:-default}; write $$ for
+// a literal dollar sign.
+type Profile struct {
+	Provider    string            `json:"provider" yaml:"provider"`
+	Model       string            `json:"model" yaml:"model"`
+	Temperature *float32          `json:"temperature,omitempty" yaml:"temperature"`
+	APIKey      string            `json:"api_key,omitempty" yaml:"api_key"`
+	BaseURL     string            `json:"base_url,omitempty" yaml:"base_url"`
+	Options     map[string]string `json:"options,omitempty" yaml:"options"`
+	// APIKeySecret is a secret reference (see ParseSecretSource) used in place
+	// of APIKey. It and any secrets the provider reads itself are cached for
+	// SecretTTL (a duration such as "10m").
+	APIKeySecret string `json:"api_key_secret,omitempty" yaml:"api_key_secret"`
+	SecretTTL    string `json:"secret_ttl,omitempty" yaml:"secret_ttl"`
+}
+
+// ProfilesFile is the layout of a profiles file. Defaults fill in any field a
+// profile leaves empty; options are merged, with the profile's taking
+// precedence.
+//
+//	defaults:
+//	  temperature: 0.2
+//	profiles:
+//	  fast:
+//	    provider: bedrock
+//	    model: anthropic.claude-3-haiku-20240307-v1:0
+//	    options: {region: us-east-1}
+//	  smart:
+//	    provider: openai
+//	    model: gpt-4o
+//	    api_key_secret: vault:secret/data/llm#openai_api_key
+type ProfilesFile struct {
+	Defaults Profile            `json:"defaults" yaml:"defaults"`
+	Profiles map[string]Profile `json:"profiles" yaml:"profiles"`
+}
+
+// Config returns the Config New needs to build the profile's provider.
+func (p Profile) Config() (Config, error) {
+	cfg := Config{
+		Model:       p.Model,
+		APIKey:      p.APIKey,
+		BaseURL:     p.BaseURL,
+		Options:     p.Options,
+		Temperature: p.Temperature,
+	}
+	ttl, err := parseSecretTTL(p.SecretTTL)
+	if err != nil {
+		return Config{}, fmt.Errorf("secret_ttl: %w", err)
+	}
+	cfg.SecretTTL = ttl
+	if p.APIKeySecret != "" {
+		src, err := ParseSecretSource(p.APIKeySecret)
+		if err != nil {
+			return Config{}, fmt.Errorf("api_key_secret: %w", err)
+		}
+		cfg.APIKeySource = CachedSecret(src, cfg.SecretTTL)
+	}
+	return cfg, nil
+}
+
+// LoadProfiles reads a YAML (.yaml, .yml) or JSON (.json) profiles file,
+// applies defaults, expands environment variables and validates the result.
+func LoadProfiles(path string) (map[string]Profile, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read profiles: %w", err)
+	}
+	return parseProfiles(data, filepath.Ext(path))
+}
+
+// LoadProviders builds a provider for every profile in the file at path,
+// keyed by profile name.
+func LoadProviders(ctx context.Context, path string) (map[string]ChatProvider, error) {
+	profiles, err := LoadProfiles(path)
+	if err != nil {
+		return nil, err
+	}
+	return NewProviders(ctx, profiles)
+}
+
+// NewProviders builds a provider for every profile, keyed by profile name. It
+// reports every profile that fails rather than stopping at the first, and
+// then closes the providers it did build if they implement io.Closer.
+func NewProviders(ctx context.Context, profiles map[string]Profile) (map[string]ChatProvider, error) {
+	providers := make(map[string]ChatProvider, len(profiles))
+	var errs []error
+	for _, name := range sortedNames(profiles) {
+		cfg, err := profiles[name].Config()
+		if err != nil {
+			errs = append(errs, fmt.Errorf("profile %q: %w", name, err))
+			continue
+		}
+		p, err := New(ctx, profiles[name].Provider, cfg)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("profile %q: %w", name, err))
+			continue
+		}
+		providers[name] = p
+	}
+	if len(errs) > 0 {
+		closeProviders(providers)
+		return nil, errors.Join(errs...)
+	}
+	return providers, nil
+}
+
+// WatchProviders polls the file at path every interval, in the background,
+// until ctx is done. Whenever its content changes from what was last loaded,
+// starting with the content when WatchProviders was called, the providers are
+// rebuilt and passed to onChange. If the file cannot be read or is invalid,
+// onChange receives the error instead and the caller should keep the
+// providers it has; the rebuild is retried on every tick, but each error is
+// reported once. Load the initial providers with LoadProviders before
+// starting the watch.
+//
+// Once onChange has returned, the providers that the watch built before and
+// has now replaced are closed if they implement io.Closer. Providers from
+// LoadProviders are left to the caller.
+func WatchProviders(ctx context.Context, path string, interval time.Duration, onChange func(map[string]ChatProvider, error)) {
+	last, _ := os.ReadFile(path)
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		var current map[string]ChatProvider
+		var reported string
+		fail := func(data []byte, err error) {
+			if key := string(data) + "\x00" + err.Error(); key != reported {
+				reported = key
+				onChange(nil, err)
+			}
+		}
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+
+			data, err := os.ReadFile(path)
+			if err != nil {
+				fail(nil, fmt.Errorf("failed to read profiles: %w", err))
+				continue
+			}
+			if bytes.Equal(data, last) {
+				reported = ""
+				continue
+			}
+
+			profiles, err := parseProfiles(data, filepath.Ext(path))
+			if err != nil {
+				fail(data, err)
+				continue
+			}
+			providers, err := NewProviders(ctx, profiles)
+			if err != nil {
+				fail(data, err)
+				continue
+			}
+			last, reported = data, ""
+			onChange(providers, nil)
+			closeProviders(current)
+			current = providers
+		}
+	}()
+}
+
+// closeProviders closes the providers that implement io.Closer.
+func closeProviders(providers map[string]ChatProvider) {
+	for _, p := range providers {
+		if c, ok := p.(io.Closer); ok {
+			_ = c.Close()
+		}
+	}
+}
+
+// parseProfiles decodes a profiles file in the format implied by ext.
+func parseProfiles(data []byte, ext string) (map[string]Profile, error) {
+	var file ProfilesFile
+	switch strings.ToLower(ext) {
+	case ".yaml", ".yml":
+		dec := yaml.NewDecoder(bytes.NewReader(data))
+		dec.KnownFields(true)
+		if err := dec.Decode(&file); err != nil && !errors.Is(err, io.EOF) {
+			return nil, fmt.Errorf("invalid profiles YAML: %w", err)
+		}
+	case ".json":
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&file); err != nil {
+			return nil, fmt.Errorf("invalid profiles JSON: %w", err)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported profiles format %q (want .yaml, .yml or .json)", ext)
+	}
+
+	if len(file.Profiles) == 0 {
+		return nil, fmt.Errorf("no profiles defined")
+	}
+
+	profiles := make(map[string]Profile, len(file.Profiles))
+	var errs []error
+	for _, name := range sortedNames(file.Profiles) {
+		profile, err := resolveProfile(file.Profiles[name], file.Defaults)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("profile %q: %w", name, err))
+			continue
+		}
+		profiles[name] = profile
+	}
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	return profiles, nil
+}
+
+// resolveProfile applies defaults to p, expands environment variables and
+// validates the result.
+func resolveProfile(p, defaults Profile) (Profile, error) {
+	if p.Provider == "" {
+		p.Provider = defaults.Provider
+	}
+	if p.Model == "" {
+		p.Model = defaults.Model
+	}
+	if p.Temperature == nil {
+		p.Temperature = defaults.Temperature
+	}
+	// The credential is inherited as a whole, so a profile's own key is never
+	// combined with a default secret or vice versa.
+	if p.APIKey == "" && p.APIKeySecret == "" {
+		p.APIKey, p.APIKeySecret = defaults.APIKey, defaults.APIKeySecret
+	}
+	if p.SecretTTL == "" {
+		p.SecretTTL = defaults.SecretTTL
+	}
+	if p.BaseURL == "" {
+		p.BaseURL = defaults.BaseURL
+	}
+	options := make(map[string]string, len(defaults.Options)+len(p.Options))
+	for k, v := range defaults.Options {
+		options[k] = v
+	}
+	for k, v := range p.Options {
+		options[k] = v
+	}
+	p.Options = options
+
+	var errs []error
+	expand := func(field string, s *string) {
+		v, err := expandEnv(*s)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", field, err))
+		}
+		*s = v
+	}
+	expand("provider", &p.Provider)
+	expand("model", &p.Model)
+	expand("api_key", &p.APIKey)
+	expand("base_url", &p.BaseURL)
+	expand("api_key_secret", &p.APIKeySecret)
+	for _, k := range sortedNames(p.Options) {
+		v := p.Options[k]
+		expand("options."+k, &v)
+		p.Options[k] = v
+	}
+	if len(errs) > 0 {
+		return p, errors.Join(errs...)
+	}
+
+	if p.Provider == "" {
+		return p, fmt.Errorf("provider is required")
+	}
+	if !isRegistered(p.Provider) {
+		return p, fmt.Errorf("unknown provider %q (registered: %s)", p.Provider, strings.Join(Providers(), ", "))
+	}
+	if p.Temperature != nil && (*p.Temperature < 0 || *p.Temperature > 2) {
+		return p, fmt.Errorf("temperature %v is outside [0, 2]", *p.Temperature)
+	}
+	if p.APIKey != "" && p.APIKeySecret != "" {
+		return p, fmt.Errorf("set api_key or api_key_secret, not both")
+	}
+	if _, err := p.Config(); err != nil {
+		return p, err
+	}
+	return p, nil
+}
+
+var envRef = regexp.MustCompile(`\$\$|\$\{([A-Za-z_][A-Za-z0-9_]*)(?::-([^}]*))?\}`)
+
+// expandEnv replaces ${VAR} and ${VAR:-default} in s. Referencing an unset
+// variable without a default is an error, so a missing credential is caught
+// when the file is loaded rather than on the first request.
+func expandEnv(s string) (string, error) {
+	var missing []string
+	out := envRef.ReplaceAllStringFunc(s, func(ref string) string {
+		if ref == "$$" {
+			return "$"
+		}
+		m := envRef.FindStringSubmatch(ref)
+		if v, ok := os.LookupEnv(m[1]); ok && v != "" {
+			return v
+		}
+		if strings.Contains(ref, ":-") {
+			return m[2]
+		}
+		missing = append(missing, m[1])
+		return ""
+	})
+	if len(missing) > 0 {
+		return "", fmt.Errorf("environment variable %s is not set", strings.Join(missing, ", "))
+	}
+	return out, nil
+}
+
+func isRegistered(name string) bool {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	_, ok := registry[name]
+	return ok
+}
+
+func sortedNames[V any](m map[string]V) []string {
+	names := make([]string, 0, len(m))
+	for name := range m {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
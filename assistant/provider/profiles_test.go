@@ -0,0 +1,385 @@
+package provider_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/sburchfield/go-assistant-api/assistant/provider"
+)
+
+// writeProfiles writes content to a file named name in a temp dir and returns its path.
+func writeProfiles(t *testing.T, name, content string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadProviders_YAML(t *testing.T) {
+	t.Setenv("GATEWAY_KEY", "secret-key")
+
+	path := writeProfiles(t, "profiles.yaml", `
+defaults:
+  provider: test-gateway
+  temperature: 0.2
+  options:
+    tenant: acme
+    region: us-east-1
+profiles:
+  fast:
+    model: fast-model
+    api_key: ${GATEWAY_KEY}
+    options:
+      region: eu-west-1
+  smart:
+    model: ${SMART_MODEL:-smart-model}
+    temperature: 0
+    base_url: http://gateway.internal/$$v1
+`)
+
+	providers, err := provider.LoadProviders(context.Background(), path)
+	if err != nil {
+		t.Fatalf("LoadProviders: %v", err)
+	}
+	if len(providers) != 2 {
+		t.Fatalf("got %d providers, want 2", len(providers))
+	}
+
+	fast := providers["fast"].(*fakeProvider).cfg
+	if fast.Model != "fast-model" || fast.APIKey != "secret-key" || fast.Temperature == nil || *fast.Temperature != 0.2 {
+		t.Errorf("fast cfg = %+v", fast)
+	}
+	if fast.Options["tenant"] != "acme" || fast.Options["region"] != "eu-west-1" {
+		t.Errorf("fast options = %v, want defaults merged with overrides", fast.Options)
+	}
+
+	smart := providers["smart"].(*fakeProvider).cfg
+	if smart.Model != "smart-model" {
+		t.Errorf("smart model = %q, want default from ${SMART_MODEL:-smart-model}", smart.Model)
+	}
+	if smart.Temperature == nil || *smart.Temperature != 0 {
+		t.Errorf("smart temperature = %v, want explicit 0 to override default", smart.Temperature)
+	}
+	if smart.BaseURL != "http://gateway.internal/$v1" {
+		t.Errorf("smart base URL = %q", smart.BaseURL)
+	}
+}
+
+func TestLoadProviders_JSON(t *testing.T) {
+	path := writeProfiles(t, "profiles.json", `{
+  "profiles": {
+    "vision": {"provider": "test-gateway", "model": "vision-model", "temperature": 0.7}
+  }
+}`)
+
+	providers, err := provider.LoadProviders(context.Background(), path)
+	if err != nil {
+		t.Fatalf("LoadProviders: %v", err)
+	}
+	cfg := providers["vision"].(*fakeProvider).cfg
+	if cfg.Model != "vision-model" || cfg.Temperature == nil || *cfg.Temperature != 0.7 {
+		t.Errorf("vision cfg = %+v", cfg)
+	}
+}
+
+func TestLoadProviders_APIKeySecret(t *testing.T) {
+	t.Setenv("PROFILES_TEST_KEY", "sk-secret")
+
+	path := writeProfiles(t, "profiles.yaml", `
+defaults:
+  provider: test-gateway
+  api_key_secret: env:PROFILES_TEST_KEY
+  secret_ttl: 1m
+profiles:
+  inherited:
+    model: m
+  own-key:
+    model: m
+    api_key: sk-literal
+`)
+
+	providers, err := provider.LoadProviders(context.Background(), path)
+	if err != nil {
+		t.Fatalf("LoadProviders: %v", err)
+	}
+
+	inherited := providers["inherited"].(*fakeProvider).cfg
+	if inherited.APIKeySource == nil {
+		t.Fatal("expected the default secret to be inherited")
+	}
+	if got, err := inherited.APIKeySource.Secret(context.Background()); err != nil || got != "sk-secret" {
+		t.Errorf("Secret() = %q, %v; want sk-secret", got, err)
+	}
+	if inherited.SecretTTL != time.Minute {
+		t.Errorf("SecretTTL = %v, want the default 1m", inherited.SecretTTL)
+	}
+
+	own := providers["own-key"].(*fakeProvider).cfg
+	if own.APIKey != "sk-literal" || own.APIKeySource != nil {
+		t.Errorf("own-key cfg = %+v, want only its literal key", own)
+	}
+}
+
+func TestLoadProfiles_Invalid(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		content string
+		want    []string
+	}{
+		{
+			name:    "missing provider",
+			file:    "p.yaml",
+			content: "profiles:\n  fast:\n    model: m\n",
+			want:    []string{`profile "fast"`, "provider is required"},
+		},
+		{
+			name:    "unknown provider",
+			file:    "p.yaml",
+			content: "profiles:\n  fast:\n    provider: nope\n    model: m\n",
+			want:    []string{`profile "fast"`, `unknown provider "nope"`, "openai"},
+		},
+		{
+			name:    "unset env var",
+			file:    "p.yaml",
+			content: "profiles:\n  smart:\n    provider: test-gateway\n    api_key: ${PROFILES_TEST_UNSET}\n",
+			want:    []string{`profile "smart"`, "api_key", "PROFILES_TEST_UNSET is not set"},
+		},
+		{
+			name:    "temperature out of range",
+			file:    "p.yaml",
+			content: "profiles:\n  hot:\n    provider: test-gateway\n    temperature: 3\n",
+			want:    []string{`profile "hot"`, "temperature"},
+		},
+		{
+			name:    "unknown field",
+			file:    "p.yaml",
+			content: "profiles:\n  fast:\n    provider: test-gateway\n    modle: m\n",
+			want:    []string{"modle"},
+		},
+		{
+			name:    "unknown JSON field",
+			file:    "p.json",
+			content: `{"profiles": {"fast": {"provider": "test-gateway", "modle": "m"}}}`,
+			want:    []string{"modle"},
+		},
+		{
+			name:    "key and secret",
+			file:    "p.yaml",
+			content: "profiles:\n  smart:\n    provider: test-gateway\n    api_key: k\n    api_key_secret: env:KEY\n",
+			want:    []string{`profile "smart"`, "not both"},
+		},
+		{
+			name:    "bad secret reference",
+			file:    "p.yaml",
+			content: "profiles:\n  smart:\n    provider: test-gateway\n    api_key_secret: plaintext\n",
+			want:    []string{`profile "smart"`, "api_key_secret"},
+		},
+		{
+			name:    "no profiles",
+			file:    "p.yaml",
+			content: "",
+			want:    []string{"no profiles defined"},
+		},
+		{
+			name:    "unsupported format",
+			file:    "p.toml",
+			content: "",
+			want:    []string{`unsupported profiles format ".toml"`},
+		},
+		{
+			name:    "every bad profile reported",
+			file:    "p.yaml",
+			content: "profiles:\n  a:\n    model: m\n  b:\n    provider: nope\n",
+			want:    []string{`profile "a"`, `profile "b"`},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := provider.LoadProfiles(writeProfiles(t, tt.file, tt.content))
+			if err == nil {
+				t.Fatal("expected error")
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("error %q does not contain %q", err, want)
+				}
+			}
+		})
+	}
+}
+
+func TestNewProviders_ReportsFactoryErrors(t *testing.T) {
+	_, err := provider.NewProviders(context.Background(), map[string]provider.Profile{
+		"smart": {Provider: "openai"},
+	})
+	if err == nil || !strings.Contains(err.Error(), `profile "smart"`) {
+		t.Fatalf("err = %v, want error naming the profile", err)
+	}
+}
+
+func TestWatchProviders(t *testing.T) {
+	path := writeProfiles(t, "profiles.yaml", "profiles:\n  fast:\n    provider: test-gateway\n    model: v1\n")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	type reload struct {
+		providers map[string]provider.ChatProvider
+		err       error
+	}
+	reloads := make(chan reload, 4)
+	provider.WatchProviders(ctx, path, 5*time.Millisecond, func(p map[string]provider.ChatProvider, err error) {
+		reloads <- reload{p, err}
+	})
+
+	next := func() reload {
+		t.Helper()
+		select {
+		case r := <-reloads:
+			return r
+		case <-time.After(2 * time.Second):
+			t.Fatal("timed out waiting for reload")
+			return reload{}
+		}
+	}
+
+	if err := os.WriteFile(path, []byte("profiles:\n  fast:\n    provider: test-gateway\n    model: v2\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	r := next()
+	if r.err != nil {
+		t.Fatalf("reload error: %v", r.err)
+	}
+	if got := r.providers["fast"].(*fakeProvider).cfg.Model; got != "v2" {
+		t.Errorf("model after reload = %q, want v2", got)
+	}
+
+	if err := os.WriteFile(path, []byte("profiles:\n  fast:\n    model: v3\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	r = next()
+	if r.err == nil || r.providers != nil {
+		t.Errorf("invalid file: got providers=%v err=%v, want only an error", r.providers, r.err)
+	}
+}
+
+// closableProvider counts how often it was closed.
+type closableProvider struct {
+	fakeProvider
+	closed atomic.Int32
+}
+
+func (c *closableProvider) Close() error {
+	c.closed.Add(1)
+	return nil
+}
+
+var (
+	// closableDown makes the test-closable provider fail to build.
+	closableDown atomic.Bool
+	// lastClosable is the test-closable provider built last.
+	lastClosable atomic.Pointer[closableProvider]
+)
+
+func init() {
+	provider.Register("test-closable", func(ctx context.Context, cfg provider.Config) (provider.ChatProvider, error) {
+		if closableDown.Load() {
+			return nil, errors.New("backend down")
+		}
+		p := &closableProvider{fakeProvider: fakeProvider{cfg: cfg}}
+		lastClosable.Store(p)
+		return p, nil
+	})
+}
+
+func TestNewProviders_ClosesBuiltProvidersOnError(t *testing.T) {
+	_, err := provider.NewProviders(context.Background(), map[string]provider.Profile{
+		"fast":  {Provider: "test-closable", Model: "m"},
+		"smart": {Provider: "openai"},
+	})
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	if p := lastClosable.Load(); p == nil || p.cfg.Model != "m" || p.closed.Load() != 1 {
+		t.Errorf("fast provider = %+v, want it built and closed once", p)
+	}
+}
+
+func TestWatchProviders_RetriesAndCloses(t *testing.T) {
+	profiles := func(model string) []byte {
+		return []byte("profiles:\n  fast:\n    provider: test-closable\n    model: " + model + "\n")
+	}
+	path := writeProfiles(t, "profiles.yaml", string(profiles("v1")))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	type reload struct {
+		providers map[string]provider.ChatProvider
+		err       error
+	}
+	reloads := make(chan reload, 16)
+	provider.WatchProviders(ctx, path, 5*time.Millisecond, func(p map[string]provider.ChatProvider, err error) {
+		reloads <- reload{p, err}
+	})
+	next := func() reload {
+		t.Helper()
+		select {
+		case r := <-reloads:
+			return r
+		case <-time.After(2 * time.Second):
+			t.Fatal("timed out waiting for reload")
+			return reload{}
+		}
+	}
+
+	if err := os.WriteFile(path, profiles("v2"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	first := next()
+	if first.err != nil {
+		t.Fatalf("reload error: %v", first.err)
+	}
+
+	// A rebuild that fails is reported once and retried until it succeeds,
+	// even though the file does not change again.
+	closableDown.Store(true)
+	defer closableDown.Store(false)
+	if err := os.WriteFile(path, profiles("v3"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if r := next(); r.err == nil {
+		t.Fatalf("expected a build error, got providers %v", r.providers)
+	}
+	time.Sleep(30 * time.Millisecond)
+	if len(reloads) != 0 {
+		t.Errorf("%d more reloads while the backend was down, want the error reported once", len(reloads))
+	}
+	closableDown.Store(false)
+	second := next()
+	if second.err != nil {
+		t.Fatalf("reload error: %v", second.err)
+	}
+	if got := second.providers["fast"].(*closableProvider).cfg.Model; got != "v3" {
+		t.Errorf("model after retry = %q, want v3", got)
+	}
+
+	// The providers that were replaced are closed, the new ones are not.
+	if n := first.providers["fast"].(*closableProvider).closed.Load(); n != 1 {
+		t.Errorf("replaced provider closed %d times, want 1", n)
+	}
+	if n := second.providers["fast"].(*closableProvider).closed.Load(); n != 0 {
+		t.Errorf("current provider closed %d times, want 0", n)
+	}
+}
@@ -6,7 +6,21 @@ import (
 	"github.com/sburchfield/go-assistant-api/assistant"
 )
 
+// ChatProvider is implemented by every LLM backend. Generation options
+// override the client's defaults for a single request; options a backend
+// cannot honor are rejected with an *assistant.UnsupportedOptionError.
 type ChatProvider interface {
 	ChatStream(ctx context.Context, messages []assistant.Message) (<-chan string, error)
-	ChatStreamWithTools(ctx context.Context, messages []assistant.Message, tools []assistant.Tool, toolChoice assistant.ToolChoice) (<-chan string, error)
+	// ChatStreamWithTools streams text and reports tool calls as JSON chunks
+	// (see assistant.TextWithToolCalls).
+	ChatStreamWithTools(ctx context.Context, messages []assistant.Message, tools []assistant.Tool, toolChoice assistant.ToolChoice, opts ...assistant.GenerationOption) (<-chan string, error)
+	// ChatStreamWithUsage and ChatStreamWithToolsAndUsage stream text like their
+	// counterparts above and report token usage once the stream is drained.
+	ChatStreamWithUsage(ctx context.Context, messages []assistant.Message) (*assistant.StreamResult, error)
+	ChatStreamWithToolsAndUsage(ctx context.Context, messages []assistant.Message, tools []assistant.Tool, toolChoice assistant.ToolChoice, opts ...assistant.GenerationOption) (*assistant.StreamResult, error)
+	// ChatStreamEvents streams the response as typed events, keeping text,
+	// tool calls, usage and the finish reason apart.
+	ChatStreamEvents(ctx context.Context, messages []assistant.Message, tools []assistant.Tool, toolChoice assistant.ToolChoice, opts ...assistant.GenerationOption) (<-chan assistant.Event, error)
+	// Chat waits for the complete response using the provider's non-streaming API.
+	Chat(ctx context.Context, messages []assistant.Message, opts assistant.ChatOptions) (*assistant.Response, error)
 }
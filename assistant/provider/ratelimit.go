@@ -0,0 +1,235 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/sburchfield/go-assistant-api/assistant"
+)
+
+// RateLimits are per-minute limits for one model. Zero fields are unlimited.
+type RateLimits struct {
+	RequestsPerMinute int
+	TokensPerMinute   int
+}
+
+// RateLimitPolicy configures RateLimit.
+type RateLimitPolicy struct {
+	// RateLimits apply to each model that has no entry in Models.
+	RateLimits
+	// Models sets the limits of individual models, keyed by the model
+	// requested with assistant.WithModel; "" is the provider's default model.
+	Models map[string]RateLimits
+	// FailFast returns a *RateLimitError instead of waiting for capacity.
+	FailFast bool
+	// EstimateTokens estimates the tokens a request will use. The estimate is
+	// reserved before the request is sent and replaced by the reported usage
+	// once it completes. Defaults to EstimateTokens.
+	EstimateTokens func(messages []assistant.Message, tools []assistant.Tool, opts assistant.GenerationOptions) int
+}
+
+// RateLimitError is returned by a fail-fast RateLimit provider when a request
+// would exceed its limits.
+type RateLimitError struct {
+	Provider string
+	Model    string
+	// RetryAfter is how long until the request would fit within the limits.
+	RetryAfter time.Duration
+}
+
+func (e *RateLimitError) Error() string {
+	return fmt.Sprintf("client-side rate limit for %s (model %q): retry after %s", e.Provider, e.Model, e.RetryAfter)
+}
+
+// EstimateTokens gives a rough upper estimate of the tokens a request uses:
+// about four characters per prompt token, plus MaxTokens for the output.
+func EstimateTokens(messages []assistant.Message, tools []assistant.Tool, opts assistant.GenerationOptions) int {
+	prompt, _ := json.Marshal(messages)
+	schema, _ := json.Marshal(tools)
+	n := (len(prompt) + len(schema) + 3) / 4
+	if opts.MaxTokens != nil {
+		n += *opts.MaxTokens
+	}
+	return n
+}
+
+// RateLimit wraps p so that requests are paced to stay within requests and
+// tokens per minute, per model. Requests wait, honoring their context, until
+// they fit, or fail with a *RateLimitError if policy.FailFast is set. Tokens
+// are reserved from an estimate up front and reconciled with the usage the
+// backend reports.
+func RateLimit(p ChatProvider, policy RateLimitPolicy) ChatProvider {
+	if policy.EstimateTokens == nil {
+		policy.EstimateTokens = EstimateTokens
+	}
+	r := &rateLimitProvider{
+		inner:    p,
+		name:     providerName(p),
+		policy:   policy,
+		limiters: map[string]*modelLimiter{},
+	}
+	r.streamMethods = streamMethods{r.ChatStreamEvents}
+	return r
+}
+
+type rateLimitProvider struct {
+	streamMethods
+	inner  ChatProvider
+	name   string
+	policy RateLimitPolicy
+
+	mu       sync.Mutex
+	limiters map[string]*modelLimiter
+}
+
+type modelLimiter struct {
+	requests *bucket
+	tokens   *bucket
+}
+
+func (r *rateLimitProvider) Name() string { return r.name }
+
+func (r *rateLimitProvider) ChatStreamEvents(ctx context.Context, messages []assistant.Message, tools []assistant.Tool, toolChoice assistant.ToolChoice, opts ...assistant.GenerationOption) (<-chan assistant.Event, error) {
+	o := assistant.NewGenerationOptions(opts...)
+	done, err := r.acquire(ctx, o.Model, r.policy.EstimateTokens(messages, tools, o))
+	if err != nil {
+		return nil, err
+	}
+
+	events, err := r.inner.ChatStreamEvents(ctx, messages, tools, toolChoice, opts...)
+	if err != nil {
+		done(nil)
+		return nil, err
+	}
+
+	out := make(chan assistant.Event)
+	go func() {
+		defer close(out)
+
+		var usage *assistant.UsageMetadata
+		defer func() { done(usage) }()
+		for ev := range events {
+			if ev.Type == assistant.EventUsage {
+				usage = ev.Usage
+			}
+			if !assistant.SendEvent(ctx, out, ev) {
+				return
+			}
+		}
+	}()
+	return out, nil
+}
+
+func (r *rateLimitProvider) Chat(ctx context.Context, messages []assistant.Message, opts assistant.ChatOptions) (*assistant.Response, error) {
+	done, err := r.acquire(ctx, opts.Model, r.policy.EstimateTokens(messages, opts.Tools, opts.GenerationOptions))
+	if err != nil {
+		return nil, err
+	}
+	resp, err := r.inner.Chat(ctx, messages, opts)
+	if resp != nil {
+		done(resp.Usage)
+	} else {
+		done(nil)
+	}
+	return resp, err
+}
+
+// acquire reserves one request and tokens for model, waiting until the
+// reservation fits. The returned done function reconciles the reservation
+// with the reported usage, if any.
+func (r *rateLimitProvider) acquire(ctx context.Context, model string, tokens int) (done func(*assistant.UsageMetadata), err error) {
+	r.mu.Lock()
+	l, ok := r.limiters[model]
+	if !ok {
+		limits, ok := r.policy.Models[model]
+		if !ok {
+			limits = r.policy.RateLimits
+		}
+		l = &modelLimiter{requests: newBucket(limits.RequestsPerMinute), tokens: newBucket(limits.TokensPerMinute)}
+		r.limiters[model] = l
+	}
+
+	now := time.Now()
+	wait := max(l.requests.wait(1, now), l.tokens.wait(tokens, now))
+	if wait > 0 && r.policy.FailFast {
+		r.mu.Unlock()
+		return nil, &RateLimitError{Provider: r.name, Model: model, RetryAfter: wait}
+	}
+	// Taking the capacity now, even if it goes into debt, queues later
+	// requests behind this one.
+	l.requests.take(1)
+	l.tokens.take(tokens)
+	r.mu.Unlock()
+
+	if wait > 0 {
+		timer := time.NewTimer(wait)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			r.mu.Lock()
+			l.requests.take(-1)
+			l.tokens.take(-tokens)
+			r.mu.Unlock()
+			return nil, fmt.Errorf("waiting for rate limit: %w", ctx.Err())
+		}
+	}
+
+	return func(usage *assistant.UsageMetadata) {
+		if usage == nil || usage.TotalTokenCount <= 0 {
+			return
+		}
+		r.mu.Lock()
+		l.tokens.take(int(usage.TotalTokenCount) - tokens)
+		r.mu.Unlock()
+	}, nil
+}
+
+// bucket is a token bucket that refills its capacity once per minute. A nil
+// bucket is unlimited.
+type bucket struct {
+	capacity float64
+	level    float64
+	perSec   float64
+	updated  time.Time
+}
+
+func newBucket(perMinute int) *bucket {
+	if perMinute <= 0 {
+		return nil
+	}
+	return &bucket{
+		capacity: float64(perMinute),
+		level:    float64(perMinute),
+		perSec:   float64(perMinute) / 60,
+		updated:  time.Now(),
+	}
+}
+
+// wait returns how long until n can be taken. Requests larger than the
+// capacity wait for a full bucket.
+func (b *bucket) wait(n int, now time.Time) time.Duration {
+	if b == nil {
+		return 0
+	}
+	b.level = min(b.capacity, b.level+now.Sub(b.updated).Seconds()*b.perSec)
+	b.updated = now
+
+	need := min(float64(n), b.capacity)
+	if b.level >= need {
+		return 0
+	}
+	return time.Duration((need - b.level) / b.perSec * float64(time.Second))
+}
+
+// take removes n from the bucket, which may leave it in debt; a negative n
+// gives capacity back.
+func (b *bucket) take(n int) {
+	if b == nil {
+		return
+	}
+	b.level = min(b.capacity, b.level-float64(n))
+}
@@ -0,0 +1,138 @@
+package provider_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/sburchfield/go-assistant-api/assistant"
+	"github.com/sburchfield/go-assistant-api/assistant/provider"
+)
+
+// fixedEstimate estimates every request at the next of tokens, repeating the
+// last one.
+func fixedEstimate(tokens ...int) func([]assistant.Message, []assistant.Tool, assistant.GenerationOptions) int {
+	calls := 0
+	return func([]assistant.Message, []assistant.Tool, assistant.GenerationOptions) int {
+		n := tokens[min(calls, len(tokens)-1)]
+		calls++
+		return n
+	}
+}
+
+func TestRateLimit_FailFastPerModel(t *testing.T) {
+	inner := script(reply("Hi"))
+	p := provider.RateLimit(provider.Named("openai", inner), provider.RateLimitPolicy{
+		RateLimits: provider.RateLimits{RequestsPerMinute: 2},
+		Models:     map[string]provider.RateLimits{"big": {RequestsPerMinute: 1}},
+		FailFast:   true,
+	})
+
+	for i := 0; i < 2; i++ {
+		if _, err := collect(t, p); err != nil {
+			t.Fatalf("request %d: unexpected error: %v", i, err)
+		}
+	}
+	_, err := collect(t, p)
+	var limitErr *provider.RateLimitError
+	if !errors.As(err, &limitErr) || limitErr.Provider != "openai" || inner.Calls() != 2 {
+		t.Fatalf("err = %v after %d calls, want RateLimitError after 2", err, inner.Calls())
+	}
+	if limitErr.RetryAfter < 29*time.Second || limitErr.RetryAfter > 30*time.Second {
+		t.Errorf("RetryAfter = %v, want about 30s", limitErr.RetryAfter)
+	}
+	if retryable, _ := provider.IsRetryable(err); retryable {
+		t.Error("IsRetryable = true for a fail-fast rate limit, want false")
+	}
+
+	// Each model has its own limits.
+	big := assistant.ChatOptions{GenerationOptions: assistant.GenerationOptions{Model: "big"}}
+	if _, err := p.Chat(context.Background(), hello, big); err != nil {
+		t.Fatalf("big: unexpected error: %v", err)
+	}
+	if _, err := p.Chat(context.Background(), hello, big); !errors.As(err, &limitErr) || limitErr.Model != "big" {
+		t.Errorf("big: err = %v, want RateLimitError", err)
+	}
+}
+
+func TestRateLimit_ReconcilesUsage(t *testing.T) {
+	// Each estimate uses most of the budget, but the reported usage is 2
+	// tokens, so the difference is given back.
+	inner := script(reply("Hi"))
+	p := provider.RateLimit(inner, provider.RateLimitPolicy{
+		RateLimits:     provider.RateLimits{TokensPerMinute: 1000},
+		FailFast:       true,
+		EstimateTokens: fixedEstimate(900),
+	})
+
+	for i := 0; i < 3; i++ {
+		if _, err := collect(t, p); err != nil {
+			t.Fatalf("request %d: unexpected error: %v", i, err)
+		}
+	}
+
+	// Without usage the estimate stands.
+	inner = script(step{events: []assistant.Event{{Type: assistant.EventTextDelta, Text: "Hi"}}})
+	p = provider.RateLimit(inner, provider.RateLimitPolicy{
+		RateLimits:     provider.RateLimits{TokensPerMinute: 1000},
+		FailFast:       true,
+		EstimateTokens: fixedEstimate(900),
+	})
+	collect(t, p)
+	var limitErr *provider.RateLimitError
+	if _, err := collect(t, p); !errors.As(err, &limitErr) {
+		t.Errorf("err = %v, want RateLimitError", err)
+	}
+}
+
+func TestRateLimit_Waits(t *testing.T) {
+	// 60000 tokens per minute refill at one per millisecond.
+	inner := script(step{events: []assistant.Event{{Type: assistant.EventTextDelta, Text: "Hi"}}})
+	p := provider.RateLimit(inner, provider.RateLimitPolicy{
+		RateLimits:     provider.RateLimits{TokensPerMinute: 60000},
+		EstimateTokens: fixedEstimate(60000, 30, 60000),
+	})
+
+	collect(t, p)
+	start := time.Now()
+	if _, err := collect(t, p); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < 20*time.Millisecond {
+		t.Errorf("second request waited %v, want about 30ms", elapsed)
+	}
+
+	// Waiting gives up when the context is done.
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := p.ChatStreamEvents(ctx, hello, nil, ""); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("err = %v, want context.DeadlineExceeded", err)
+	}
+	if inner.Calls() != 2 {
+		t.Errorf("calls = %d, want 2", inner.Calls())
+	}
+}
+
+func TestRateLimit_FailFastIsNotRetried(t *testing.T) {
+	inner := script(reply("Hi"))
+	p := provider.RateLimit(inner, provider.RateLimitPolicy{
+		RateLimits: provider.RateLimits{RequestsPerMinute: 1},
+		FailFast:   true,
+	})
+	collect(t, p)
+
+	var delays []time.Duration
+	_, err := collect(t, provider.Retry(p, fastRetry(&delays)))
+	var limitErr *provider.RateLimitError
+	if !errors.As(err, &limitErr) || len(delays) != 0 || inner.Calls() != 1 {
+		t.Errorf("err = %v after %d retries, want RateLimitError without retrying", err, len(delays))
+	}
+
+	// Nor does a local limit count against the backend.
+	b := provider.CircuitBreaker(p, provider.BreakerPolicy{FailureThreshold: 1})
+	collect(t, b)
+	if b.State("") != provider.BreakerClosed {
+		t.Errorf("breaker state = %v, want closed", b.State(""))
+	}
+}
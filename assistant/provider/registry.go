@@ -0,0 +1,88 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Config carries the settings a Factory needs to build a provider. Fields a
+// provider has no use for are ignored.
+type Config struct {
+	Model string
+	// Temperature, when set, is the default sampling temperature. Left nil,
+	// each provider uses its own default.
+	Temperature *float32
+	APIKey      string
+	// APIKeySource, when set, supplies the API key in place of APIKey. The
+	// openai, anthropic and gemini providers fetch it for every request, so
+	// wrap it with CachedSecret to avoid a lookup per call. Bedrock takes no
+	// API key and rejects one.
+	APIKeySource SecretSource
+	// SecretTTL is how long secrets the provider reads itself, such as
+	// Gemini's credentials, are cached. Zero selects DefaultSecretTTL.
+	SecretTTL time.Duration
+	BaseURL   string
+	// Options holds provider-specific settings, such as "region" for bedrock
+	// or "project_id" and "location" for gemini.
+	Options map[string]string
+}
+
+// temperature returns the configured temperature, or 0 if it is unset.
+func (c Config) temperature() float32 {
+	if c.Temperature == nil {
+		return 0
+	}
+	return *c.Temperature
+}
+
+// Factory builds a ChatProvider from cfg.
+type Factory func(ctx context.Context, cfg Config) (ChatProvider, error)
+
+var (
+	registryMu sync.RWMutex
+	registry   = map[string]Factory{}
+)
+
+// Register makes a provider available to New and NewProviderFromEnv under
+// name. It panics if name is already registered or factory is nil, so
+// conflicting registrations fail at startup.
+func Register(name string, factory Factory) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	if factory == nil {
+		panic("provider: Register factory is nil for " + name)
+	}
+	if _, dup := registry[name]; dup {
+		panic("provider: Register called twice for " + name)
+	}
+	registry[name] = factory
+}
+
+// New builds the provider registered under name.
+func New(ctx context.Context, name string, cfg Config) (ChatProvider, error) {
+	registryMu.RLock()
+	factory, ok := registry[name]
+	registryMu.RUnlock()
+
+	if !ok {
+		return nil, fmt.Errorf("unsupported provider: %s", name)
+	}
+	return factory(ctx, cfg)
+}
+
+// Providers returns the names of the registered providers, sorted.
+func Providers() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,161 @@
+package provider_test
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/sburchfield/go-assistant-api/assistant/provider"
+	"github.com/sburchfield/go-assistant-api/assistant/provider/openai"
+)
+
+// fakeProvider records the Config it was built from.
+type fakeProvider struct {
+	provider.ChatProvider
+	cfg provider.Config
+}
+
+func init() {
+	provider.Register("test-gateway", func(ctx context.Context, cfg provider.Config) (provider.ChatProvider, error) {
+		return &fakeProvider{cfg: cfg}, nil
+	})
+}
+
+func TestNewProviderFromEnv_RegisteredProvider(t *testing.T) {
+	t.Setenv("LLM_PROVIDER", "test-gateway")
+	t.Setenv("TEST_GATEWAY_MODEL", "gateway-model")
+	t.Setenv("TEST_GATEWAY_API_KEY", "gateway-key")
+	t.Setenv("TEST_GATEWAY_BASE_URL", "http://gateway.internal")
+	t.Setenv("TEST_GATEWAY_TENANT", "acme")
+	t.Setenv("TEMPERATURE", "0.5")
+
+	p, err := provider.NewProviderFromEnv()
+	if err != nil {
+		t.Fatalf("NewProviderFromEnv: %v", err)
+	}
+	fake, ok := p.(*fakeProvider)
+	if !ok {
+		t.Fatalf("provider = %T, want *fakeProvider", p)
+	}
+
+	cfg := fake.cfg
+	if cfg.Model != "gateway-model" || cfg.APIKey != "gateway-key" || cfg.BaseURL != "http://gateway.internal" {
+		t.Errorf("cfg = %+v", cfg)
+	}
+	if cfg.Temperature == nil || *cfg.Temperature != 0.5 {
+		t.Errorf("Temperature = %v, want 0.5", cfg.Temperature)
+	}
+	if got := cfg.Options["tenant"]; got != "acme" {
+		t.Errorf(`Options["tenant"] = %q, want "acme"`, got)
+	}
+	if _, ok := cfg.Options["model"]; ok {
+		t.Errorf("Options should not repeat the model: %v", cfg.Options)
+	}
+}
+
+func TestNew_UnknownProvider(t *testing.T) {
+	if _, err := provider.New(context.Background(), "no-such-provider", provider.Config{}); err == nil {
+		t.Fatal("expected error for unregistered provider")
+	}
+}
+
+func TestRegister_Duplicate(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("expected panic registering a duplicate name")
+		}
+	}()
+	provider.Register("openai", func(ctx context.Context, cfg provider.Config) (provider.ChatProvider, error) {
+		return nil, nil
+	})
+}
+
+func TestProviders_Builtins(t *testing.T) {
+	names := provider.Providers()
+	for _, want := range []string{"anthropic", "bedrock", "gemini", "openai", "test-gateway"} {
+		if !slices.Contains(names, want) {
+			t.Errorf("Providers() = %v, missing %q", names, want)
+		}
+	}
+}
+
+func TestNewProviderFromEnv_OpenAI(t *testing.T) {
+	t.Setenv("LLM_PROVIDER", "openai")
+	t.Setenv("OPENAI_API_KEY", "sk-test")
+	t.Setenv("OPENAI_MODEL", "gpt-test")
+
+	p, err := provider.NewProviderFromEnv()
+	if err != nil {
+		t.Fatalf("NewProviderFromEnv: %v", err)
+	}
+	if _, ok := p.(*openai.Client); !ok {
+		t.Errorf("provider = %T, want *openai.Client", p)
+	}
+
+	t.Setenv("OPENAI_API_MODE", "responses")
+	p, err = provider.NewProviderFromEnv()
+	if err != nil {
+		t.Fatalf("NewProviderFromEnv: %v", err)
+	}
+	if _, ok := p.(*openai.ResponsesClient); !ok {
+		t.Errorf("provider = %T, want *openai.ResponsesClient", p)
+	}
+}
+
+func TestNewProviderFromEnv_OpenAIMissingModel(t *testing.T) {
+	t.Setenv("LLM_PROVIDER", "openai")
+	t.Setenv("OPENAI_API_KEY", "sk-test")
+	t.Setenv("OPENAI_MODEL", "")
+
+	if _, err := provider.NewProviderFromEnv(); err == nil {
+		t.Fatal("expected error without OPENAI_MODEL")
+	}
+}
+
+func TestConfigFromEnv_Aliases(t *testing.T) {
+	t.Setenv("AZURE_OPENAI_ENDPOINT", "https://example.openai.azure.com")
+	t.Setenv("AZURE_OPENAI_DEPLOYMENT", "gpt-deploy")
+	t.Setenv("AWS_REGION", "eu-west-1")
+
+	openaiCfg, err := provider.ConfigFromEnv("openai")
+	if err != nil {
+		t.Fatalf("ConfigFromEnv: %v", err)
+	}
+	if got := openaiCfg.Options["azure_endpoint"]; got != "https://example.openai.azure.com" {
+		t.Errorf(`openai Options["azure_endpoint"] = %q`, got)
+	}
+	if got := openaiCfg.Options["azure_deployment"]; got != "gpt-deploy" {
+		t.Errorf(`openai Options["azure_deployment"] = %q`, got)
+	}
+	bedrockCfg, err := provider.ConfigFromEnv("bedrock")
+	if err != nil {
+		t.Fatalf("ConfigFromEnv: %v", err)
+	}
+	if got := bedrockCfg.Options["region"]; got != "eu-west-1" {
+		t.Errorf(`bedrock Options["region"] = %q, want "eu-west-1"`, got)
+	}
+}
+
+func TestConfigFromEnv_Temperature(t *testing.T) {
+	t.Setenv("TEMPERATURE", "")
+	if cfg, _ := provider.ConfigFromEnv("openai"); cfg.Temperature != nil {
+		t.Errorf("Temperature = %v, want unset", *cfg.Temperature)
+	}
+
+	// An explicit zero is kept apart from an unset temperature.
+	t.Setenv("TEMPERATURE", "0")
+	if cfg, _ := provider.ConfigFromEnv("openai"); cfg.Temperature == nil || *cfg.Temperature != 0 {
+		t.Errorf("Temperature = %v, want 0", cfg.Temperature)
+	}
+}
+
+func TestNew_BedrockRejectsAPIKey(t *testing.T) {
+	_, err := provider.New(context.Background(), "bedrock", provider.Config{
+		Model:        "anthropic.claude-3-haiku",
+		APIKeySource: provider.EnvSecret("BEDROCK_TEST_KEY"),
+	})
+	if err == nil || !strings.Contains(err.Error(), "API keys are not supported") {
+		t.Errorf("err = %v, want API keys rejected", err)
+	}
+}
@@ -0,0 +1,292 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"syscall"
+	"time"
+
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
+	"github.com/aws/smithy-go"
+	sdk "github.com/sashabaranov/go-openai"
+	"github.com/sburchfield/go-assistant-api/assistant"
+	"github.com/sburchfield/go-assistant-api/assistant/provider/anthropic"
+	"github.com/sburchfield/go-assistant-api/assistant/provider/openai"
+	"google.golang.org/genai"
+)
+
+// RetryPolicy configures Retry. Zero fields select the defaults.
+type RetryPolicy struct {
+	// MaxAttempts is the total number of attempts, including the first.
+	// Defaults to 3.
+	MaxAttempts int
+	// BaseDelay is the backoff before the first retry; it doubles for every
+	// further retry, up to MaxDelay, with jitter. They default to 500ms and
+	// 30s; a Retry-After longer than MaxDelay is not waited out.
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+	// Retryable classifies errors. Defaults to IsRetryable.
+	Retryable func(err error) (retryable bool, retryAfter time.Duration)
+	// OnRetry, if set, is called before waiting delay for the next attempt.
+	OnRetry func(attempt int, err error, delay time.Duration)
+}
+
+func (p RetryPolicy) withDefaults() RetryPolicy {
+	if p.MaxAttempts <= 0 {
+		p.MaxAttempts = 3
+	}
+	if p.BaseDelay <= 0 {
+		p.BaseDelay = 500 * time.Millisecond
+	}
+	if p.MaxDelay <= 0 {
+		p.MaxDelay = 30 * time.Second
+	}
+	if p.Retryable == nil {
+		p.Retryable = IsRetryable
+	}
+	return p
+}
+
+// delay returns the wait before the attempt after attempt, and false if the
+// backend asked for a longer wait than MaxDelay.
+func (p RetryPolicy) delay(attempt int, retryAfter time.Duration) (time.Duration, bool) {
+	if retryAfter > 0 {
+		return retryAfter, retryAfter <= p.MaxDelay
+	}
+	ceiling := p.BaseDelay << (attempt - 1)
+	if ceiling <= 0 || ceiling > p.MaxDelay {
+		ceiling = p.MaxDelay
+	}
+	// Jitter keeps clients that failed together from retrying together.
+	half := ceiling / 2
+	return half + rand.N(ceiling-half+1), true
+}
+
+// Retry wraps p so that transient failures are retried with jittered
+// exponential backoff, honoring any Retry-After the backend sends. A stream
+// is only retried while nothing has been delivered to the consumer: failures
+// to start and errors arriving as the first event. Once output has been
+// delivered, errors are passed through as they are.
+func Retry(p ChatProvider, policy RetryPolicy) ChatProvider {
+	r := &retryProvider{inner: p, policy: policy.withDefaults()}
+	r.streamMethods = streamMethods{r.ChatStreamEvents}
+	return r
+}
+
+type retryProvider struct {
+	streamMethods
+	inner  ChatProvider
+	policy RetryPolicy
+}
+
+func (r *retryProvider) ChatStreamEvents(ctx context.Context, messages []assistant.Message, tools []assistant.Tool, toolChoice assistant.ToolChoice, opts ...assistant.GenerationOption) (<-chan assistant.Event, error) {
+	attempt := 0
+	cancel := context.CancelFunc(func() {})
+
+	// open starts the next attempt, retrying failures to start. Each attempt
+	// gets its own context so an abandoned one stops producing.
+	open := func() (<-chan assistant.Event, error) {
+		for {
+			cancel()
+			attempt++
+			var attemptCtx context.Context
+			attemptCtx, cancel = context.WithCancel(ctx)
+			events, err := r.inner.ChatStreamEvents(attemptCtx, messages, tools, toolChoice, opts...)
+			if err == nil {
+				return events, nil
+			}
+			if !r.backoff(ctx, attempt, err) {
+				cancel()
+				return nil, err
+			}
+		}
+	}
+
+	events, err := open()
+	if err != nil {
+		return nil, err
+	}
+
+	out := make(chan assistant.Event)
+	go func() {
+		defer close(out)
+		defer func() { cancel() }()
+
+		for {
+			ev, ok := <-events
+			if !ok {
+				return
+			}
+			if ev.Type == assistant.EventError && r.backoff(ctx, attempt, ev.Err) {
+				if events, err = open(); err != nil {
+					assistant.SendEvent(ctx, out, assistant.Event{Type: assistant.EventError, Err: err})
+					return
+				}
+				continue
+			}
+
+			// Output has reached the consumer, so the rest is passed through.
+			if !assistant.SendEvent(ctx, out, ev) {
+				return
+			}
+			for ev := range events {
+				if !assistant.SendEvent(ctx, out, ev) {
+					return
+				}
+			}
+			return
+		}
+	}()
+	return out, nil
+}
+
+func (r *retryProvider) Chat(ctx context.Context, messages []assistant.Message, opts assistant.ChatOptions) (*assistant.Response, error) {
+	for attempt := 1; ; attempt++ {
+		resp, err := r.inner.Chat(ctx, messages, opts)
+		if err == nil || !r.backoff(ctx, attempt, err) {
+			return resp, err
+		}
+	}
+}
+
+// backoff reports whether err, from the given attempt, should be retried, and
+// if so waits before returning. It returns false if ctx is done while waiting.
+func (r *retryProvider) backoff(ctx context.Context, attempt int, err error) bool {
+	if attempt >= r.policy.MaxAttempts || ctx.Err() != nil {
+		return false
+	}
+	retryable, retryAfter := r.policy.Retryable(err)
+	if !retryable {
+		return false
+	}
+	delay, ok := r.policy.delay(attempt, retryAfter)
+	if !ok {
+		return false
+	}
+	if r.policy.OnRetry != nil {
+		r.policy.OnRetry(attempt, err, delay)
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// IsRetryable reports whether err is a transient failure worth retrying:
+// rate limiting, overload, 5xx responses, timeouts and dropped connections,
+// as reported by any of the built-in providers. retryAfter is the delay the
+// backend asked for, or zero.
+func IsRetryable(err error) (retryable bool, retryAfter time.Duration) {
+	if err == nil || errors.Is(err, context.Canceled) {
+		return false, 0
+	}
+
+	// Local wrappers refuse requests on purpose: an open circuit or a
+	// fail-fast rate limit asks callers to back off, not to wait and retry.
+	var openErr *CircuitOpenError
+	var limitErr *RateLimitError
+	if errors.As(err, &openErr) || errors.As(err, &limitErr) {
+		return false, 0
+	}
+
+	var openaiRetry *openai.RetryAfterError
+	if errors.As(err, &openaiRetry) {
+		retryAfter = openaiRetry.RetryAfter
+	}
+
+	var (
+		openaiErr    *sdk.APIError
+		openaiReqErr *sdk.RequestError
+		anthropicErr *anthropic.APIError
+		geminiErr    genai.APIError
+		awsErr       smithy.APIError
+	)
+	switch {
+	case errors.As(err, &openaiErr):
+		// A 429 for an exhausted quota does not clear by waiting.
+		if openaiErr.Type == "insufficient_quota" || openaiErr.Code == "insufficient_quota" {
+			return false, 0
+		}
+		return retryableStatus(openaiErr.HTTPStatusCode), retryAfter
+	case errors.As(err, &openaiReqErr):
+		return retryableStatus(openaiReqErr.HTTPStatusCode), retryAfter
+	case errors.As(err, &anthropicErr):
+		if anthropicErr.StatusCode != 0 {
+			return retryableStatus(anthropicErr.StatusCode), anthropicErr.RetryAfter
+		}
+		// Errors sent inside the stream carry only a type.
+		switch anthropicErr.Type {
+		case "overloaded_error", "rate_limit_error", "api_error", "timeout_error":
+			return true, 0
+		}
+		return false, 0
+	case errors.As(err, &geminiErr):
+		switch geminiErr.Status {
+		case "RESOURCE_EXHAUSTED", "UNAVAILABLE", "INTERNAL", "DEADLINE_EXCEEDED":
+			retryable = true
+		}
+		return retryable || retryableStatus(geminiErr.Code), geminiRetryDelay(geminiErr.Details)
+	case errors.As(err, &awsErr):
+		switch awsErr.ErrorCode() {
+		case "ThrottlingException", "TooManyRequestsException", "ServiceUnavailableException",
+			"InternalServerException", "ModelNotReadyException", "ModelTimeoutException":
+			retryable = true
+		}
+		var respErr *awshttp.ResponseError
+		if errors.As(err, &respErr) {
+			retryable = retryable || retryableStatus(respErr.HTTPStatusCode())
+			if respErr.Response != nil && respErr.Response.Response != nil {
+				retryAfter = assistant.ParseRetryAfter(respErr.Response.Header)
+			}
+		}
+		return retryable, retryAfter
+	}
+
+	// Transport failures, which look alike for every provider.
+	if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.ECONNREFUSED) {
+		return true, retryAfter
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true, retryAfter
+	}
+	return false, 0
+}
+
+// retryableStatus reports whether an HTTP status signals a transient failure.
+func retryableStatus(code int) bool {
+	switch {
+	case code == http.StatusRequestTimeout, code == http.StatusConflict, code == http.StatusTooManyRequests:
+		return true
+	case code == http.StatusNotImplemented:
+		return false
+	default:
+		return code >= 500
+	}
+}
+
+// geminiRetryDelay returns the delay from a google.rpc.RetryInfo error
+// detail, or zero.
+func geminiRetryDelay(details []map[string]any) time.Duration {
+	for _, detail := range details {
+		if detail["@type"] != "type.googleapis.com/google.rpc.RetryInfo" {
+			continue
+		}
+		if s, ok := detail["retryDelay"].(string); ok {
+			if d, err := time.ParseDuration(s); err == nil {
+				return d
+			}
+		}
+	}
+	return 0
+}
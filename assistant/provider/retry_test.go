@@ -0,0 +1,319 @@
+package provider_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime/types"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
+	sdk "github.com/sashabaranov/go-openai"
+	"github.com/sburchfield/go-assistant-api/assistant"
+	"github.com/sburchfield/go-assistant-api/assistant/provider"
+	"github.com/sburchfield/go-assistant-api/assistant/provider/anthropic"
+	"github.com/sburchfield/go-assistant-api/assistant/provider/openai"
+	"google.golang.org/genai"
+)
+
+// step is one scripted response: an error returned before streaming, or the
+// events of a stream.
+type step struct {
+	err    error
+	events []assistant.Event
+}
+
+// scriptedProvider answers each call with the next step, repeating the last
+// one once the script runs out.
+type scriptedProvider struct {
+	provider.ChatProvider
+
+	mu    sync.Mutex
+	steps []step
+	calls int
+}
+
+func script(steps ...step) *scriptedProvider {
+	return &scriptedProvider{steps: steps}
+}
+
+func (s *scriptedProvider) next() step {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	st := s.steps[min(s.calls, len(s.steps)-1)]
+	s.calls++
+	return st
+}
+
+func (s *scriptedProvider) Calls() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.calls
+}
+
+func (s *scriptedProvider) ChatStreamEvents(ctx context.Context, messages []assistant.Message, tools []assistant.Tool, toolChoice assistant.ToolChoice, opts ...assistant.GenerationOption) (<-chan assistant.Event, error) {
+	st := s.next()
+	if st.err != nil {
+		return nil, st.err
+	}
+	out := make(chan assistant.Event)
+	go func() {
+		defer close(out)
+		for _, ev := range st.events {
+			if !assistant.SendEvent(ctx, out, ev) {
+				return
+			}
+		}
+	}()
+	return out, nil
+}
+
+func (s *scriptedProvider) Chat(ctx context.Context, messages []assistant.Message, opts assistant.ChatOptions) (*assistant.Response, error) {
+	st := s.next()
+	if st.err != nil {
+		return nil, st.err
+	}
+	events := make(chan assistant.Event, len(st.events))
+	for _, ev := range st.events {
+		events <- ev
+	}
+	close(events)
+	return assistant.Collect(events)
+}
+
+// reply is the event stream of a successful response with the given text.
+func reply(text string) step {
+	return step{events: []assistant.Event{
+		{Type: assistant.EventTextDelta, Text: text},
+		{Type: assistant.EventFinish, FinishReason: assistant.FinishReasonStop},
+		{Type: assistant.EventUsage, Usage: &assistant.UsageMetadata{PromptTokenCount: 1, CandidatesTokenCount: 1, TotalTokenCount: 2}},
+	}}
+}
+
+// streamError is a stream that fails before producing output.
+func streamError(err error) step {
+	return step{events: []assistant.Event{{Type: assistant.EventError, Err: err}}}
+}
+
+var (
+	errRateLimited = &sdk.APIError{HTTPStatusCode: http.StatusTooManyRequests, Message: "Rate limit reached"}
+	errBadRequest  = &sdk.APIError{HTTPStatusCode: http.StatusBadRequest, Message: "Invalid request"}
+)
+
+var hello = []assistant.Message{{Role: assistant.RoleUser, Content: "Hello"}}
+
+// fastRetry keeps test backoffs short and records each retry's delay.
+func fastRetry(delays *[]time.Duration) provider.RetryPolicy {
+	return provider.RetryPolicy{
+		BaseDelay: time.Millisecond,
+		MaxDelay:  50 * time.Millisecond,
+		OnRetry: func(attempt int, err error, delay time.Duration) {
+			*delays = append(*delays, delay)
+		},
+	}
+}
+
+func collect(t *testing.T, p provider.ChatProvider) (*assistant.Response, error) {
+	t.Helper()
+	events, err := p.ChatStreamEvents(context.Background(), hello, nil, "")
+	if err != nil {
+		return nil, err
+	}
+	return assistant.Collect(events)
+}
+
+func TestRetry_StartErrors(t *testing.T) {
+	inner := script(step{err: errRateLimited}, step{err: errRateLimited}, reply("Hi"))
+	var delays []time.Duration
+
+	resp, err := collect(t, provider.Retry(inner, fastRetry(&delays)))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if resp.Message.Content != "Hi" {
+		t.Errorf("content = %q, want Hi", resp.Message.Content)
+	}
+	if inner.Calls() != 3 || len(delays) != 2 {
+		t.Errorf("calls = %d, retries = %d; want 3 and 2", inner.Calls(), len(delays))
+	}
+	for i, d := range delays {
+		if d <= 0 || d > 50*time.Millisecond {
+			t.Errorf("retry %d delay = %v, want within (0, MaxDelay]", i, d)
+		}
+	}
+}
+
+func TestRetry_ErrorBeforeFirstEvent(t *testing.T) {
+	overloaded := fmt.Errorf("stream error: %w", &anthropic.APIError{Type: "overloaded_error", Message: "Overloaded"})
+	inner := script(streamError(overloaded), reply("Hi"))
+	var delays []time.Duration
+
+	resp, err := collect(t, provider.Retry(inner, fastRetry(&delays)))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if resp.Message.Content != "Hi" || inner.Calls() != 2 {
+		t.Errorf("content = %q after %d calls, want Hi after 2", resp.Message.Content, inner.Calls())
+	}
+}
+
+func TestRetry_ErrorAfterOutputIsNotRetried(t *testing.T) {
+	inner := script(step{events: []assistant.Event{
+		{Type: assistant.EventTextDelta, Text: "Partial"},
+		{Type: assistant.EventError, Err: errRateLimited},
+	}}, reply("Hi"))
+	var delays []time.Duration
+
+	resp, err := collect(t, provider.Retry(inner, fastRetry(&delays)))
+	if !errors.Is(err, errRateLimited) {
+		t.Fatalf("err = %v, want the original error", err)
+	}
+	if resp.Message.Content != "Partial" || inner.Calls() != 1 {
+		t.Errorf("content = %q after %d calls, want Partial after 1", resp.Message.Content, inner.Calls())
+	}
+}
+
+func TestRetry_NonRetryableAndExhausted(t *testing.T) {
+	var delays []time.Duration
+
+	inner := script(step{err: errBadRequest}, reply("Hi"))
+	if _, err := collect(t, provider.Retry(inner, fastRetry(&delays))); !errors.Is(err, errBadRequest) || inner.Calls() != 1 {
+		t.Errorf("non-retryable: err = %v after %d calls, want errBadRequest after 1", err, inner.Calls())
+	}
+
+	policy := fastRetry(&delays)
+	policy.MaxAttempts = 4
+	inner = script(streamError(errRateLimited))
+	if _, err := collect(t, provider.Retry(inner, policy)); !errors.Is(err, errRateLimited) || inner.Calls() != 4 {
+		t.Errorf("exhausted: err = %v after %d calls, want errRateLimited after 4", err, inner.Calls())
+	}
+}
+
+func TestRetry_RetryAfter(t *testing.T) {
+	var delays []time.Duration
+	policy := fastRetry(&delays)
+
+	throttled := &openai.RetryAfterError{Err: errRateLimited, RetryAfter: 20 * time.Millisecond}
+	inner := script(step{err: throttled}, reply("Hi"))
+	if _, err := collect(t, provider.Retry(inner, policy)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(delays) != 1 || delays[0] != 20*time.Millisecond {
+		t.Errorf("delays = %v, want the server's 20ms", delays)
+	}
+
+	// A Retry-After beyond MaxDelay is reported rather than waited out.
+	tooLong := &openai.RetryAfterError{Err: errRateLimited, RetryAfter: time.Hour}
+	inner = script(step{err: tooLong}, reply("Hi"))
+	if _, err := collect(t, provider.Retry(inner, policy)); !errors.Is(err, errRateLimited) || inner.Calls() != 1 {
+		t.Errorf("err = %v after %d calls, want errRateLimited after 1", err, inner.Calls())
+	}
+}
+
+func TestRetry_ContextCanceledDuringBackoff(t *testing.T) {
+	inner := script(step{err: errRateLimited}, reply("Hi"))
+	ctx, cancel := context.WithCancel(context.Background())
+
+	p := provider.Retry(inner, provider.RetryPolicy{
+		BaseDelay: time.Hour,
+		MaxDelay:  time.Hour,
+		OnRetry:   func(int, error, time.Duration) { cancel() },
+	})
+
+	start := time.Now()
+	if _, err := p.ChatStreamEvents(ctx, hello, nil, ""); !errors.Is(err, errRateLimited) {
+		t.Errorf("err = %v, want errRateLimited", err)
+	}
+	if time.Since(start) > time.Second {
+		t.Error("backoff did not stop when the context was canceled")
+	}
+}
+
+func TestRetry_Chat(t *testing.T) {
+	inner := script(step{err: errRateLimited}, reply("Hi"))
+	var delays []time.Duration
+
+	resp, err := provider.Retry(inner, fastRetry(&delays)).Chat(context.Background(), hello, assistant.ChatOptions{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if resp.Message.Content != "Hi" || inner.Calls() != 2 {
+		t.Errorf("content = %q after %d calls, want Hi after 2", resp.Message.Content, inner.Calls())
+	}
+}
+
+func TestRetry_ChatStream(t *testing.T) {
+	inner := script(streamError(errRateLimited), reply("Hi"))
+	var delays []time.Duration
+
+	stream, err := provider.Retry(inner, fastRetry(&delays)).ChatStream(context.Background(), hello)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var text strings.Builder
+	for chunk := range stream {
+		text.WriteString(chunk)
+	}
+	if text.String() != "Hi" {
+		t.Errorf("text = %q, want Hi", text.String())
+	}
+}
+
+func TestIsRetryable(t *testing.T) {
+	awsResponse := func(status int, header http.Header) *awshttp.ResponseError {
+		return &awshttp.ResponseError{ResponseError: &smithyhttp.ResponseError{
+			Response: &smithyhttp.Response{Response: &http.Response{StatusCode: status, Header: header}},
+			Err:      &types.ThrottlingException{Message: ptr("Too many requests")},
+		}}
+	}
+
+	tests := []struct {
+		name       string
+		err        error
+		retryable  bool
+		retryAfter time.Duration
+	}{
+		{name: "openai 429", err: errRateLimited, retryable: true},
+		{name: "openai 503", err: &sdk.APIError{HTTPStatusCode: 503}, retryable: true},
+		{name: "openai 400", err: errBadRequest},
+		{name: "openai quota", err: &sdk.APIError{HTTPStatusCode: 429, Type: "insufficient_quota"}},
+		{name: "openai request error", err: &sdk.RequestError{HTTPStatusCode: 502}, retryable: true},
+		{name: "openai retry-after", err: &openai.RetryAfterError{Err: errRateLimited, RetryAfter: 2 * time.Second}, retryable: true, retryAfter: 2 * time.Second},
+		{name: "anthropic 529", err: &anthropic.APIError{StatusCode: 529, Type: "overloaded_error", RetryAfter: time.Second}, retryable: true, retryAfter: time.Second},
+		{name: "anthropic 400", err: &anthropic.APIError{StatusCode: 400, Type: "invalid_request_error"}},
+		{name: "anthropic stream overloaded", err: fmt.Errorf("stream error: %w", &anthropic.APIError{Type: "overloaded_error"}), retryable: true},
+		{name: "anthropic stream invalid", err: &anthropic.APIError{Type: "invalid_request_error"}},
+		{name: "gemini exhausted", err: fmt.Errorf("failed to generate content: %w", genai.APIError{
+			Code:    429,
+			Status:  "RESOURCE_EXHAUSTED",
+			Details: []map[string]any{{"@type": "type.googleapis.com/google.rpc.RetryInfo", "retryDelay": "12s"}},
+		}), retryable: true, retryAfter: 12 * time.Second},
+		{name: "gemini invalid", err: genai.APIError{Code: 400, Status: "INVALID_ARGUMENT"}},
+		{name: "bedrock throttling", err: fmt.Errorf("failed to start converse stream: %w", &types.ThrottlingException{Message: ptr("slow down")}), retryable: true},
+		{name: "bedrock unavailable", err: &types.ServiceUnavailableException{}, retryable: true},
+		{name: "bedrock validation", err: &types.ValidationException{}},
+		{name: "bedrock retry-after", err: awsResponse(429, http.Header{"Retry-After": {"4"}}), retryable: true, retryAfter: 4 * time.Second},
+		{name: "unexpected EOF", err: fmt.Errorf("stream error: %w", io.ErrUnexpectedEOF), retryable: true},
+		{name: "deadline", err: context.DeadlineExceeded, retryable: true},
+		{name: "canceled", err: context.Canceled},
+		{name: "circuit open", err: &provider.CircuitOpenError{Provider: "bedrock", RetryAt: time.Now().Add(time.Second)}},
+		{name: "rate limited locally", err: &provider.RateLimitError{Provider: "openai", RetryAfter: time.Second}},
+		{name: "other", err: errors.New("boom")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			retryable, retryAfter := provider.IsRetryable(tt.err)
+			if retryable != tt.retryable || retryAfter != tt.retryAfter {
+				t.Errorf("IsRetryable() = %v, %v; want %v, %v", retryable, retryAfter, tt.retryable, tt.retryAfter)
+			}
+		})
+	}
+}
+
+func ptr[T any](v T) *T { return &v }
@@ -0,0 +1,333 @@
+package provider
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
+)
+
+// DefaultSecretTTL is how long a cached secret is reused before it is fetched
+// again.
+const DefaultSecretTTL = 5 * time.Minute
+
+// SecretSource supplies a credential such as an API key.
+type SecretSource interface {
+	Secret(ctx context.Context) (string, error)
+}
+
+// SecretFunc adapts a function to a SecretSource.
+type SecretFunc func(ctx context.Context) (string, error)
+
+func (f SecretFunc) Secret(ctx context.Context) (string, error) {
+	return f(ctx)
+}
+
+// EnvSecret reads the secret from the environment variable name.
+func EnvSecret(name string) SecretSource {
+	return SecretFunc(func(context.Context) (string, error) {
+		value := os.Getenv(name)
+		if value == "" {
+			return "", fmt.Errorf("environment variable %s is not set", name)
+		}
+		return value, nil
+	})
+}
+
+// FileSecret reads the secret from the file at path, such as a Kubernetes
+// secret volume, with surrounding whitespace trimmed.
+func FileSecret(path string) SecretSource {
+	return SecretFunc(func(context.Context) (string, error) {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return "", fmt.Errorf("failed to read secret file: %w", err)
+		}
+		value := strings.TrimSpace(string(data))
+		if value == "" {
+			return "", fmt.Errorf("secret file %s is empty", path)
+		}
+		return value, nil
+	})
+}
+
+// SecretsManagerAPI is the subset of the AWS Secrets Manager client used by
+// AWSSecretSource.
+type SecretsManagerAPI interface {
+	GetSecretValue(ctx context.Context, params *secretsmanager.GetSecretValueInput, optFns ...func(*secretsmanager.Options)) (*secretsmanager.GetSecretValueOutput, error)
+}
+
+// AWSSecretSource reads a secret from AWS Secrets Manager.
+type AWSSecretSource struct {
+	SecretID string
+	// Key selects a field when the secret is a JSON object. When empty the
+	// whole secret string is returned.
+	Key string
+	// Client defaults to a client built from the default AWS configuration
+	// on first use.
+	Client SecretsManagerAPI
+
+	mu            sync.Mutex
+	defaultClient SecretsManagerAPI
+}
+
+func (s *AWSSecretSource) Secret(ctx context.Context) (string, error) {
+	client, err := s.client(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	result, err := client.GetSecretValue(ctx, &secretsmanager.GetSecretValueInput{
+		SecretId: &s.SecretID,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to get secret: %w", err)
+	}
+	if result.SecretString == nil {
+		return "", fmt.Errorf("secret is not a string")
+	}
+	if s.Key == "" {
+		return *result.SecretString, nil
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(*result.SecretString), &fields); err != nil {
+		return "", fmt.Errorf("secret %s is not a JSON object: %w", s.SecretID, err)
+	}
+	return secretField(fields, s.Key)
+}
+
+// client returns s.Client, or the default client, building it once. A failed
+// build is tried again on the next call.
+func (s *AWSSecretSource) client(ctx context.Context) (SecretsManagerAPI, error) {
+	if s.Client != nil {
+		return s.Client, nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.defaultClient == nil {
+		cfg, err := config.LoadDefaultConfig(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("unable to load SDK config: %w", err)
+		}
+		s.defaultClient = secretsmanager.NewFromConfig(cfg)
+	}
+	return s.defaultClient, nil
+}
+
+// VaultSecretSource reads a field of a HashiCorp Vault secret over the HTTP
+// API. Both KV version 1 and version 2 engines are supported.
+type VaultSecretSource struct {
+	// Path is the API path below /v1/, e.g. "secret/data/llm" for a KV v2
+	// mount named "secret".
+	Path string
+	Key  string
+	// Address, Token and Namespace default to VAULT_ADDR, VAULT_TOKEN and
+	// VAULT_NAMESPACE.
+	Address   string
+	Token     string
+	Namespace string
+	// HTTPClient defaults to http.DefaultClient.
+	HTTPClient *http.Client
+}
+
+func (s *VaultSecretSource) Secret(ctx context.Context) (string, error) {
+	address := firstNonEmpty(s.Address, os.Getenv("VAULT_ADDR"))
+	if address == "" {
+		return "", fmt.Errorf("vault address is not set (VAULT_ADDR)")
+	}
+	endpoint, err := url.JoinPath(address, "v1", s.Path)
+	if err != nil {
+		return "", fmt.Errorf("invalid vault address: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return "", err
+	}
+	if token := firstNonEmpty(s.Token, os.Getenv("VAULT_TOKEN")); token != "" {
+		req.Header.Set("X-Vault-Token", token)
+	}
+	if namespace := firstNonEmpty(s.Namespace, os.Getenv("VAULT_NAMESPACE")); namespace != "" {
+		req.Header.Set("X-Vault-Namespace", namespace)
+	}
+
+	httpClient := s.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to read vault secret: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("failed to read vault secret: %w", err)
+	}
+	if resp.StatusCode/100 != 2 {
+		var errBody struct {
+			Errors []string `json:"errors"`
+		}
+		if json.Unmarshal(body, &errBody) == nil && len(errBody.Errors) > 0 {
+			return "", fmt.Errorf("vault %s: %s", resp.Status, strings.Join(errBody.Errors, "; "))
+		}
+		return "", fmt.Errorf("vault %s", resp.Status)
+	}
+
+	var secret struct {
+		Data map[string]json.RawMessage `json:"data"`
+	}
+	if err := json.Unmarshal(body, &secret); err != nil {
+		return "", fmt.Errorf("invalid vault response: %w", err)
+	}
+	fields := secret.Data
+	// KV v2 nests the fields under data.data, next to data.metadata.
+	if nested, ok := fields["data"]; ok && fields["metadata"] != nil {
+		fields = nil
+		if err := json.Unmarshal(nested, &fields); err != nil {
+			return "", fmt.Errorf("invalid vault response: %w", err)
+		}
+	}
+	return secretField(fields, s.Key)
+}
+
+// secretField returns fields[key], unquoted when it is a JSON string and as
+// raw JSON otherwise (e.g. a service account object).
+func secretField(fields map[string]json.RawMessage, key string) (string, error) {
+	raw, ok := fields[key]
+	if !ok {
+		return "", fmt.Errorf("secret has no key %q", key)
+	}
+	var value string
+	if err := json.Unmarshal(raw, &value); err == nil {
+		return value, nil
+	}
+	return string(bytes.TrimSpace(raw)), nil
+}
+
+// ParseSecretSource parses a secret reference:
+//
+//	env:OPENAI_API_KEY
+//	file:/var/run/secrets/openai/api-key
+//	aws:prod/llm[#json_key]
+//	vault:secret/data/llm#openai_api_key
+func ParseSecretSource(ref string) (SecretSource, error) {
+	scheme, rest, ok := strings.Cut(ref, ":")
+	if !ok || rest == "" {
+		return nil, fmt.Errorf("invalid secret reference %q (want env:, file:, aws: or vault:)", ref)
+	}
+	switch scheme {
+	case "env":
+		return EnvSecret(rest), nil
+	case "file":
+		return FileSecret(rest), nil
+	case "aws":
+		id, key, _ := strings.Cut(rest, "#")
+		return &AWSSecretSource{SecretID: id, Key: key}, nil
+	case "vault":
+		path, key, _ := strings.Cut(rest, "#")
+		if key == "" {
+			return nil, fmt.Errorf("invalid secret reference %q: vault secrets need a #key", ref)
+		}
+		return &VaultSecretSource{Path: path, Key: key}, nil
+	default:
+		return nil, fmt.Errorf("unknown secret source %q in %q", scheme, ref)
+	}
+}
+
+// CachedSecret wraps src so it is fetched at most once per ttl; a ttl of zero
+// or less selects DefaultSecretTTL. Once the ttl has passed the next caller
+// fetches the secret again, so rotated credentials are picked up; other
+// callers keep getting the previous value meanwhile instead of waiting. If
+// the fetch fails the previous value is kept, and the source is not asked
+// again for the ttl or a minute, whichever is shorter.
+func CachedSecret(src SecretSource, ttl time.Duration) SecretSource {
+	if ttl <= 0 {
+		ttl = DefaultSecretTTL
+	}
+	return &cachedSecret{src: src, ttl: ttl}
+}
+
+// secretRetryDelay bounds how long CachedSecret waits after a failed fetch.
+const secretRetryDelay = time.Minute
+
+type cachedSecret struct {
+	src SecretSource
+	ttl time.Duration
+
+	mu      sync.Mutex
+	value   string
+	err     error // of the last fetch
+	expires time.Time
+	// fetching is closed when the fetch in flight, if any, completes.
+	fetching chan struct{}
+}
+
+func (c *cachedSecret) Secret(ctx context.Context) (string, error) {
+	c.mu.Lock()
+	for {
+		if time.Now().Before(c.expires) || (c.fetching != nil && c.value != "") {
+			value, err := c.value, c.err
+			c.mu.Unlock()
+			if value == "" {
+				return "", err
+			}
+			return value, nil
+		}
+		if c.fetching == nil {
+			break
+		}
+		// Nothing to serve yet: wait for the fetch in flight.
+		fetching := c.fetching
+		c.mu.Unlock()
+		select {
+		case <-fetching:
+		case <-ctx.Done():
+			return "", ctx.Err()
+		}
+		c.mu.Lock()
+	}
+	fetching := make(chan struct{})
+	c.fetching = fetching
+	c.mu.Unlock()
+
+	value, err := c.src.Secret(ctx)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.fetching = nil
+	close(fetching)
+	switch {
+	case err == nil:
+		c.value, c.err, c.expires = value, nil, time.Now().Add(c.ttl)
+		return value, nil
+	case ctx.Err() == nil:
+		// Back off rather than asking a failing store on every call. A
+		// fetch cut short by its caller's context is retried at once.
+		c.err, c.expires = err, time.Now().Add(min(c.ttl, secretRetryDelay))
+	}
+	if c.value != "" {
+		return c.value, nil
+	}
+	return "", err
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
@@ -0,0 +1,302 @@
+package provider_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
+	"github.com/sburchfield/go-assistant-api/assistant/provider"
+)
+
+func TestEnvSecret(t *testing.T) {
+	t.Setenv("SECRETS_TEST_KEY", "sk-env")
+
+	if got, err := provider.EnvSecret("SECRETS_TEST_KEY").Secret(context.Background()); err != nil || got != "sk-env" {
+		t.Errorf("Secret() = %q, %v; want sk-env", got, err)
+	}
+	if _, err := provider.EnvSecret("SECRETS_TEST_UNSET").Secret(context.Background()); err == nil {
+		t.Error("expected error for unset variable")
+	}
+}
+
+func TestFileSecret(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "api-key")
+	if err := os.WriteFile(path, []byte("sk-file\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	if got, err := provider.FileSecret(path).Secret(context.Background()); err != nil || got != "sk-file" {
+		t.Errorf("Secret() = %q, %v; want sk-file", got, err)
+	}
+	if _, err := provider.FileSecret(path + ".missing").Secret(context.Background()); err == nil {
+		t.Error("expected error for missing file")
+	}
+}
+
+// fakeSecretsManager serves a single secret string.
+type fakeSecretsManager struct {
+	secret string
+	ids    []string
+}
+
+func (f *fakeSecretsManager) GetSecretValue(ctx context.Context, in *secretsmanager.GetSecretValueInput, _ ...func(*secretsmanager.Options)) (*secretsmanager.GetSecretValueOutput, error) {
+	f.ids = append(f.ids, *in.SecretId)
+	return &secretsmanager.GetSecretValueOutput{SecretString: &f.secret}, nil
+}
+
+func TestAWSSecretSource(t *testing.T) {
+	client := &fakeSecretsManager{secret: `{"openai_api_key": "sk-aws", "gcp": {"type": "service_account"}}`}
+
+	tests := []struct {
+		key     string
+		want    string
+		wantErr bool
+	}{
+		{key: "", want: client.secret},
+		{key: "openai_api_key", want: "sk-aws"},
+		{key: "gcp", want: `{"type": "service_account"}`},
+		{key: "missing", wantErr: true},
+	}
+	for _, tt := range tests {
+		src := &provider.AWSSecretSource{SecretID: "prod/llm", Key: tt.key, Client: client}
+		got, err := src.Secret(context.Background())
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("key %q: Secret() = %q, %v; want %q (error %v)", tt.key, got, err, tt.want, tt.wantErr)
+		}
+	}
+	if client.ids[0] != "prod/llm" {
+		t.Errorf("SecretId = %q, want prod/llm", client.ids[0])
+	}
+}
+
+func TestVaultSecretSource(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Vault-Token") != "vault-token" || r.Header.Get("X-Vault-Namespace") != "team" {
+			w.WriteHeader(http.StatusForbidden)
+			_, _ = w.Write([]byte(`{"errors":["permission denied"]}`))
+			return
+		}
+		switch r.URL.Path {
+		case "/v1/secret/data/llm":
+			_, _ = w.Write([]byte(`{"data":{"data":{"openai_api_key":"sk-kv2"},"metadata":{"version":3}}}`))
+		case "/v1/kv/llm":
+			_, _ = w.Write([]byte(`{"data":{"openai_api_key":"sk-kv1"}}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"errors":[]}`))
+		}
+	}))
+	defer server.Close()
+
+	t.Setenv("VAULT_ADDR", server.URL)
+	t.Setenv("VAULT_TOKEN", "vault-token")
+	t.Setenv("VAULT_NAMESPACE", "team")
+
+	for path, want := range map[string]string{"secret/data/llm": "sk-kv2", "kv/llm": "sk-kv1"} {
+		src := &provider.VaultSecretSource{Path: path, Key: "openai_api_key"}
+		if got, err := src.Secret(context.Background()); err != nil || got != want {
+			t.Errorf("%s: Secret() = %q, %v; want %q", path, got, err, want)
+		}
+	}
+
+	src := &provider.VaultSecretSource{Path: "secret/data/llm", Key: "openai_api_key", Token: "wrong"}
+	if _, err := src.Secret(context.Background()); err == nil || !strings.Contains(err.Error(), "permission denied") {
+		t.Errorf("expected vault error to be reported, got %v", err)
+	}
+	src = &provider.VaultSecretSource{Path: "secret/data/missing", Key: "openai_api_key"}
+	if _, err := src.Secret(context.Background()); err == nil || !strings.Contains(err.Error(), "404") {
+		t.Errorf("expected 404 error, got %v", err)
+	}
+}
+
+func TestParseSecretSource(t *testing.T) {
+	t.Setenv("SECRETS_TEST_KEY", "sk-env")
+
+	src, err := provider.ParseSecretSource("env:SECRETS_TEST_KEY")
+	if err != nil {
+		t.Fatalf("ParseSecretSource: %v", err)
+	}
+	if got, _ := src.Secret(context.Background()); got != "sk-env" {
+		t.Errorf("env secret = %q, want sk-env", got)
+	}
+
+	src, err = provider.ParseSecretSource("aws:prod/llm#openai_api_key")
+	if err != nil {
+		t.Fatalf("ParseSecretSource: %v", err)
+	}
+	if aws, ok := src.(*provider.AWSSecretSource); !ok || aws.SecretID != "prod/llm" || aws.Key != "openai_api_key" {
+		t.Errorf("aws source = %#v", src)
+	}
+
+	src, err = provider.ParseSecretSource("vault:secret/data/llm#openai_api_key")
+	if err != nil {
+		t.Fatalf("ParseSecretSource: %v", err)
+	}
+	if vault, ok := src.(*provider.VaultSecretSource); !ok || vault.Path != "secret/data/llm" || vault.Key != "openai_api_key" {
+		t.Errorf("vault source = %#v", src)
+	}
+
+	for _, ref := range []string{"", "sk-plain", "env:", "vault:secret/data/llm", "gcp:projects/x"} {
+		if _, err := provider.ParseSecretSource(ref); err == nil {
+			t.Errorf("ParseSecretSource(%q): expected error", ref)
+		}
+	}
+}
+
+func TestCachedSecret(t *testing.T) {
+	calls := 0
+	var fail bool
+	src := provider.CachedSecret(provider.SecretFunc(func(context.Context) (string, error) {
+		calls++
+		if fail {
+			return "", errors.New("store unavailable")
+		}
+		return "key-" + string(rune('0'+calls)), nil
+	}), 20*time.Millisecond)
+
+	ctx := context.Background()
+	for range 3 {
+		if got, err := src.Secret(ctx); err != nil || got != "key-1" {
+			t.Fatalf("Secret() = %q, %v; want cached key-1", got, err)
+		}
+	}
+	if calls != 1 {
+		t.Errorf("source called %d times within the TTL, want 1", calls)
+	}
+
+	// After the TTL the rotated key is picked up.
+	time.Sleep(30 * time.Millisecond)
+	if got, err := src.Secret(ctx); err != nil || got != "key-2" {
+		t.Errorf("Secret() after TTL = %q, %v; want key-2", got, err)
+	}
+
+	// A failed refresh keeps serving the last good value, and the store is
+	// not asked again until the backoff has passed.
+	fail = true
+	time.Sleep(30 * time.Millisecond)
+	for range 3 {
+		if got, err := src.Secret(ctx); err != nil || got != "key-2" {
+			t.Errorf("Secret() with failing store = %q, %v; want stale key-2", got, err)
+		}
+	}
+	if calls != 3 {
+		t.Errorf("source called %d times, want 3", calls)
+	}
+}
+
+func TestCachedSecret_ConcurrentRefresh(t *testing.T) {
+	var calls atomic.Int32
+	release := make(chan struct{})
+	src := provider.CachedSecret(provider.SecretFunc(func(context.Context) (string, error) {
+		n := calls.Add(1)
+		if n > 1 {
+			<-release
+		}
+		return fmt.Sprint("key-", n), nil
+	}), 20*time.Millisecond)
+
+	ctx := context.Background()
+	if got, err := src.Secret(ctx); err != nil || got != "key-1" {
+		t.Fatalf("Secret() = %q, %v; want key-1", got, err)
+	}
+	time.Sleep(30 * time.Millisecond)
+
+	// One caller refreshes while the others are served the stale key.
+	refreshed := make(chan string)
+	go func() {
+		got, _ := src.Secret(ctx)
+		refreshed <- got
+	}()
+	for calls.Load() < 2 {
+		time.Sleep(time.Millisecond)
+	}
+	for range 5 {
+		if got, err := src.Secret(ctx); err != nil || got != "key-1" {
+			t.Errorf("Secret() during refresh = %q, %v; want stale key-1", got, err)
+		}
+	}
+	close(release)
+	if got := <-refreshed; got != "key-2" {
+		t.Errorf("refreshed key = %q, want key-2", got)
+	}
+	if got, _ := src.Secret(ctx); got != "key-2" || calls.Load() != 2 {
+		t.Errorf("Secret() = %q after %d fetches, want key-2 after 2", got, calls.Load())
+	}
+}
+
+func TestCachedSecret_FirstFetchIsShared(t *testing.T) {
+	var calls atomic.Int32
+	release := make(chan struct{})
+	src := provider.CachedSecret(provider.SecretFunc(func(context.Context) (string, error) {
+		calls.Add(1)
+		<-release
+		return "", errors.New("store unavailable")
+	}), time.Minute)
+
+	errs := make(chan error)
+	for range 3 {
+		go func() {
+			_, err := src.Secret(context.Background())
+			errs <- err
+		}()
+	}
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+	for range 3 {
+		if err := <-errs; err == nil {
+			t.Error("expected the fetch error")
+		}
+	}
+	if calls.Load() != 1 {
+		t.Errorf("source called %d times, want 1", calls.Load())
+	}
+}
+
+func TestNewProviderFromEnv_APIKeySecret(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "api-key")
+	if err := os.WriteFile(path, []byte("sk-mounted"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("LLM_PROVIDER", "test-gateway")
+	t.Setenv("TEST_GATEWAY_API_KEY_SECRET", "file:"+path)
+	t.Setenv("SECRET_TTL", "")
+
+	p, err := provider.NewProviderFromEnv()
+	if err != nil {
+		t.Fatalf("NewProviderFromEnv: %v", err)
+	}
+	cfg := p.(*fakeProvider).cfg
+	if cfg.APIKeySource == nil {
+		t.Fatal("expected APIKeySource to be set")
+	}
+	if got, err := cfg.APIKeySource.Secret(context.Background()); err != nil || got != "sk-mounted" {
+		t.Errorf("Secret() = %q, %v; want sk-mounted", got, err)
+	}
+	if _, ok := cfg.Options["api_key_secret"]; ok {
+		t.Errorf("Options should not repeat the secret reference: %v", cfg.Options)
+	}
+
+	if cfg.SecretTTL != 0 {
+		t.Errorf("SecretTTL = %v, want 0 for the default", cfg.SecretTTL)
+	}
+
+	t.Setenv("SECRET_TTL", "90s")
+	if cfg, err := provider.ConfigFromEnv("test-gateway"); err != nil || cfg.SecretTTL != 90*time.Second {
+		t.Errorf("SecretTTL = %v, %v; want 90s", cfg.SecretTTL, err)
+	}
+
+	t.Setenv("SECRET_TTL", "")
+	t.Setenv("TEST_GATEWAY_API_KEY_SECRET", "nope:x")
+	if _, err := provider.NewProviderFromEnv(); err == nil {
+		t.Error("expected error for an invalid secret reference")
+	}
+}
@@ -0,0 +1,41 @@
+package provider
+
+import (
+	"context"
+
+	"github.com/sburchfield/go-assistant-api/assistant"
+)
+
+// eventsFunc has the signature of ChatProvider.ChatStreamEvents.
+type eventsFunc func(ctx context.Context, messages []assistant.Message, tools []assistant.Tool, toolChoice assistant.ToolChoice, opts ...assistant.GenerationOption) (<-chan assistant.Event, error)
+
+// streamMethods implements the text and StreamResult methods of ChatProvider
+// on top of an event stream, the same way the built-in clients do, so a
+// wrapper only has to implement ChatStreamEvents and Chat.
+type streamMethods struct {
+	events eventsFunc
+}
+
+func (s streamMethods) ChatStream(ctx context.Context, messages []assistant.Message) (<-chan string, error) {
+	return s.ChatStreamWithTools(ctx, messages, nil, "")
+}
+
+func (s streamMethods) ChatStreamWithTools(ctx context.Context, messages []assistant.Message, tools []assistant.Tool, toolChoice assistant.ToolChoice, opts ...assistant.GenerationOption) (<-chan string, error) {
+	events, err := s.events(ctx, messages, tools, toolChoice, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return assistant.TextWithToolCalls(ctx, events), nil
+}
+
+func (s streamMethods) ChatStreamWithUsage(ctx context.Context, messages []assistant.Message) (*assistant.StreamResult, error) {
+	return s.ChatStreamWithToolsAndUsage(ctx, messages, nil, "")
+}
+
+func (s streamMethods) ChatStreamWithToolsAndUsage(ctx context.Context, messages []assistant.Message, tools []assistant.Tool, toolChoice assistant.ToolChoice, opts ...assistant.GenerationOption) (*assistant.StreamResult, error) {
+	events, err := s.events(ctx, messages, tools, toolChoice, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return assistant.NewStreamResult(ctx, events), nil
+}
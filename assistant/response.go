@@ -0,0 +1,47 @@
+package assistant
+
+// ChatOptions configures a single non-streaming chat call.
+type ChatOptions struct {
+	Tools      []Tool
+	ToolChoice ToolChoice
+	GenerationOptions
+}
+
+// Response is the complete result of a chat call.
+type Response struct {
+	// Message is the assistant turn, ready to be appended to the conversation history.
+	Message Message `json:"message"`
+	// ToolCalls holds the tool calls the model requested, if any. Same as Message.ToolCalls.
+	ToolCalls    []ToolCall     `json:"tool_calls,omitempty"`
+	FinishReason FinishReason   `json:"finish_reason"`
+	Usage        *UsageMetadata `json:"usage,omitempty"`
+	// Metadata is set by wrappers that choose between backends or cache responses.
+	Metadata *StreamMetadata `json:"metadata,omitempty"`
+}
+
+// Collect drains an event stream into a Response. If the stream ends with an
+// error event, that error is returned together with the partial response.
+func Collect(events <-chan Event) (*Response, error) {
+	acc := NewMessageAccumulator()
+	resp := &Response{FinishReason: FinishReasonStop}
+	var err error
+
+	for ev := range events {
+		acc.Add(ev)
+		switch ev.Type {
+		case EventUsage:
+			resp.Usage = ev.Usage
+		case EventFinish:
+			resp.FinishReason = ev.FinishReason
+		case EventMetadata:
+			resp.Metadata = ev.Metadata
+		case EventError:
+			err = ev.Err
+			resp.FinishReason = FinishReasonError
+		}
+	}
+
+	resp.Message = acc.Message()
+	resp.ToolCalls = resp.Message.ToolCalls
+	return resp, err
+}
@@ -0,0 +1,35 @@
+package assistant_test
+
+import (
+	"testing"
+
+	"github.com/sburchfield/go-assistant-api/assistant"
+)
+
+func TestCollect(t *testing.T) {
+	events := make(chan assistant.Event, 6)
+	events <- assistant.Event{Type: assistant.EventMetadata, Metadata: &assistant.StreamMetadata{Provider: "backup"}}
+	events <- assistant.Event{Type: assistant.EventTextDelta, Text: "Hi"}
+	events <- assistant.Event{Type: assistant.EventToolCallStart, ToolCall: &assistant.ToolCallDelta{ID: "call_1", Name: "lookup"}}
+	events <- assistant.Event{Type: assistant.EventToolCallEnd, ToolCall: &assistant.ToolCallDelta{ID: "call_1"}}
+	events <- assistant.Event{Type: assistant.EventFinish, FinishReason: assistant.FinishReasonToolCalls}
+	events <- assistant.Event{Type: assistant.EventUsage, Usage: &assistant.UsageMetadata{TotalTokenCount: 3}}
+	close(events)
+
+	resp, err := assistant.Collect(events)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if resp.Message.Content != "Hi" || len(resp.ToolCalls) != 1 {
+		t.Errorf("unexpected response: %+v", resp)
+	}
+	if resp.FinishReason != assistant.FinishReasonToolCalls {
+		t.Errorf("expected finish reason 'tool-calls', got '%s'", resp.FinishReason)
+	}
+	if resp.Usage == nil || resp.Usage.TotalTokenCount != 3 {
+		t.Errorf("unexpected usage metadata: %+v", resp.Usage)
+	}
+	if resp.Metadata == nil || resp.Metadata.Provider != "backup" {
+		t.Errorf("unexpected metadata: %+v", resp.Metadata)
+	}
+}
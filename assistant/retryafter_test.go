@@ -0,0 +1,36 @@
+package assistant_test
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/sburchfield/go-assistant-api/assistant"
+)
+
+func TestParseRetryAfter(t *testing.T) {
+	tests := []struct {
+		name   string
+		header http.Header
+		want   time.Duration
+	}{
+		{name: "none", header: http.Header{}, want: 0},
+		{name: "seconds", header: http.Header{"Retry-After": {"2"}}, want: 2 * time.Second},
+		{name: "milliseconds win", header: http.Header{"Retry-After": {"2"}, "Retry-After-Ms": {"150"}}, want: 150 * time.Millisecond},
+		{name: "zero", header: http.Header{"Retry-After": {"0"}}, want: 0},
+		{name: "garbage", header: http.Header{"Retry-After": {"soon"}}, want: 0},
+		{name: "past date", header: http.Header{"Retry-After": {"Wed, 21 Oct 2015 07:28:00 GMT"}}, want: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := assistant.ParseRetryAfter(tt.header); got != tt.want {
+				t.Errorf("ParseRetryAfter() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	future := http.Header{"Retry-After": {time.Now().Add(time.Minute).UTC().Format(http.TimeFormat)}}
+	if got := assistant.ParseRetryAfter(future); got <= 50*time.Second || got > time.Minute {
+		t.Errorf("ParseRetryAfter(date) = %v, want about a minute", got)
+	}
+}
@@ -5,20 +5,177 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"sync"
 	"time"
 
 	"github.com/rs/xid"
 )
 
-// StreamResult contains both the text channel and a way to get usage metadata after streaming completes
+// StreamResult contains both the text channel and a way to get usage metadata, the finish reason
+// and any error after streaming completes
 type StreamResult struct {
 	TextChannel <-chan string
 	// GetUsage returns the usage metadata. Must be called after TextChannel is closed.
 	// Returns nil if usage data is not available.
 	GetUsage func() *UsageMetadata
+	// Err returns the error that ended the stream early. Must be called after TextChannel is closed.
+	// Returns nil if the stream completed normally.
+	Err func() error
+	// GetFinishReason returns why generation stopped. Must be called after TextChannel is closed.
+	// Returns FinishReasonError if the stream failed and FinishReasonStop if the provider did not say.
+	GetFinishReason func() FinishReason
+	// GetMetadata returns the metadata added by wrappers, if any. Must be called after TextChannel is closed.
+	GetMetadata func() *StreamMetadata
+}
+
+// NewStreamResult adapts an event stream to a StreamResult. Text deltas are
+// forwarded to TextChannel, while usage, finish, error and metadata events are
+// recorded for GetUsage, GetFinishReason, Err and GetMetadata. Tool call
+// events are not representable as text and are dropped, so callers that need
+// them should consume the event stream directly. If ctx is done before the
+// stream ends, TextChannel is closed and Err reports the context error.
+func NewStreamResult(ctx context.Context, events <-chan Event) *StreamResult {
+	out := make(chan string)
+	var usageMetadata *UsageMetadata
+	var streamErr error
+	var finishReason FinishReason
+	var metadata *StreamMetadata
+	var usageMu sync.Mutex
+
+	go func() {
+		defer close(out)
+		finished := false
+		for ev := range events {
+			switch ev.Type {
+			case EventTextDelta:
+				select {
+				case out <- ev.Text:
+				case <-ctx.Done():
+					usageMu.Lock()
+					streamErr = ctx.Err()
+					usageMu.Unlock()
+					return
+				}
+			case EventUsage:
+				usageMu.Lock()
+				usageMetadata = ev.Usage
+				usageMu.Unlock()
+			case EventFinish:
+				finished = true
+				usageMu.Lock()
+				finishReason = ev.FinishReason
+				usageMu.Unlock()
+			case EventError:
+				usageMu.Lock()
+				streamErr = ev.Err
+				usageMu.Unlock()
+			case EventMetadata:
+				usageMu.Lock()
+				metadata = ev.Metadata
+				usageMu.Unlock()
+			}
+		}
+		// Providers end the stream without an error event when ctx is done.
+		usageMu.Lock()
+		if !finished && streamErr == nil && ctx.Err() != nil {
+			streamErr = ctx.Err()
+		}
+		usageMu.Unlock()
+	}()
+
+	return &StreamResult{
+		TextChannel: out,
+		GetUsage: func() *UsageMetadata {
+			usageMu.Lock()
+			defer usageMu.Unlock()
+			return usageMetadata
+		},
+		Err: func() error {
+			usageMu.Lock()
+			defer usageMu.Unlock()
+			return streamErr
+		},
+		GetFinishReason: func() FinishReason {
+			usageMu.Lock()
+			defer usageMu.Unlock()
+			switch {
+			case streamErr != nil:
+				return FinishReasonError
+			case finishReason == "":
+				return FinishReasonStop
+			default:
+				return finishReason
+			}
+		},
+		GetMetadata: func() *StreamMetadata {
+			usageMu.Lock()
+			defer usageMu.Unlock()
+			return metadata
+		},
+	}
+}
+
+// TextWithToolCalls streams the text of events like NewStreamResult, and also
+// reports each tool call start and argument fragment as a JSON chunk, as
+// ChatStreamWithTools always has:
+//
+//	{"type":"tool_call","id":"call_1","function":{"name":"get_weather","arguments":""}}
+//	{"type":"tool_call","id":"","function":{"name":"","arguments":"{\"location\":"}}
+//
+// New code should read tool calls from the event stream instead.
+func TextWithToolCalls(ctx context.Context, events <-chan Event) <-chan string {
+	converted := make(chan Event)
+	go func() {
+		defer close(converted)
+		for ev := range events {
+			if (ev.Type == EventToolCallStart || ev.Type == EventToolCallDelta) && ev.ToolCall != nil {
+				// Only the first chunk of a call names it; providers repeat the
+				// ID on every delta, but fragments have always had none.
+				id, name := ev.ToolCall.ID, ev.ToolCall.Name
+				if ev.Type == EventToolCallDelta {
+					id, name = "", ""
+				}
+				chunk, _ := json.Marshal(map[string]interface{}{
+					"type": "tool_call",
+					"id":   id,
+					"function": map[string]string{
+						"name":      name,
+						"arguments": ev.ToolCall.Arguments,
+					},
+				})
+				ev = Event{Type: EventTextDelta, Text: string(chunk)}
+			}
+			if !SendEvent(ctx, converted, ev) {
+				return
+			}
+		}
+	}()
+	return NewStreamResult(ctx, converted).TextChannel
+}
+
+// finishFrame is the payload of the closing "d:" and "e:" parts.
+type finishFrame struct {
+	FinishReason FinishReason `json:"finishReason"`
+	Usage        struct {
+		PromptTokens     int32 `json:"promptTokens"`
+		CompletionTokens int32 `json:"completionTokens"`
+	} `json:"usage"`
+	IsContinued *bool `json:"isContinued,omitempty"`
 }
 
 func ToSSE(ctx context.Context, w http.ResponseWriter, stream <-chan string) {
+	writeSSE(ctx, w, stream, nil)
+}
+
+// ToSSEResult writes a StreamResult as SSE. The closing frames report the
+// actual finish reason and token usage, and if the stream ended with an error
+// an error part is sent first so the client can tell a failed generation from
+// an empty one.
+func ToSSEResult(ctx context.Context, w http.ResponseWriter, result *StreamResult) {
+	writeSSE(ctx, w, result.TextChannel, result)
+}
+
+func writeSSE(ctx context.Context, w http.ResponseWriter, stream <-chan string, result *StreamResult) {
 	w.Header().Set("Content-Type", "text/event-stream")
 	w.Header().Set("Cache-Control", "no-cache")
 	w.Header().Set("Connection", "keep-alive")
@@ -42,10 +199,31 @@ func ToSSE(ctx context.Context, w http.ResponseWriter, stream <-chan string) {
 		select {
 		case msg, ok := <-stream:
 			if !ok {
+				finish := finishFrame{FinishReason: FinishReasonStop}
+				if result != nil {
+					if result.Err != nil {
+						if err := result.Err(); err != nil {
+							escaped, _ := json.Marshal(err.Error())
+							fmt.Fprintf(w, "3:%s\n\n", escaped)
+						}
+					}
+					if result.GetFinishReason != nil {
+						finish.FinishReason = result.GetFinishReason()
+					}
+					if result.GetUsage != nil {
+						if usage := result.GetUsage(); usage != nil {
+							finish.Usage.PromptTokens = usage.PromptTokenCount
+							finish.Usage.CompletionTokens = usage.CandidatesTokenCount
+						}
+					}
+				}
+
 				// Emit final usage and finishReason metadata
-				d := `d:{"finishReason":"stop","usage":{"promptTokens":0,"completionTokens":0}}`
-				e := `e:{"finishReason":"stop","usage":{"promptTokens":0,"completionTokens":0},"isContinued":false}`
-				fmt.Fprintf(w, "%s\n\n%s\n\n", d, e)
+				d, _ := json.Marshal(finish)
+				finish.IsContinued = new(bool)
+				e, _ := json.Marshal(finish)
+				fmt.Fprintf(w, "d:%s\n\n", d)
+				fmt.Fprintf(w, "e:%s\n\n", e)
 				flusher.Flush()
 				return
 			}
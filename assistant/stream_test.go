@@ -3,6 +3,7 @@ package assistant_test
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"net/http/httptest"
 	"strings"
 	"testing"
@@ -75,3 +76,152 @@ func TestToSSE(t *testing.T) {
 		}
 	}
 }
+
+func TestNewStreamResult(t *testing.T) {
+	events := make(chan assistant.Event, 5)
+	events <- assistant.Event{Type: assistant.EventMetadata, Metadata: &assistant.StreamMetadata{Provider: "backup"}}
+	events <- assistant.Event{Type: assistant.EventTextDelta, Text: "Hello"}
+	events <- assistant.Event{Type: assistant.EventToolCallStart, ToolCall: &assistant.ToolCallDelta{ID: "call_1", Name: "lookup"}}
+	events <- assistant.Event{Type: assistant.EventTextDelta, Text: " world"}
+	events <- assistant.Event{Type: assistant.EventUsage, Usage: &assistant.UsageMetadata{TotalTokenCount: 7}}
+	close(events)
+
+	result := assistant.NewStreamResult(context.TODO(), events)
+
+	var text string
+	for msg := range result.TextChannel {
+		text += msg
+	}
+
+	if text != "Hello world" {
+		t.Errorf("expected 'Hello world', got '%s'", text)
+	}
+
+	usage := result.GetUsage()
+	if usage == nil || usage.TotalTokenCount != 7 {
+		t.Errorf("expected usage with 7 total tokens, got %+v", usage)
+	}
+
+	if err := result.Err(); err != nil {
+		t.Errorf("expected no error, got %v", err)
+	}
+
+	if md := result.GetMetadata(); md == nil || md.Provider != "backup" {
+		t.Errorf("expected metadata naming provider 'backup', got %+v", md)
+	}
+}
+
+func TestNewStreamResult_Cancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	events := make(chan assistant.Event)
+	// Like the providers, stop after some text and close without an error
+	// once ctx is done.
+	go func() {
+		defer close(events)
+		if assistant.SendEvent(ctx, events, assistant.Event{Type: assistant.EventTextDelta, Text: "Hel"}) {
+			<-ctx.Done()
+		}
+	}()
+
+	result := assistant.NewStreamResult(ctx, events)
+	<-result.TextChannel
+	cancel()
+	for range result.TextChannel {
+	}
+
+	if err := result.Err(); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+	if reason := result.GetFinishReason(); reason != assistant.FinishReasonError {
+		t.Errorf("expected finish reason 'error', got '%s'", reason)
+	}
+}
+
+func TestTextWithToolCalls(t *testing.T) {
+	events := make(chan assistant.Event, 5)
+	events <- assistant.Event{Type: assistant.EventTextDelta, Text: "Let me check."}
+	// Deltas carry the index and ID of their call, as the providers send them.
+	events <- assistant.Event{Type: assistant.EventToolCallStart, ToolCall: &assistant.ToolCallDelta{Index: 0, ID: "call_1", Name: "lookup"}}
+	events <- assistant.Event{Type: assistant.EventToolCallDelta, ToolCall: &assistant.ToolCallDelta{Index: 0, ID: "call_1", Arguments: `{"q":"go"}`}}
+	events <- assistant.Event{Type: assistant.EventToolCallEnd, ToolCall: &assistant.ToolCallDelta{Index: 0, ID: "call_1"}}
+	events <- assistant.Event{Type: assistant.EventFinish, FinishReason: assistant.FinishReasonToolCalls}
+	close(events)
+
+	var chunks []string
+	for chunk := range assistant.TextWithToolCalls(context.TODO(), events) {
+		chunks = append(chunks, chunk)
+	}
+
+	want := []string{
+		"Let me check.",
+		`{"function":{"arguments":"","name":"lookup"},"id":"call_1","type":"tool_call"}`,
+		`{"function":{"arguments":"{\"q\":\"go\"}","name":""},"id":"","type":"tool_call"}`,
+	}
+	if strings.Join(chunks, "\n") != strings.Join(want, "\n") {
+		t.Errorf("expected chunks %q, got %q", want, chunks)
+	}
+}
+
+func TestToSSEResult_Error(t *testing.T) {
+	events := make(chan assistant.Event, 2)
+	events <- assistant.Event{Type: assistant.EventTextDelta, Text: "Hel"}
+	events <- assistant.Event{Type: assistant.EventError, Err: errors.New("throttled")}
+	close(events)
+
+	recorder := httptest.NewRecorder()
+	assistant.ToSSEResult(context.TODO(), recorder, assistant.NewStreamResult(context.TODO(), events))
+
+	body := recorder.Body.String()
+	parts := strings.Split(strings.TrimSpace(body), "\n\n")
+
+	if len(parts) != 5 { // f + token + 3 + d + e
+		t.Fatalf("expected 5 SSE events (f, 0, 3, d, e), got %d:\n%s", len(parts), body)
+	}
+
+	if parts[2] != `3:"throttled"` {
+		t.Errorf("expected error part '3:\"throttled\"', got: %s", parts[2])
+	}
+
+	for _, part := range parts[3:] {
+		if !strings.Contains(part, `"finishReason":"error"`) {
+			t.Errorf("expected finishReason 'error' in %s", part)
+		}
+	}
+}
+
+func TestToSSEResult_FinishReasonAndUsage(t *testing.T) {
+	events := make(chan assistant.Event, 3)
+	events <- assistant.Event{Type: assistant.EventTextDelta, Text: "Hello"}
+	events <- assistant.Event{Type: assistant.EventFinish, FinishReason: assistant.FinishReasonLength}
+	events <- assistant.Event{Type: assistant.EventUsage, Usage: &assistant.UsageMetadata{
+		PromptTokenCount:     12,
+		CandidatesTokenCount: 34,
+		TotalTokenCount:      46,
+	}}
+	close(events)
+
+	recorder := httptest.NewRecorder()
+	assistant.ToSSEResult(context.TODO(), recorder, assistant.NewStreamResult(context.TODO(), events))
+
+	body := recorder.Body.String()
+	parts := strings.Split(strings.TrimSpace(body), "\n\n")
+
+	expected := map[string]string{
+		"d:": `d:{"finishReason":"length","usage":{"promptTokens":12,"completionTokens":34}}`,
+		"e:": `e:{"finishReason":"length","usage":{"promptTokens":12,"completionTokens":34},"isContinued":false}`,
+	}
+	for prefix, want := range expected {
+		found := false
+		for _, part := range parts {
+			if strings.HasPrefix(part, prefix) {
+				found = true
+				if part != want {
+					t.Errorf("expected %s, got %s", want, part)
+				}
+			}
+		}
+		if !found {
+			t.Errorf("expected stream to contain %q event", prefix)
+		}
+	}
+}
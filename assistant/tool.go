@@ -11,6 +11,9 @@ type ToolFunction struct {
 	Name        string                 `json:"name"`
 	Description string                 `json:"description"`
 	Parameters  map[string]interface{} `json:"parameters"` // JSON Schema
+	// Strict asks the provider to enforce Parameters exactly. Only OpenAI
+	// supports it, and it requires a schema that meets OpenAI's rules.
+	Strict bool `json:"strict,omitempty"`
 }
 
 // ToolChoice controls how the model uses tools
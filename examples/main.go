@@ -2,7 +2,6 @@
 package main
 
 import (
-	"context"
 	"encoding/json"
 	"log"
 	"net/http"
@@ -33,14 +32,14 @@ func main() {
 			return
 		}
 
-		stream, err := providerClient.ChatStream(r.Context(), req.Messages)
+		events, err := providerClient.ChatStreamEvents(r.Context(), req.Messages, nil, assistant.ToolChoiceAuto)
 		if err != nil {
 			http.Error(w, "Failed to stream from provider", http.StatusInternalServerError)
 			log.Println("stream error:", err)
 			return
 		}
 
-		assistant.ToSSE(context.TODO(), w, stream)
+		assistant.ToSSEResult(r.Context(), w, assistant.NewStreamResult(r.Context(), events))
 	})
 
 	log.Println("Listening on http://localhost:8080")
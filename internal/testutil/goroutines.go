@@ -0,0 +1,24 @@
+// Package testutil holds helpers shared by the provider tests.
+package testutil
+
+import (
+	"runtime"
+	"testing"
+	"time"
+)
+
+// WaitForGoroutines waits up to two seconds for the number of goroutines to
+// drop back to baseline, and fails t with every goroutine's stack if it
+// does not.
+func WaitForGoroutines(t testing.TB, baseline int) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for runtime.NumGoroutine() > baseline {
+		if time.Now().After(deadline) {
+			buf := make([]byte, 1<<16)
+			n := runtime.Stack(buf, true)
+			t.Fatalf("leaked goroutines: %d running, expected %d\n%s", runtime.NumGoroutine(), baseline, buf[:n])
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}